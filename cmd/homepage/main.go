@@ -1,16 +1,30 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
+	"homedash/internal/audit"
+	"homedash/internal/auth"
 	"homedash/internal/handlers"
 	"homedash/internal/monitor"
+	"homedash/internal/recording"
 	"homedash/internal/routes"
+	"homedash/internal/scheduler"
+	"homedash/internal/sftpd"
+	"homedash/internal/sshterm"
+	"homedash/internal/storage"
+	"homedash/internal/terminal"
+	"homedash/internal/tlscert"
 
 	"github.com/gin-gonic/gin"
 )
@@ -57,6 +71,10 @@ func disableQuickEdit() {
 }
 
 func main() {
+	// 存储后端：json（默认，services.json/settings.json 等文件）或 sqlite（data/homedash.db）
+	storageKind := flag.String("storage", os.Getenv("STORAGE_BACKEND"), "存储后端 json 或 sqlite")
+	flag.Parse()
+
 	// 查找项目根目录
 	projectRoot, err := findProjectRoot()
 	if err != nil {
@@ -80,7 +98,16 @@ func main() {
 	settingsFile := filepath.Join(webDir, "settings.json")
 	servicesFile := filepath.Join(webDir, "services.json")
 
-	// WebDAV 根目录：优先从设置文件加载，否则使用环境变量或默认用户目录
+	// 运行数据目录（任务、执行记录等），不放在 web 目录下以免被静态访问
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	if err := storage.PrepareDataDir(dataDir); err != nil {
+		log.Fatalf("创建数据目录失败: %v", err)
+	}
+
+	// 文件根目录：设置中未配置挂载点时使用环境变量或默认用户目录
 	webdavRoot := os.Getenv("WEBDAV_ROOT")
 	if webdavRoot == "" {
 		homeDir, _ := os.UserHomeDir()
@@ -92,15 +119,60 @@ func main() {
 	}
 
 	// 初始化处理器全局变量
-	handlers.InitHandlers(webDir, settingsFile, servicesFile, webdavRoot)
+	handlers.InitHandlers(webDir, webdavRoot)
+	handlers.SetDataDir(dataDir)
+
+	// 打开存储后端（首次切换到 SQLite 时自动导入现有 JSON 文件）
+	store, err := storage.Open(*storageKind, dataDir, map[string]string{
+		storage.KeyServices: servicesFile,
+		storage.KeySettings: settingsFile,
+	})
+	if err != nil {
+		log.Fatalf("打开存储失败: %v", err)
+	}
+	defer store.Close()
+	handlers.InitStorage(store)
+	log.Printf("存储后端: %s", store.Kind())
+
+	// 初始化登录认证（无用户时首次访问进入初始化页面）
+	authManager, err := auth.NewManager(store)
+	if err != nil {
+		log.Fatalf("初始化认证失败: %v", err)
+	}
+	handlers.InitAuth(authManager)
+
+	// 审计日志（登录、锁定等认证事件同样写入）
+	auditLog, err := audit.Open(store, dataDir)
+	if err != nil {
+		log.Fatalf("打开审计日志失败: %v", err)
+	}
+	defer auditLog.Close()
+	handlers.InitAudit(auditLog)
+	authManager.OnEvent(handlers.RecordAuthEvent)
+
+	// 检查配置文件完整性
+	handlers.CheckConfigFiles()
 
 	// 初始化默认服务
 	handlers.InitDefaultServices()
 
-	// 从设置文件加载 WebDAV 根目录
-	savedSettings := handlers.LoadSettings()
-	if savedSettings.WebdavRoot != "" {
-		handlers.SetWebdavRoot(savedSettings.WebdavRoot)
+	// 启动服务定时任务调度器
+	handlers.StartServiceScheduler()
+
+	// 从设置文件加载挂载点
+	handlers.LoadMounts()
+
+	// 初始化定时任务调度器
+	var jobScheduler *scheduler.Scheduler
+	jobStore, err := scheduler.OpenStore(store, dataDir)
+	if err == nil {
+		jobScheduler, err = scheduler.New(jobStore, handlers.NewJobCommand)
+	}
+	if err != nil {
+		log.Printf("⚠ 定时任务调度器初始化失败: %v", err)
+	} else {
+		jobScheduler.Start()
+		handlers.InitScheduler(jobScheduler)
 	}
 
 	// 初始化监控 Hub
@@ -108,6 +180,82 @@ func main() {
 	go monitorHub.Run()
 	handlers.InitMonitor(monitorHub)
 
+	// 事件推送（配置被外部修改等），所有已登录页面都会连接
+	eventHub := monitor.NewEventHub()
+	go eventHub.Run()
+	handlers.InitEvents(eventHub)
+
+	// 终端会话：浏览器断开后继续运行，空闲超时后自动结束
+	maxSessions := terminal.DefaultMaxSessions
+	if v, err := strconv.Atoi(os.Getenv("TERMINAL_MAX_SESSIONS")); err == nil {
+		maxSessions = v
+	}
+	idleTimeout := terminal.DefaultIdleTimeout
+	if v, err := time.ParseDuration(os.Getenv("TERMINAL_IDLE_TIMEOUT")); err == nil {
+		idleTimeout = v
+	}
+	terminalSessions := terminal.NewManager(maxSessions, idleTimeout)
+	handlers.InitTerminal(terminalSessions)
+
+	// SSH 主机配置（凭据用独立的密钥文件加密保存）和 known_hosts（只能连接已信任密钥的主机）
+	sshHosts, err := sshterm.NewHosts(store, filepath.Join(dataDir, "ssh", "hosts.key"))
+	if err != nil {
+		log.Fatalf("加载 SSH 主机失败: %v", err)
+	}
+	knownHosts, err := sshterm.OpenKnownHosts(filepath.Join(dataDir, "ssh", "known_hosts"))
+	if err != nil {
+		log.Fatalf("打开 known_hosts 失败: %v", err)
+	}
+	handlers.InitSSH(sshHosts, knownHosts)
+
+	// 终端录像：TERMINAL_RECORDING 开启后录制每个会话，按保留时间和总大小自动清理
+	retention := recording.Retention{MaxAge: recording.DefaultMaxAge, MaxBytes: recording.DefaultMaxBytes}
+	if v, err := time.ParseDuration(os.Getenv("TERMINAL_RECORDING_RETENTION")); err == nil {
+		retention.MaxAge = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("TERMINAL_RECORDING_MAX_MB"), 10, 64); err == nil {
+		retention.MaxBytes = v << 20
+	}
+	if recordings, err := recording.Open(filepath.Join(dataDir, "recordings"), retention); err != nil {
+		log.Printf("⚠ 终端录像目录打开失败: %v", err)
+	} else {
+		handlers.InitTerminalRecordings(recordings, envBool("TERMINAL_RECORDING"))
+	}
+
+	// SFTP：设置 SFTP_PORT 后启用，与 WebDAV 共用根目录和账号
+	sftpKeys, err := sftpd.NewKeys(store)
+	if err != nil {
+		log.Fatalf("加载 SFTP 公钥失败: %v", err)
+	}
+	var sftpServer *sftpd.Server
+	if sftpPort := os.Getenv("SFTP_PORT"); sftpPort != "" {
+		sftpServer, err = sftpd.New(sftpd.Config{
+			Addr:        "0.0.0.0:" + sftpPort,
+			HostKeyPath: filepath.Join(dataDir, "ssh", "sftp_host_ed25519_key"),
+			Auth:        authManager,
+			Keys:        sftpKeys,
+			Audit:       auditLog,
+			Mounts:      handlers.Mounts,
+			Limits:      handlers.SFTPLimits,
+		})
+		if err != nil {
+			log.Fatalf("初始化 SFTP 失败: %v", err)
+		}
+		go func() {
+			if err := sftpServer.ListenAndServe(); err != nil {
+				log.Printf("⚠ SFTP 监听失败: %v", err)
+			}
+		}()
+		defer sftpServer.Close()
+		log.Printf("SFTP 服务监听端口 %s，主机密钥指纹 %s", sftpPort, sftpServer.Fingerprint())
+	}
+	handlers.InitSFTP(sftpServer, sftpKeys)
+
+	// 监听配置文件的外部修改
+	if err := handlers.StartConfigWatcher(); err != nil {
+		log.Printf("⚠ 配置文件监听启动失败: %v", err)
+	}
+
 	// 创建路由
 	router := gin.New()
 	router.Use(gin.Logger())
@@ -117,12 +265,69 @@ func main() {
 	routes.SetupRoutes(router, webDir, port)
 
 	addr := "0.0.0.0:" + port
-	log.Printf("HomeDash Win is running at http://%s", addr)
-	if err := router.Run(addr); err != nil {
+	tlsConfig := tlscert.Config{
+		CertFile:   os.Getenv("TLS_CERT_FILE"),
+		KeyFile:    os.Getenv("TLS_KEY_FILE"),
+		SelfSigned: envBool("TLS_SELF_SIGNED"),
+		Hosts:      strings.Split(os.Getenv("TLS_HOSTS"), ","),
+		DataDir:    dataDir,
+	}
+	if !tlsConfig.Enabled() {
+		log.Printf("HomeDash Win is running at http://%s", addr)
+		if err := router.Run(addr); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := runTLS(router, addr, port, tlsConfig); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// runTLS 以 HTTPS 提供服务，可选 HTTP 重定向监听和 HSTS
+func runTLS(router http.Handler, addr, port string, cfg tlscert.Config) error {
+	certs, err := tlscert.New(cfg)
+	if err != nil {
+		return err
+	}
+	if err := certs.Watch(); err != nil {
+		log.Printf("⚠ 证书文件监听启动失败，修改证书后需重启: %v", err)
+	}
+	if caFile := certs.CAFile(); caFile != "" {
+		log.Printf("使用自签名证书，将 CA 证书 %s 导入系统或浏览器信任后即可消除证书警告", caFile)
+	}
+
+	handler := router
+	if envBool("TLS_HSTS") {
+		handler = tlscert.HSTS(handler)
+	}
+
+	// HTTP → HTTPS 重定向
+	if redirectPort := os.Getenv("HTTP_REDIRECT_PORT"); redirectPort != "" {
+		go func() {
+			redirectAddr := "0.0.0.0:" + redirectPort
+			log.Printf("HTTP 请求将从 %s 重定向到 HTTPS", redirectAddr)
+			if err := http.ListenAndServe(redirectAddr, tlscert.RedirectHandler(port)); err != nil {
+				log.Printf("⚠ HTTP 重定向监听失败: %v", err)
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: certs.TLSConfig(),
+	}
+	log.Printf("HomeDash Win is running at https://%s", addr)
+	return server.ListenAndServeTLS("", "")
+}
+
+// envBool 读取布尔型环境变量（1、true 等）
+func envBool(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
 // findProjectRoot 查找项目根目录（包含go.mod的目录）
 func findProjectRoot() (string, error) {
 	// 首先尝试从当前工作目录向上查找
@@ -0,0 +1,141 @@
+// Package audit 审计日志：记录谁在什么时间、从哪里、对什么执行了什么操作以及结果
+package audit
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"homedash/internal/storage"
+)
+
+// 操作结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied" // 未登录、无权限或被锁定
+)
+
+// retention 审计日志保留时长
+const retention = 365 * 24 * time.Hour
+
+// maxFieldLength 目标和详情的最大长度，超出部分截断
+const maxFieldLength = 500
+
+// Entry 一条审计记录
+type Entry struct {
+	Time    int64  `json:"time"`              // 毫秒时间戳
+	Actor   string `json:"actor"`             // 用户名，未登录时为空
+	ActorID string `json:"actorId,omitempty"` // 用户 ID
+	Via     string `json:"via,omitempty"`     // 认证方式：session 或 token:<名称>
+	IP      string `json:"ip"`
+	Action  string `json:"action"`           // 如 service.stop、file.delete
+	Target  string `json:"target,omitempty"` // 操作对象，如服务名称、文件路径、命令
+	Outcome string `json:"outcome"`
+	Status  int    `json:"status,omitempty"` // HTTP 状态码
+	Detail  string `json:"detail,omitempty"` // 失败原因等
+}
+
+// Filter 查询条件，零值表示不限制
+type Filter struct {
+	Actor   string    // 用户名（不区分大小写）
+	Action  string    // 操作前缀，如 "service" 匹配全部服务操作
+	Target  string    // 目标包含的文本
+	Outcome string    // 结果
+	IP      string    // 来源 IP
+	From    time.Time // 起始时间（含）
+	To      time.Time // 结束时间（不含）
+	Limit   int       // 最多返回条数，按时间倒序
+}
+
+// Match 判断记录是否满足条件
+func (f Filter) Match(e Entry) bool {
+	if f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor) {
+		return false
+	}
+	if f.Action != "" && !strings.HasPrefix(e.Action, f.Action) {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(e.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	if f.Outcome != "" && e.Outcome != f.Outcome {
+		return false
+	}
+	if f.IP != "" && e.IP != f.IP {
+		return false
+	}
+	if !f.From.IsZero() && e.Time < f.From.UnixMilli() {
+		return false
+	}
+	if !f.To.IsZero() && e.Time >= f.To.UnixMilli() {
+		return false
+	}
+	return true
+}
+
+// Store 审计记录的存储
+type Store interface {
+	Append(e Entry) error
+	// Query 按时间倒序返回满足条件的记录
+	Query(f Filter) ([]Entry, error)
+	Close() error
+}
+
+// Log 审计日志
+type Log struct {
+	store Store
+}
+
+// Open 按存储后端打开审计日志：SQLite 后端写入 audit_log 表，JSON 后端按月写入 dataDir/audit/*.jsonl
+func Open(backend storage.Backend, dataDir string) (*Log, error) {
+	var store Store
+	var err error
+	if db, ok := backend.(*storage.SQLiteBackend); ok {
+		store, err = newSQLStore(db.DB())
+	} else {
+		store, err = newFileStore(dataDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Log{store: store}, nil
+}
+
+// Record 写入一条审计记录，失败时只记录日志，不影响业务
+func (l *Log) Record(e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time == 0 {
+		e.Time = time.Now().UnixMilli()
+	}
+	e.Target = truncate(e.Target)
+	e.Detail = truncate(e.Detail)
+	if err := l.store.Append(e); err != nil {
+		log.Printf("⚠ 写入审计日志失败: %v", err)
+	}
+}
+
+// Query 查询审计记录
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return l.store.Query(f)
+}
+
+// Close 关闭审计日志
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.store.Close()
+}
+
+func truncate(s string) string {
+	if len([]rune(s)) <= maxFieldLength {
+		return s
+	}
+	return string([]rune(s)[:maxFieldLength]) + "…"
+}
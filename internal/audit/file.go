@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monthLayout 按月分文件：audit/2006-01.jsonl
+const monthLayout = "2006-01"
+
+// fileStore 以 JSON Lines 追加写入的审计日志，每月一个文件
+type fileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newFileStore(dataDir string) (*fileStore, error) {
+	dir := filepath.Join(dataDir, "audit")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &fileStore{dir: dir}
+	s.prune(time.Now())
+	return s, nil
+}
+
+// Append 追加一条记录
+func (s *fileStore) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := filepath.Join(s.dir, time.UnixMilli(e.Time).Format(monthLayout)+".jsonl")
+	_, statErr := os.Stat(name)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	if os.IsNotExist(statErr) {
+		// 新的月份，顺便清理过期文件
+		s.prune(time.UnixMilli(e.Time))
+	}
+	return err
+}
+
+// Query 从新到旧扫描各月文件
+func (s *fileStore) Query(f Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	months, err := s.months()
+	if err != nil {
+		return nil, err
+	}
+	var result []Entry
+	for i := len(months) - 1; i >= 0; i-- {
+		month, _ := time.ParseInLocation(monthLayout, months[i], time.Local)
+		if !f.To.IsZero() && !month.Before(f.To) {
+			continue
+		}
+		if !f.From.IsZero() && !month.AddDate(0, 1, 0).After(f.From) {
+			break
+		}
+		entries, err := s.readMonth(months[i], f)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[:f.Limit]
+	}
+	return result, nil
+}
+
+// readMonth 读取某月中满足条件的记录（时间倒序），损坏的行会被跳过
+func (s *fileStore) readMonth(month string, f Filter) ([]Entry, error) {
+	file, err := os.Open(filepath.Join(s.dir, month+".jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// 文件按写入顺序追加，先反转再排序，使同一毫秒内的记录也是新的在前
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time > entries[j].Time })
+	return entries, nil
+}
+
+// months 已有的月份文件（升序）
+func (s *fileStore) months() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var months []string
+	for _, file := range files {
+		month, ok := strings.CutSuffix(file.Name(), ".jsonl")
+		if !ok || file.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(monthLayout, month); err == nil {
+			months = append(months, month)
+		}
+	}
+	sort.Strings(months)
+	return months, nil
+}
+
+// prune 删除超过保留期的月份文件
+func (s *fileStore) prune(now time.Time) {
+	months, err := s.months()
+	if err != nil {
+		return
+	}
+	cutoff := now.Add(-retention)
+	for _, month := range months {
+		t, _ := time.ParseInLocation(monthLayout, month, time.Local)
+		if t.AddDate(0, 1, 0).Before(cutoff) {
+			os.Remove(filepath.Join(s.dir, month+".jsonl"))
+		}
+	}
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// sqlStore 写入 SQLite 的 audit_log 表（由 storage 的迁移创建）
+type sqlStore struct {
+	db *sql.DB
+}
+
+func newSQLStore(db *sql.DB) (*sqlStore, error) {
+	s := &sqlStore{db: db}
+	if _, err := db.Exec(`DELETE FROM audit_log WHERE time < ?`, time.Now().Add(-retention).UnixMilli()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append 写入一条记录
+func (s *sqlStore) Append(e Entry) error {
+	_, err := s.db.Exec(`INSERT INTO audit_log (time, actor, actor_id, via, ip, action, target, outcome, status, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time, e.Actor, e.ActorID, e.Via, e.IP, e.Action, e.Target, e.Outcome, e.Status, e.Detail)
+	return err
+}
+
+// Query 按条件查询，时间倒序
+func (s *sqlStore) Query(f Filter) ([]Entry, error) {
+	var where []string
+	var args []interface{}
+	if f.Actor != "" {
+		where = append(where, "actor = ? COLLATE NOCASE")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		where = append(where, "substr(action, 1, ?) = ?")
+		args = append(args, len(f.Action), f.Action)
+	}
+	if f.Target != "" {
+		where = append(where, "instr(lower(target), lower(?)) > 0")
+		args = append(args, f.Target)
+	}
+	if f.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if f.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, f.IP)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, f.From.UnixMilli())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, f.To.UnixMilli())
+	}
+
+	query := `SELECT time, actor, actor_id, via, ip, action, target, outcome, status, detail FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Time, &e.Actor, &e.ActorID, &e.Via, &e.IP, &e.Action, &e.Target, &e.Outcome, &e.Status, &e.Detail); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Close 数据库由存储后端负责关闭
+func (s *sqlStore) Close() error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package auth
+
+import "time"
+
+// 认证事件类型
+const (
+	EventLoginSucceeded = "login.succeeded"
+	EventLoginFailed    = "login.failed"
+	EventLockout        = "login.lockout"
+	EventTOTPEnabled    = "totp.enabled"
+	EventTOTPDisabled   = "totp.disabled"
+)
+
+// Event 认证事件，供审计日志等模块记录
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	UserID   string    `json:"userId,omitempty"`
+	Username string    `json:"username,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// OnEvent 设置认证事件的处理函数（在同一 goroutine 中同步调用，不要阻塞）
+func (m *Manager) OnEvent(fn func(Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEvent = fn
+}
+
+// emit 发送认证事件，调用方不能持有锁
+func (m *Manager) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	m.mu.Lock()
+	fn := m.onEvent
+	m.mu.Unlock()
+	if fn != nil {
+		fn(e)
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录失败的退避策略：前 freeAttempts 次失败不限制，之后每次失败锁定时间翻倍
+const (
+	freeAttempts    = 5
+	baseLockout     = 30 * time.Second
+	maxLockout      = time.Hour
+	failureForgetAt = 24 * time.Hour // 超过该时间没有失败则清零
+)
+
+// LockedError 登录尝试过于频繁
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	wait := time.Until(e.Until).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("登录失败次数过多，请 %s 后再试", wait)
+}
+
+// RetryAfter 距离可以重试的秒数
+func (e *LockedError) RetryAfter() int {
+	return int(math.Ceil(time.Until(e.Until).Seconds()))
+}
+
+type failureRecord struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter 按来源 IP 和用户名分别记录失败次数（仅内存，重启后清零）
+type loginLimiter struct {
+	mu      sync.Mutex
+	records map[string]*failureRecord
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{records: make(map[string]*failureRecord)}
+}
+
+func limiterKeys(username, ip string) []string {
+	return []string{"ip:" + ip, "user:" + strings.ToLower(strings.TrimSpace(username))}
+}
+
+// check 是否处于锁定中
+func (l *loginLimiter) check(keys []string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var until time.Time
+	for _, key := range keys {
+		if r, ok := l.records[key]; ok && r.lockedUntil.After(now) && r.lockedUntil.After(until) {
+			until = r.lockedUntil
+		}
+	}
+	if until.IsZero() {
+		return nil
+	}
+	return &LockedError{Until: until}
+}
+
+// fail 记录一次失败，返回新进入锁定状态的键及锁定时长
+func (l *loginLimiter) fail(keys []string, now time.Time) map[string]time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked(now)
+
+	locked := map[string]time.Duration{}
+	for _, key := range keys {
+		r, ok := l.records[key]
+		if !ok {
+			r = &failureRecord{}
+			l.records[key] = r
+		}
+		r.count++
+		r.lastFailure = now
+		if r.count < freeAttempts {
+			continue
+		}
+		lockout := maxLockout
+		if shift := r.count - freeAttempts; shift < 16 {
+			lockout = baseLockout << uint(shift)
+		}
+		if lockout > maxLockout {
+			lockout = maxLockout
+		}
+		r.lockedUntil = now.Add(lockout)
+		locked[key] = lockout
+	}
+	return locked
+}
+
+// succeed 登录成功后清除记录
+func (l *loginLimiter) succeed(keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.records, key)
+	}
+}
+
+// pruneLocked 清理长时间没有失败的记录
+func (l *loginLimiter) pruneLocked(now time.Time) {
+	for key, r := range l.records {
+		if now.Sub(r.lastFailure) > failureForgetAt && !r.lockedUntil.After(now) {
+			delete(l.records, key)
+		}
+	}
+}
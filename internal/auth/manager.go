@@ -0,0 +1,566 @@
+// Package auth 本地用户、登录会话与认证配置
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"homedash/internal/storage"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrUserNotFound       = errors.New("用户不存在")
+	ErrUserExists         = errors.New("用户名已存在")
+	ErrSetupDone          = errors.New("已完成初始化，请直接登录")
+	ErrLastUser           = errors.New("不能删除最后一个用户")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// dummyHash 用户不存在时也做一次哈希比较，避免通过响应时间判断用户名是否存在
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("homedash-dummy-password"), bcrypt.DefaultCost)
+
+// Manager 管理用户、会话与认证配置
+type Manager struct {
+	mu        sync.Mutex
+	backend   storage.Backend // 认证配置文档
+	store     Store           // 用户、会话和令牌
+	users     []User
+	sessions  []Session
+	tokens    []APIToken
+	config    Config
+	limiter   *loginLimiter
+	wsTickets *wsTicketStore
+	onEvent   func(Event)
+}
+
+// NewManager 从存储加载用户、会话和配置
+func NewManager(backend storage.Backend) (*Manager, error) {
+	store, err := openStore(backend)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{backend: backend, store: store, limiter: newLoginLimiter(), wsTickets: newWSTicketStore()}
+	if m.users, err = store.LoadUsers(); err != nil {
+		return nil, fmt.Errorf("加载用户失败: %v", err)
+	}
+	if m.sessions, err = store.LoadSessions(); err != nil {
+		return nil, fmt.Errorf("加载会话失败: %v", err)
+	}
+	if m.tokens, err = store.LoadTokens(); err != nil {
+		return nil, fmt.Errorf("加载 API 令牌失败: %v", err)
+	}
+	if err := loadDocument(backend, storage.KeyAuth, &m.config); err != nil {
+		return nil, fmt.Errorf("加载认证配置失败: %v", err)
+	}
+	// 引入角色之前创建的用户拥有全部权限，视为管理员
+	for i := range m.users {
+		if m.users[i].Role == "" {
+			m.users[i].Role = RoleAdmin
+		}
+	}
+	m.pruneSessionsLocked(time.Now())
+	return m, nil
+}
+
+// NeedsSetup 尚未创建任何用户
+func (m *Manager) NeedsSetup() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.users) == 0
+}
+
+// Setup 首次运行时创建第一个用户（管理员）
+func (m *Manager) Setup(username, password string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.users) > 0 {
+		return User{}, ErrSetupDone
+	}
+	return m.createUserLocked(username, password, RoleAdmin, nil)
+}
+
+// CreateUser 创建用户，permissions 仅在自定义角色下使用
+func (m *Manager) CreateUser(username, password, role string, permissions []string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createUserLocked(username, password, role, permissions)
+}
+
+func (m *Manager) createUserLocked(username, password, role string, permissions []string) (User, error) {
+	permissions, err := checkRole(role, permissions)
+	if err != nil {
+		return User{}, err
+	}
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return User{}, fmt.Errorf("用户名只能包含字母、数字、下划线、点和横线，长度 1-32")
+	}
+	for _, u := range m.users {
+		if strings.EqualFold(u.Username, username) {
+			return User{}, ErrUserExists
+		}
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	now := time.Now().UnixMilli()
+	user := User{
+		ID:           uuid.New().String()[:8],
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		Permissions:  permissions,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := m.store.SaveUser(user); err != nil {
+		return User{}, err
+	}
+	m.users = append(append([]User(nil), m.users...), user)
+	return user, nil
+}
+
+// Users 用户列表
+func (m *Manager) Users() []User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]User(nil), m.users...)
+}
+
+// User 按 ID 查找用户
+func (m *Manager) User(id string) (User, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.userLocked(id)
+}
+
+// Authenticate 校验用户名和密码
+func (m *Manager) Authenticate(username, password string) (User, error) {
+	m.mu.Lock()
+	var found *User
+	for i := range m.users {
+		if strings.EqualFold(m.users[i].Username, strings.TrimSpace(username)) {
+			u := m.users[i]
+			found = &u
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if found == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(found.PasswordHash), []byte(password)) != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return *found, nil
+}
+
+// Login 登录校验：失败退避、密码和两步验证
+// 启用两步验证的用户需同时提供验证码或恢复码，未提供时返回 ErrTOTPRequired
+func (m *Manager) Login(username, password, code, ip string) (User, error) {
+	keys := limiterKeys(username, ip)
+	if err := m.limiter.check(keys, time.Now()); err != nil {
+		return User{}, err
+	}
+
+	user, err := m.Authenticate(username, password)
+	if err == nil && user.TOTPEnabled {
+		err = m.checkSecondFactor(user.ID, code)
+	}
+	// 密码正确、等待输入验证码时不计为失败
+	if errors.Is(err, ErrTOTPRequired) {
+		return User{}, err
+	}
+	if err != nil {
+		m.loginFailed(keys, username, ip, err)
+		return User{}, err
+	}
+
+	// 只清除该用户的失败记录，IP 的记录保留，避免用一个已知账号重置对其他账号的猜测限制
+	m.limiter.succeed(keys[1:])
+	m.emit(Event{Type: EventLoginSucceeded, UserID: user.ID, Username: user.Username, IP: ip})
+	return user, nil
+}
+
+// loginFailed 记录失败，触发锁定时发送锁定事件
+func (m *Manager) loginFailed(keys []string, username, ip string, reason error) {
+	locked := m.limiter.fail(keys, time.Now())
+	m.emit(Event{Type: EventLoginFailed, Username: username, IP: ip, Detail: reason.Error()})
+	for _, key := range keys {
+		if d, ok := locked[key]; ok {
+			m.emit(Event{Type: EventLockout, Username: username, IP: ip, Detail: fmt.Sprintf("%s 锁定 %s", key, d)})
+		}
+	}
+}
+
+// SetPassword 修改密码，并注销该用户的其他会话（keepSessionID 为保留的当前会话）
+func (m *Manager) SetPassword(userID, password, keepSessionID string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := append([]User(nil), m.users...)
+	index := -1
+	for i := range users {
+		if users[i].ID == userID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrUserNotFound
+	}
+	users[index].PasswordHash = hash
+	users[index].UpdatedAt = time.Now().UnixMilli()
+	if err := m.store.SaveUser(users[index]); err != nil {
+		return err
+	}
+	m.users = users
+
+	return m.removeSessionsLocked(func(s Session) bool {
+		return s.UserID == userID && s.ID != keepSessionID
+	})
+}
+
+// SetRole 修改用户角色，permissions 仅在自定义角色下使用
+func (m *Manager) SetRole(userID, role string, permissions []string) (User, error) {
+	permissions, err := checkRole(role, permissions)
+	if err != nil {
+		return User{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := append([]User(nil), m.users...)
+	index := -1
+	for i := range users {
+		if users[i].ID == userID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return User{}, ErrUserNotFound
+	}
+	users[index].Role = role
+	users[index].Permissions = permissions
+	users[index].UpdatedAt = time.Now().UnixMilli()
+	if !hasAdmin(users) {
+		return User{}, ErrLastAdmin
+	}
+	if err := m.store.SaveUser(users[index]); err != nil {
+		return User{}, err
+	}
+	m.users = users
+	return users[index], nil
+}
+
+// DeleteUser 删除用户及其会话和 API 令牌
+func (m *Manager) DeleteUser(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		if u.ID != userID {
+			users = append(users, u)
+		}
+	}
+	if len(users) == len(m.users) {
+		return ErrUserNotFound
+	}
+	if len(users) == 0 {
+		return ErrLastUser
+	}
+	if !hasAdmin(users) {
+		return ErrLastAdmin
+	}
+	if err := m.store.DeleteUser(userID); err != nil {
+		return err
+	}
+	m.users = users
+
+	if err := m.removeTokensLocked(func(t APIToken) bool { return t.UserID == userID }); err != nil && !errors.Is(err, ErrTokenNotFound) {
+		return err
+	}
+	return m.removeSessionsLocked(func(s Session) bool { return s.UserID == userID })
+}
+
+// CreateSession 为用户创建会话，返回写入 Cookie 的令牌
+func (m *Manager) CreateSession(userID, ip, userAgent string) (string, Session, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", Session{}, err
+	}
+	csrf, err := randomToken(32)
+	if err != nil {
+		return "", Session{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	session := Session{
+		ID:        uuid.New().String()[:8],
+		TokenHash: hashToken(token),
+		CSRFToken: csrf,
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: now.UnixMilli(),
+		LastSeen:  now.UnixMilli(),
+		ExpiresAt: now.Add(m.config.sessionTTL()).UnixMilli(),
+	}
+	m.pruneSessionsLocked(now)
+	if err := m.store.SaveSession(session); err != nil {
+		return "", Session{}, err
+	}
+	m.sessions = append(append([]Session(nil), m.sessions...), session)
+	return token, session, nil
+}
+
+// Session 根据令牌查找有效会话及其用户，使用超过一半有效期时自动续期
+func (m *Manager) Session(token string) (Session, User, bool) {
+	if token == "" {
+		return Session{}, User{}, false
+	}
+	hash := hashToken(token)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i := range m.sessions {
+		s := &m.sessions[i]
+		if subtle.ConstantTimeCompare([]byte(s.TokenHash), []byte(hash)) != 1 {
+			continue
+		}
+		if now.UnixMilli() >= s.ExpiresAt {
+			return Session{}, User{}, false
+		}
+		var user *User
+		for j := range m.users {
+			if m.users[j].ID == s.UserID {
+				user = &m.users[j]
+				break
+			}
+		}
+		if user == nil {
+			return Session{}, User{}, false
+		}
+
+		ttl := m.config.sessionTTL()
+		s.LastSeen = now.UnixMilli()
+		if time.UnixMilli(s.ExpiresAt).Sub(now) < ttl/2 {
+			s.ExpiresAt = now.Add(ttl).UnixMilli()
+			m.store.SaveSession(*s)
+		}
+		return *s, *user, true
+	}
+	return Session{}, User{}, false
+}
+
+// DeleteSession 注销会话
+func (m *Manager) DeleteSession(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.removeSessionsLocked(func(s Session) bool { return s.ID == sessionID })
+}
+
+// removeSessionsLocked 删除满足条件的会话
+func (m *Manager) removeSessionsLocked(match func(Session) bool) error {
+	sessions := make([]Session, 0, len(m.sessions))
+	var removed []string
+	for _, s := range m.sessions {
+		if match(s) {
+			removed = append(removed, s.ID)
+		} else {
+			sessions = append(sessions, s)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := m.store.DeleteSessions(removed); err != nil {
+		return err
+	}
+	m.sessions = sessions
+	return nil
+}
+
+// pruneSessionsLocked 清理已过期的会话，删除失败时留到下次清理
+func (m *Manager) pruneSessionsLocked(now time.Time) {
+	m.removeSessionsLocked(func(s Session) bool { return now.UnixMilli() >= s.ExpiresAt })
+}
+
+// Config 当前认证配置
+func (m *Manager) Config() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg := m.config
+	cfg.PublicRoutes = append([]string(nil), m.config.PublicRoutes...)
+	cfg.AllowedOrigins = append([]string(nil), m.config.AllowedOrigins...)
+	if cfg.SessionHours <= 0 {
+		cfg.SessionHours = defaultSessionHours
+	}
+	return cfg
+}
+
+// SetConfig 更新认证配置
+func (m *Manager) SetConfig(cfg Config) error {
+	routes := make([]string, 0, len(cfg.PublicRoutes))
+	for _, route := range cfg.PublicRoutes {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("公开路由必须以 / 开头: %s", route)
+		}
+		if coversProtected(route) {
+			return fmt.Errorf("公开路由不能包含 WebSocket、终端、SSH 或 WebDAV: %s", route)
+		}
+		routes = append(routes, route)
+	}
+	cfg.PublicRoutes = routes
+	origins := make([]string, 0, len(cfg.AllowedOrigins))
+	for _, raw := range cfg.AllowedOrigins {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		origin, err := normalizeOrigin(raw)
+		if err != nil {
+			return err
+		}
+		origins = append(origins, origin)
+	}
+	cfg.AllowedOrigins = origins
+	if cfg.SessionHours < 0 || cfg.SessionHours > 24*365 {
+		return fmt.Errorf("会话有效期无效")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := saveDocument(m.backend, storage.KeyAuth, cfg); err != nil {
+		return err
+	}
+	m.config = cfg
+	return nil
+}
+
+// protectedPrefixes 不允许公开的路径前缀：WebSocket 握手是 GET 请求，
+// 终端、SSH 和 WebDAV 可执行命令或读写文件，始终要求登录
+var protectedPrefixes = []string{"/ws/", "/api/terminal", "/api/ssh", "/webdav"}
+
+// coversProtected 判断公开路由是否会匹配受保护的路径
+func coversProtected(route string) bool {
+	prefix, wildcard := strings.CutSuffix(route, "*")
+	for _, protected := range protectedPrefixes {
+		if strings.HasPrefix(prefix, protected) || (wildcard && strings.HasPrefix(protected, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublic 判断请求是否命中公开的只读路由
+func (m *Manager) IsPublic(method, path string) bool {
+	if method != "GET" && method != "HEAD" {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, route := range m.config.PublicRoutes {
+		if coversProtected(route) {
+			continue // 旧版本保存的配置可能包含
+		}
+		if prefix, ok := strings.CutSuffix(route, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == route {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidCSRF 校验请求携带的 CSRF 令牌
+func ValidCSRF(session Session, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(session.CSRFToken), []byte(token)) == 1
+}
+
+// checkRole 校验角色，非自定义角色忽略权限列表
+func checkRole(role string, permissions []string) ([]string, error) {
+	if !validRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if role != RoleCustom {
+		return nil, nil
+	}
+	return normalizePermissions(permissions)
+}
+
+// hasAdmin 用户列表中是否还有管理员
+func hasAdmin(users []User) bool {
+	for _, u := range users {
+		if u.IsAdmin() {
+			return true
+		}
+	}
+	return false
+}
+
+// hashPassword 校验密码强度并生成 bcrypt 哈希
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("密码至少需要 %d 个字符", minPasswordLength)
+	}
+	if len(password) > 72 {
+		return "", fmt.Errorf("密码不能超过 72 个字节")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// randomToken 生成随机令牌（URL 安全的 base64）
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken 令牌哈希，存储中只保存哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 功能区域，与 SetupRoutes 中的路由分组一一对应
+const (
+	AreaServices = "services" // 服务卡片、启停、定时任务
+	AreaMonitor  = "monitor"  // 系统监控、进程列表
+	AreaFiles    = "files"    // 文件管理、WebDAV
+	AreaTerminal = "terminal" // 终端
+	AreaDocker   = "docker"
+	AreaComfyUI  = "comfyui"
+	AreaLogs     = "logs"
+	AreaSettings = "settings" // 程序设置、配置导入导出与历史
+)
+
+// Areas 全部功能区域
+var Areas = []string{AreaServices, AreaMonitor, AreaFiles, AreaTerminal, AreaDocker, AreaComfyUI, AreaLogs, AreaSettings}
+
+// 访问级别
+const (
+	AccessRead  = "read"
+	AccessWrite = "write" // 包含 read
+)
+
+// 角色
+const (
+	RoleAdmin    = "admin"    // 全部权限，可管理用户
+	RoleOperator = "operator" // 除终端和修改设置外的全部权限
+	RoleViewer   = "viewer"   // 只读查看服务、监控、文件、Docker、AI 绘画和日志
+	RoleCustom   = "custom"   // 使用用户自己的权限列表
+)
+
+var (
+	ErrInvalidRole = errors.New("无效的角色")
+	ErrLastAdmin   = errors.New("至少需要保留一个管理员")
+)
+
+// rolePermissions 内置角色的权限
+var rolePermissions = map[string][]string{
+	RoleOperator: {
+		"services:write", "monitor:write", "files:write", "docker:write",
+		"comfyui:write", "logs:write", "settings:read",
+	},
+	RoleViewer: {
+		"services:read", "monitor:read", "files:read", "docker:read",
+		"comfyui:read", "logs:read",
+	},
+}
+
+// Permission 权限字符串，如 "files:read"、"terminal:write"
+func Permission(area, access string) string {
+	return area + ":" + access
+}
+
+// validRole 是否为已知角色
+func validRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOperator, RoleViewer, RoleCustom:
+		return true
+	}
+	return false
+}
+
+// normalizePermissions 校验并去重自定义权限
+func normalizePermissions(perms []string) ([]string, error) {
+	result := make([]string, 0, len(perms))
+	seen := map[string]bool{}
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		area, access, ok := strings.Cut(p, ":")
+		if !ok || (access != AccessRead && access != AccessWrite) || !knownArea(area) {
+			return nil, fmt.Errorf("无效的权限 %q，格式为 <区域>:read 或 <区域>:write", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func knownArea(area string) bool {
+	for _, a := range Areas {
+		if a == area {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin 是否为管理员
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Can 判断用户对某区域是否有指定级别的访问权限（write 包含 read）
+func (u User) Can(area, access string) bool {
+	if u.IsAdmin() {
+		return true
+	}
+	perms := u.Permissions
+	if u.Role != RoleCustom {
+		perms = rolePermissions[u.Role]
+	}
+	for _, p := range perms {
+		if p == Permission(area, access) || p == Permission(area, AccessWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// Access 各区域的最高访问级别（无权限的区域不出现）
+func (u User) Access() map[string]string {
+	access := map[string]string{}
+	for _, area := range Areas {
+		if u.Can(area, AccessWrite) {
+			access[area] = AccessWrite
+		} else if u.Can(area, AccessRead) {
+			access[area] = AccessRead
+		}
+	}
+	return access
+}
+
+// EffectivePermissions 用户实际拥有的权限列表
+func (u User) EffectivePermissions() []string {
+	access := u.Access()
+	var perms []string
+	for _, area := range Areas {
+		if level, ok := access[area]; ok {
+			perms = append(perms, Permission(area, level))
+		}
+	}
+	return perms
+}
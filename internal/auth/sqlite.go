@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"homedash/internal/storage"
+)
+
+// sqlStore 写入 SQLite 的 users、sessions、api_tokens 表（由 storage 的迁移创建）
+type sqlStore struct {
+	db *sql.DB
+}
+
+// execer 可在数据库或事务上执行的语句
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// newSQLStore 表为空时导入旧版本保存的 users、sessions、tokens 文档
+func newSQLStore(db *sql.DB, backend storage.Backend) (*sqlStore, error) {
+	s := &sqlStore{db: db}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		if err := s.importDocuments(backend); err != nil {
+			return nil, fmt.Errorf("导入用户数据失败: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// importDocuments 在一个事务中导入文档中的用户、会话和令牌
+func (s *sqlStore) importDocuments(backend storage.Backend) error {
+	var users []User
+	var sessions []Session
+	var tokens []APIToken
+	if err := loadDocument(backend, storage.KeyUsers, &users); err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	if err := loadDocument(backend, storage.KeySessions, &sessions); err != nil {
+		return err
+	}
+	if err := loadDocument(backend, storage.KeyTokens, &tokens); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range users {
+		if err := saveUser(tx, u); err != nil {
+			return err
+		}
+	}
+	for _, session := range sessions {
+		if err := saveSession(tx, session); err != nil {
+			return err
+		}
+	}
+	for _, t := range tokens {
+		if err := saveToken(tx, t); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const userColumns = `id, username, password_hash, role, permissions, totp_enabled, totp_secret, totp_pending, totp_last_step, recovery_codes, created_at, updated_at`
+
+func (s *sqlStore) LoadUsers() ([]User, error) {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		var permissions, recovery string
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &permissions, &u.TOTPEnabled, &u.TOTPSecret,
+			&u.TOTPPending, &u.TOTPLastStep, &recovery, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := decodeList(permissions, &u.Permissions); err != nil {
+			return nil, err
+		}
+		if err := decodeList(recovery, &u.RecoveryCodes); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlStore) SaveUser(u User) error {
+	return saveUser(s.db, u)
+}
+
+func saveUser(db execer, u User) error {
+	_, err := db.Exec(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET username = excluded.username, password_hash = excluded.password_hash,
+			role = excluded.role, permissions = excluded.permissions, totp_enabled = excluded.totp_enabled,
+			totp_secret = excluded.totp_secret, totp_pending = excluded.totp_pending,
+			totp_last_step = excluded.totp_last_step, recovery_codes = excluded.recovery_codes,
+			updated_at = excluded.updated_at`,
+		u.ID, u.Username, u.PasswordHash, u.Role, encodeList(u.Permissions), u.TOTPEnabled, u.TOTPSecret,
+		u.TOTPPending, u.TOTPLastStep, encodeList(u.RecoveryCodes), u.CreatedAt, u.UpdatedAt)
+	return err
+}
+
+func (s *sqlStore) DeleteUser(id string) error {
+	_, err := s.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+const sessionColumns = `id, token_hash, csrf_token, user_id, ip, user_agent, created_at, last_seen, expires_at`
+
+func (s *sqlStore) LoadSessions() ([]Session, error) {
+	rows, err := s.db.Query(`SELECT ` + sessionColumns + ` FROM sessions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.TokenHash, &session.CSRFToken, &session.UserID, &session.IP,
+			&session.UserAgent, &session.CreatedAt, &session.LastSeen, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *sqlStore) SaveSession(session Session) error {
+	return saveSession(s.db, session)
+}
+
+func saveSession(db execer, session Session) error {
+	_, err := db.Exec(`INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET last_seen = excluded.last_seen, expires_at = excluded.expires_at`,
+		session.ID, session.TokenHash, session.CSRFToken, session.UserID, session.IP, session.UserAgent,
+		session.CreatedAt, session.LastSeen, session.ExpiresAt)
+	return err
+}
+
+func (s *sqlStore) DeleteSessions(ids []string) error {
+	return deleteIDs(s.db, "sessions", ids)
+}
+
+const tokenColumns = `id, user_id, name, hint, token_hash, scopes, created_at, expires_at, last_used_at, last_used_ip`
+
+func (s *sqlStore) LoadTokens() ([]APIToken, error) {
+	rows, err := s.db.Query(`SELECT ` + tokenColumns + ` FROM api_tokens ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Hint, &t.TokenHash, &scopes, &t.CreatedAt, &t.ExpiresAt,
+			&t.LastUsedAt, &t.LastUsedIP); err != nil {
+			return nil, err
+		}
+		if err := decodeList(scopes, &t.Scopes); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *sqlStore) SaveToken(t APIToken) error {
+	return saveToken(s.db, t)
+}
+
+func saveToken(db execer, t APIToken) error {
+	_, err := db.Exec(`INSERT INTO api_tokens (`+tokenColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, scopes = excluded.scopes, expires_at = excluded.expires_at,
+			last_used_at = excluded.last_used_at, last_used_ip = excluded.last_used_ip`,
+		t.ID, t.UserID, t.Name, t.Hint, t.TokenHash, encodeList(t.Scopes), t.CreatedAt, t.ExpiresAt,
+		t.LastUsedAt, t.LastUsedIP)
+	return err
+}
+
+func (s *sqlStore) DeleteTokens(ids []string) error {
+	return deleteIDs(s.db, "api_tokens", ids)
+}
+
+// deleteIDs 按 ID 删除多行（table 为内部常量，不来自用户输入）
+func deleteIDs(db *sql.DB, table string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	_, err := db.Exec(`DELETE FROM `+table+` WHERE id IN (`+placeholders+`)`, args...)
+	return err
+}
+
+// encodeList 列表字段保存为 JSON 数组
+func encodeList(list []string) string {
+	if len(list) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(list)
+	return string(data)
+}
+
+func decodeList(data string, list *[]string) error {
+	if err := json.Unmarshal([]byte(data), list); err != nil {
+		return err
+	}
+	if len(*list) == 0 {
+		*list = nil
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"homedash/internal/storage"
+)
+
+// Store 用户、会话和 API 令牌的存储，按单条记录插入、更新和删除
+type Store interface {
+	LoadUsers() ([]User, error)
+	SaveUser(u User) error
+	DeleteUser(id string) error
+	LoadSessions() ([]Session, error)
+	SaveSession(s Session) error
+	DeleteSessions(ids []string) error
+	LoadTokens() ([]APIToken, error)
+	SaveToken(t APIToken) error
+	DeleteTokens(ids []string) error
+}
+
+// openStore 按存储后端打开：SQLite 后端写入 users、sessions、api_tokens 表，
+// JSON 后端仍保存为 users.json、sessions.json、tokens.json 文档
+func openStore(backend storage.Backend) (Store, error) {
+	if db, ok := backend.(*storage.SQLiteBackend); ok {
+		return newSQLStore(db.DB(), backend)
+	}
+	return &docStore{backend: backend}, nil
+}
+
+// docStore 以整个文档保存的存储（JSON 后端），内存中保留一份副本用于按条修改
+type docStore struct {
+	mu       sync.Mutex
+	backend  storage.Backend
+	users    []User
+	sessions []Session
+	tokens   []APIToken
+}
+
+func (s *docStore) LoadUsers() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = nil
+	err := loadDocument(s.backend, storage.KeyUsers, &s.users)
+	return append([]User(nil), s.users...), err
+}
+
+func (s *docStore) SaveUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := upsertByID(s.users, u, func(u User) string { return u.ID })
+	if err := saveDocument(s.backend, storage.KeyUsers, users); err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+func (s *docStore) DeleteUser(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := removeByID(s.users, []string{id}, func(u User) string { return u.ID })
+	if err := saveDocument(s.backend, storage.KeyUsers, users); err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+func (s *docStore) LoadSessions() ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = nil
+	err := loadDocument(s.backend, storage.KeySessions, &s.sessions)
+	return append([]Session(nil), s.sessions...), err
+}
+
+func (s *docStore) SaveSession(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := upsertByID(s.sessions, session, func(s Session) string { return s.ID })
+	if err := saveDocument(s.backend, storage.KeySessions, sessions); err != nil {
+		return err
+	}
+	s.sessions = sessions
+	return nil
+}
+
+func (s *docStore) DeleteSessions(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := removeByID(s.sessions, ids, func(s Session) string { return s.ID })
+	if err := saveDocument(s.backend, storage.KeySessions, sessions); err != nil {
+		return err
+	}
+	s.sessions = sessions
+	return nil
+}
+
+func (s *docStore) LoadTokens() ([]APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = nil
+	err := loadDocument(s.backend, storage.KeyTokens, &s.tokens)
+	return append([]APIToken(nil), s.tokens...), err
+}
+
+func (s *docStore) SaveToken(t APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := upsertByID(s.tokens, t, func(t APIToken) string { return t.ID })
+	if err := saveDocument(s.backend, storage.KeyTokens, tokens); err != nil {
+		return err
+	}
+	s.tokens = tokens
+	return nil
+}
+
+func (s *docStore) DeleteTokens(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := removeByID(s.tokens, ids, func(t APIToken) string { return t.ID })
+	if err := saveDocument(s.backend, storage.KeyTokens, tokens); err != nil {
+		return err
+	}
+	s.tokens = tokens
+	return nil
+}
+
+// upsertByID 返回替换或追加 item 后的新列表，不修改原列表
+func upsertByID[T any](list []T, item T, id func(T) string) []T {
+	result := append([]T(nil), list...)
+	for i := range result {
+		if id(result[i]) == id(item) {
+			result[i] = item
+			return result
+		}
+	}
+	return append(result, item)
+}
+
+// removeByID 返回去掉指定 ID 后的新列表，不修改原列表
+func removeByID[T any](list []T, ids []string, id func(T) string) []T {
+	remove := make(map[string]bool, len(ids))
+	for _, v := range ids {
+		remove[v] = true
+	}
+	result := make([]T, 0, len(list))
+	for _, item := range list {
+		if !remove[id(item)] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func loadDocument(backend storage.Backend, key string, v interface{}) error {
+	data, err := backend.Load(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func saveDocument(backend storage.Backend, key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return backend.Save(key, data)
+}
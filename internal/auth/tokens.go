@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenPrefix API 令牌前缀，便于识别和密钥扫描
+const tokenPrefix = "hd_"
+
+// tokenUsageSaveInterval 最近使用时间的落盘间隔，避免每个请求都写一次存储
+const tokenUsageSaveInterval = time.Minute
+
+var ErrTokenNotFound = errors.New("令牌不存在")
+
+// APIToken 个人 API 令牌，只保存令牌的哈希
+type APIToken struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"userId"`
+	Name       string   `json:"name"`
+	Hint       string   `json:"hint"` // 令牌前几位，用于辨认
+	TokenHash  string   `json:"tokenHash"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"` // 0 表示永不过期
+	LastUsedAt int64    `json:"lastUsedAt,omitempty"`
+	LastUsedIP string   `json:"lastUsedIp,omitempty"`
+}
+
+// APITokenView 对外展示的令牌信息（不含哈希）
+type APITokenView struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"userId"`
+	Name       string   `json:"name"`
+	Hint       string   `json:"hint"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"`
+	LastUsedAt int64    `json:"lastUsedAt,omitempty"`
+	LastUsedIP string   `json:"lastUsedIp,omitempty"`
+	Expired    bool     `json:"expired"`
+}
+
+// View 转换为对外展示的令牌信息
+func (t APIToken) View() APITokenView {
+	return APITokenView{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		Scopes:     append([]string{}, t.Scopes...),
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		LastUsedIP: t.LastUsedIP,
+		Expired:    t.expired(time.Now()),
+	}
+}
+
+func (t APIToken) expired(now time.Time) bool {
+	return t.ExpiresAt > 0 && now.UnixMilli() >= t.ExpiresAt
+}
+
+// Allows 令牌范围是否包含指定访问（write 包含 read）
+func (t APIToken) Allows(area, access string) bool {
+	for _, s := range t.Scopes {
+		if s == Permission(area, access) || s == Permission(area, AccessWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIToken 判断字符串是否为 API 令牌格式
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, tokenPrefix)
+}
+
+// CreateToken 为用户创建 API 令牌，返回明文令牌（仅此一次）
+// ttl 为 0 表示永不过期；范围不能超出用户当前的权限
+func (m *Manager) CreateToken(userID, name string, scopes []string, ttl time.Duration) (string, APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 64 {
+		return "", APIToken{}, fmt.Errorf("令牌名称不能为空且不超过 64 个字符")
+	}
+	scopes, err := normalizePermissions(scopes)
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	if len(scopes) == 0 {
+		return "", APIToken{}, fmt.Errorf("至少需要一个权限范围")
+	}
+	if ttl < 0 {
+		return "", APIToken{}, fmt.Errorf("有效期无效")
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", APIToken{}, err
+	}
+	token := tokenPrefix + secret
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.userLocked(userID)
+	if !ok {
+		return "", APIToken{}, ErrUserNotFound
+	}
+	for _, s := range scopes {
+		area, access, _ := strings.Cut(s, ":")
+		if !user.Can(area, access) {
+			return "", APIToken{}, fmt.Errorf("权限范围 %s 超出当前用户的权限", s)
+		}
+	}
+
+	now := time.Now()
+	t := APIToken{
+		ID:        uuid.New().String()[:8],
+		UserID:    userID,
+		Name:      name,
+		Hint:      token[:len(tokenPrefix)+4],
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now.UnixMilli(),
+	}
+	if ttl > 0 {
+		t.ExpiresAt = now.Add(ttl).UnixMilli()
+	}
+	if err := m.store.SaveToken(t); err != nil {
+		return "", APIToken{}, err
+	}
+	m.tokens = append(append([]APIToken(nil), m.tokens...), t)
+	return token, t, nil
+}
+
+// Tokens 用户的 API 令牌列表，userID 为空时返回全部
+func (m *Manager) Tokens(userID string) []APIToken {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []APIToken
+	for _, t := range m.tokens {
+		if userID == "" || t.UserID == userID {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Token 按 ID 查找令牌
+func (m *Manager) Token(id string) (APIToken, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
+// RevokeToken 吊销令牌
+func (m *Manager) RevokeToken(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.removeTokensLocked(func(t APIToken) bool { return t.ID == id })
+}
+
+// removeTokensLocked 删除满足条件的令牌，没有匹配时返回 ErrTokenNotFound
+func (m *Manager) removeTokensLocked(match func(APIToken) bool) error {
+	tokens := make([]APIToken, 0, len(m.tokens))
+	var removed []string
+	for _, t := range m.tokens {
+		if match(t) {
+			removed = append(removed, t.ID)
+		} else {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(removed) == 0 {
+		return ErrTokenNotFound
+	}
+	if err := m.store.DeleteTokens(removed); err != nil {
+		return err
+	}
+	m.tokens = tokens
+	return nil
+}
+
+// TokenUser 校验 API 令牌，返回令牌及其所属用户，并记录最近使用时间和来源
+func (m *Manager) TokenUser(token, ip string) (APIToken, User, bool) {
+	if !IsAPIToken(token) {
+		return APIToken{}, User{}, false
+	}
+	hash := hashToken(token)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i := range m.tokens {
+		t := &m.tokens[i]
+		if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hash)) != 1 {
+			continue
+		}
+		if t.expired(now) {
+			return APIToken{}, User{}, false
+		}
+		user, ok := m.userLocked(t.UserID)
+		if !ok {
+			return APIToken{}, User{}, false
+		}
+
+		persist := now.Sub(time.UnixMilli(t.LastUsedAt)) >= tokenUsageSaveInterval || t.LastUsedIP != ip
+		t.LastUsedAt = now.UnixMilli()
+		t.LastUsedIP = ip
+		if persist {
+			m.store.SaveToken(*t)
+		}
+		return *t, user, true
+	}
+	return APIToken{}, User{}, false
+}
+
+// userLocked 按 ID 查找用户（调用方需持有锁）
+func (m *Manager) userLocked(id string) (User, bool) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return User{}, false
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP 参数（RFC 6238 默认值，兼容常见验证器应用）
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // 允许前后各一个时间窗口的时钟误差
+
+	recoveryCodeCount = 10
+	totpIssuer        = "HomeDash"
+)
+
+var (
+	ErrTOTPRequired   = errors.New("请输入两步验证码")
+	ErrInvalidTOTP    = errors.New("验证码错误")
+	ErrTOTPNotPending = errors.New("请先生成两步验证密钥")
+	ErrTOTPEnabled    = errors.New("两步验证已启用")
+	ErrTOTPDisabled   = errors.New("两步验证未启用")
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode 计算某个时间步的验证码
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// verifyTOTP 校验验证码，返回匹配的时间步；不接受不晚于 lastStep 的时间步以防重放
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newTOTPSecret 生成 160 位随机密钥（base32）
+func newTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32NoPad.EncodeToString(buf), nil
+}
+
+// totpURI 生成验证器应用可扫描的 otpauth:// 地址
+func totpURI(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("period", fmt.Sprint(totpPeriod))
+	q.Set("digits", fmt.Sprint(totpDigits))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// newRecoveryCodes 生成恢复码，返回明文和哈希
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32NoPad.EncodeToString(buf))
+		code := raw[:4] + "-" + raw[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写和空格，允许省略横线
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 8 && !strings.Contains(code, "-") {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+// TOTPSetup 两步验证的待确认密钥
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// BeginTOTP 生成待确认的两步验证密钥，需调用 EnableTOTP 确认后才生效
+func (m *Manager) BeginTOTP(userID string) (TOTPSetup, error) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+	var username string
+	err = m.updateUser(userID, func(u *User) error {
+		if u.TOTPEnabled {
+			return ErrTOTPEnabled
+		}
+		u.TOTPPending = secret
+		username = u.Username
+		return nil
+	})
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+	return TOTPSetup{Secret: secret, URI: totpURI(username, secret)}, nil
+}
+
+// EnableTOTP 用验证器应用生成的验证码确认并启用两步验证，返回恢复码（仅此一次）
+func (m *Manager) EnableTOTP(userID, code string) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	var username string
+	err = m.updateUser(userID, func(u *User) error {
+		if u.TOTPEnabled {
+			return ErrTOTPEnabled
+		}
+		if u.TOTPPending == "" {
+			return ErrTOTPNotPending
+		}
+		step, ok := verifyTOTP(u.TOTPPending, code, time.Now(), 0)
+		if !ok {
+			return ErrInvalidTOTP
+		}
+		u.TOTPSecret = u.TOTPPending
+		u.TOTPPending = ""
+		u.TOTPEnabled = true
+		u.TOTPLastStep = step
+		u.RecoveryCodes = hashes
+		username = u.Username
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.emit(Event{Type: EventTOTPEnabled, UserID: userID, Username: username})
+	return codes, nil
+}
+
+// DisableTOTP 关闭两步验证
+func (m *Manager) DisableTOTP(userID string) error {
+	var username string
+	err := m.updateUser(userID, func(u *User) error {
+		if !u.TOTPEnabled && u.TOTPPending == "" {
+			return ErrTOTPDisabled
+		}
+		u.TOTPEnabled = false
+		u.TOTPSecret = ""
+		u.TOTPPending = ""
+		u.TOTPLastStep = 0
+		u.RecoveryCodes = nil
+		username = u.Username
+		return nil
+	})
+	if err == nil {
+		m.emit(Event{Type: EventTOTPDisabled, UserID: userID, Username: username})
+	}
+	return err
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部失效
+func (m *Manager) RegenerateRecoveryCodes(userID string) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = m.updateUser(userID, func(u *User) error {
+		if !u.TOTPEnabled {
+			return ErrTOTPDisabled
+		}
+		u.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkSecondFactor 校验验证码或恢复码（恢复码使用后作废）
+func (m *Manager) checkSecondFactor(userID, code string) error {
+	if strings.TrimSpace(code) == "" {
+		return ErrTOTPRequired
+	}
+	return m.updateUser(userID, func(u *User) error {
+		if step, ok := verifyTOTP(u.TOTPSecret, code, time.Now(), u.TOTPLastStep); ok {
+			u.TOTPLastStep = step
+			return nil
+		}
+		hash := hashToken(normalizeRecoveryCode(code))
+		for i, h := range u.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				u.RecoveryCodes = append(append([]string(nil), u.RecoveryCodes[:i]...), u.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+		return ErrInvalidTOTP
+	})
+}
+
+// updateUser 修改用户并保存，fn 返回错误时不做任何修改
+func (m *Manager) updateUser(userID string, fn func(u *User) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := append([]User(nil), m.users...)
+	for i := range users {
+		if users[i].ID != userID {
+			continue
+		}
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+		users[i].UpdatedAt = time.Now().UnixMilli()
+		if err := m.store.SaveUser(users[i]); err != nil {
+			return err
+		}
+		m.users = users
+		return nil
+	}
+	return ErrUserNotFound
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"homedash/internal/storage"
+)
+
+// enableTestTOTP 为用户启用两步验证，返回保存的密钥
+func enableTestTOTP(t *testing.T, m *Manager, userID string) string {
+	t.Helper()
+	setup, err := m.BeginTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32NoPad.DecodeString(strings.ToUpper(setup.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EnableTOTP(userID, totpCode(key, time.Now().Unix()/totpPeriod)); err != nil {
+		t.Fatal(err)
+	}
+	return setup.Secret
+}
+
+// TestTOTPSecretFileMode 两步验证密钥与密码哈希保存在一起，保存它们的文件只能由运行 HomeDash 的账号读取
+func TestTOTPSecretFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不使用 Unix 权限位")
+	}
+
+	for _, kind := range []string{storage.KindJSON, storage.KindSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "data")
+			if err := storage.PrepareDataDir(dir); err != nil {
+				t.Fatal(err)
+			}
+			backend, err := storage.Open(kind, dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer backend.Close()
+			m, err := NewManager(backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			user, err := m.Setup("admin", "password123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret := enableTestTOTP(t, m, user.ID)
+
+			path := filepath.Join(dir, "users.json")
+			if kind == storage.KindSQLite {
+				path = filepath.Join(dir, "homedash.db")
+			} else {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(data), secret) {
+					t.Fatal("users.json 中没有两步验证密钥，测试未覆盖实际保存的文件")
+				}
+			}
+			for _, p := range []string{dir, path} {
+				info, err := os.Stat(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := storage.FilePerm
+				if info.IsDir() {
+					want = storage.DirPerm
+				}
+				if got := info.Mode().Perm(); got != want {
+					t.Errorf("%s 权限 = %o, want %o", filepath.Base(p), got, want)
+				}
+			}
+		})
+	}
+}
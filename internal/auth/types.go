@@ -0,0 +1,84 @@
+package auth
+
+import "time"
+
+// User 本地用户
+type User struct {
+	ID           string   `json:"id"`
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"passwordHash"` // bcrypt
+	Role         string   `json:"role"`
+	Permissions  []string `json:"permissions,omitempty"` // 仅自定义角色使用
+	CreatedAt    int64    `json:"createdAt"`
+
+	// 两步验证
+	TOTPEnabled   bool     `json:"totpEnabled,omitempty"`
+	TOTPSecret    string   `json:"totpSecret,omitempty"`
+	TOTPPending   string   `json:"totpPending,omitempty"`   // 已生成但尚未确认的密钥
+	TOTPLastStep  int64    `json:"totpLastStep,omitempty"`  // 最近使用的时间步，防止验证码重放
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"` // 恢复码哈希，使用后删除
+
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+// UserView 对外展示的用户信息（不含密码哈希）
+type UserView struct {
+	ID          string   `json:"id"`
+	Username    string   `json:"username"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"` // 实际拥有的权限
+	TOTPEnabled bool     `json:"totpEnabled"`
+	Recovery    int      `json:"recoveryCodesLeft"` // 剩余可用的恢复码数量
+	CreatedAt   int64    `json:"createdAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+}
+
+// View 转换为对外展示的用户信息
+func (u User) View() UserView {
+	return UserView{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		Permissions: u.EffectivePermissions(),
+		TOTPEnabled: u.TOTPEnabled,
+		Recovery:    len(u.RecoveryCodes),
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+}
+
+// Session 登录会话，只保存令牌的哈希
+type Session struct {
+	ID        string `json:"id"`        // 会话标识（用于列表和注销，不是令牌）
+	TokenHash string `json:"tokenHash"` // sha256(令牌)
+	CSRFToken string `json:"csrfToken"` // 双重提交校验用
+	UserID    string `json:"userId"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	CreatedAt int64  `json:"createdAt"`
+	LastSeen  int64  `json:"lastSeen"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// Config 认证配置
+type Config struct {
+	// PublicRoutes 无需登录即可访问的只读路由（仅 GET/HEAD），
+	// 如 "/api/services" 精确匹配，"/api/services/*" 匹配前缀
+	PublicRoutes []string `json:"publicRoutes"`
+	// SessionHours 会话有效期（小时），活跃使用时自动续期
+	SessionHours int `json:"sessionHours"`
+	// AllowedOrigins 除同源外允许建立 WebSocket 连接的来源，如反向代理的公网地址
+	// "https://home.example.com"
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+const defaultSessionHours = 7 * 24
+
+// sessionTTL 会话有效期
+func (c Config) sessionTTL() time.Duration {
+	hours := c.SessionHours
+	if hours <= 0 {
+		hours = defaultSessionHours
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wsTicketTTL WebSocket 票据的有效期，票据只能使用一次
+const wsTicketTTL = 30 * time.Second
+
+type wsTicket struct {
+	owner     string // 签发票据的凭据，如 "session:<ID>"、"token:<ID>"
+	expiresAt time.Time
+}
+
+// wsTicketStore 浏览器建立 WebSocket 连接前先通过 API（受 CSRF 保护）换取一次性票据，
+// 仅凭 Cookie 无法连接，防止其他网站发起跨站 WebSocket 劫持（仅内存，重启后失效）
+type wsTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]wsTicket // sha256(票据) -> 票据
+}
+
+func newWSTicketStore() *wsTicketStore {
+	return &wsTicketStore{tickets: make(map[string]wsTicket)}
+}
+
+// IssueWSTicket 为登录会话或 API 令牌签发 WebSocket 票据，owner 标识签发时使用的凭据
+func (m *Manager) IssueWSTicket(owner string) (string, error) {
+	ticket, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	s := m.wsTickets
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, t := range s.tickets {
+		if now.After(t.expiresAt) {
+			delete(s.tickets, hash)
+		}
+	}
+	s.tickets[hashToken(ticket)] = wsTicket{owner: owner, expiresAt: now.Add(wsTicketTTL)}
+	return ticket, nil
+}
+
+// ConsumeWSTicket 校验并作废票据，票据必须由同一凭据签发且未过期
+func (m *Manager) ConsumeWSTicket(ticket, owner string) bool {
+	if ticket == "" {
+		return false
+	}
+	hash := hashToken(ticket)
+	s := m.wsTickets
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[hash]
+	if !ok {
+		return false
+	}
+	delete(s.tickets, hash)
+	return t.owner == owner && time.Now().Before(t.expiresAt)
+}
+
+// normalizeOrigin 将 URL 规范为 scheme://host[:port] 形式，省略默认端口
+func normalizeOrigin(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("无效的来源地址: %s（示例: https://home.example.com）", raw)
+	}
+	host := strings.ToLower(u.Host)
+	if (u.Scheme == "http" && strings.HasSuffix(host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return u.Scheme + "://" + host, nil
+}
+
+// CheckOrigin 校验 WebSocket 握手的 Origin：允许同源和配置的允许来源。
+// 没有 Origin 头的请求不是浏览器发起的（如脚本），不存在跨站风险
+func (m *Manager) CheckOrigin(r *http.Request) bool {
+	raw := r.Header.Get("Origin")
+	if raw == "" {
+		return true
+	}
+	origin, err := normalizeOrigin(raw)
+	if err != nil {
+		return false
+	}
+
+	// 同源：Origin 的主机与请求的 Host 一致（忽略默认端口）
+	scheme, originHost, _ := strings.Cut(origin, "://")
+	host := strings.ToLower(r.Host)
+	if scheme == "https" {
+		host = strings.TrimSuffix(host, ":443")
+	} else {
+		host = strings.TrimSuffix(host, ":80")
+	}
+	if originHost == host {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, allowed := range m.config.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"homedash/internal/storage"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := NewManager(storage.NewJSONBackend(t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCheckOrigin(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.SetConfig(Config{AllowedOrigins: []string{"https://home.example.com:443"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   bool
+	}{
+		{"同源", "192.168.1.2:29678", "http://192.168.1.2:29678", true},
+		{"同源忽略默认端口", "nas.local", "http://nas.local:80", true},
+		{"同源主机名不区分大小写", "NAS.local:29678", "http://nas.LOCAL:29678", true},
+		{"允许的来源", "127.0.0.1:29678", "https://home.example.com", true},
+		{"允许的来源端口不同", "127.0.0.1:29678", "https://home.example.com:8443", false},
+		{"其他来源", "192.168.1.2:29678", "https://evil.example.com", false},
+		{"同主机不同端口", "192.168.1.2:29678", "http://192.168.1.2:8080", false},
+		{"无效的来源", "192.168.1.2:29678", "null", false},
+		{"没有 Origin", "192.168.1.2:29678", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws/monitor", nil)
+			r.Host = tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := m.CheckOrigin(r); got != tt.want {
+				t.Errorf("CheckOrigin(Host=%s, Origin=%s) = %v, want %v", tt.host, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWSTicket(t *testing.T) {
+	m := newTestManager(t)
+
+	ticket, err := m.IssueWSTicket("session:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ConsumeWSTicket(ticket, "session:b") {
+		t.Error("其他会话使用了票据")
+	}
+	// 校验失败的票据同样作废
+	if m.ConsumeWSTicket(ticket, "session:a") {
+		t.Error("被其他会话尝试过的票据仍然有效")
+	}
+
+	ticket, _ = m.IssueWSTicket("token:a")
+	if !m.ConsumeWSTicket(ticket, "token:a") {
+		t.Fatal("有效的票据被拒绝")
+	}
+	if m.ConsumeWSTicket(ticket, "token:a") {
+		t.Error("票据被重复使用")
+	}
+	if m.ConsumeWSTicket("", "token:a") {
+		t.Error("空票据被接受")
+	}
+
+	ticket, _ = m.IssueWSTicket("session:a")
+	m.wsTickets.mu.Lock()
+	for hash, entry := range m.wsTickets.tickets {
+		entry.expiresAt = time.Now().Add(-time.Second)
+		m.wsTickets.tickets[hash] = entry
+	}
+	m.wsTickets.mu.Unlock()
+	if m.ConsumeWSTicket(ticket, "session:a") {
+		t.Error("过期的票据被接受")
+	}
+}
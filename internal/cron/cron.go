@@ -0,0 +1,235 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的 cron 表达式（分 时 日 月 周）
+type Schedule struct {
+	expr   string
+	minute uint64 // 位图，第 n 位表示值 n
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// 日和周都被限制时，按标准 cron 语义取并集
+	domRestricted bool
+	dowRestricted bool
+}
+
+// 字段取值范围
+type fieldRange struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteRange = fieldRange{min: 0, max: 59}
+	hourRange   = fieldRange{min: 0, max: 23}
+	domRange    = fieldRange{min: 1, max: 31}
+	monthRange  = fieldRange{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowRange = fieldRange{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// 预定义表达式
+var aliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse 解析 5 段式 cron 表达式，支持 * , - / 以及月份、星期英文缩写和 @daily 等别名
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if alias, ok := aliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要 5 个字段（分 时 日 月 周）: %q", expr)
+	}
+
+	s := &Schedule{expr: strings.TrimSpace(expr)}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteRange); err != nil {
+		return nil, fmt.Errorf("分钟字段无效: %v", err)
+	}
+	if s.hour, err = parseField(fields[1], hourRange); err != nil {
+		return nil, fmt.Errorf("小时字段无效: %v", err)
+	}
+	if s.dom, err = parseField(fields[2], domRange); err != nil {
+		return nil, fmt.Errorf("日期字段无效: %v", err)
+	}
+	if s.month, err = parseField(fields[3], monthRange); err != nil {
+		return nil, fmt.Errorf("月份字段无效: %v", err)
+	}
+	if s.dow, err = parseField(fields[4], dowRange); err != nil {
+		return nil, fmt.Errorf("星期字段无效: %v", err)
+	}
+
+	// 7 和 0 都表示周日
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+		s.dow &^= 1 << 7
+	}
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// String 返回原始表达式
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parseField 解析单个字段为位图
+func parseField(field string, r fieldRange) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf("空的列表项")
+		}
+
+		step := 1
+		if base, stepStr, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长无效: %q", stepStr)
+			}
+			step = n
+			part = base
+		}
+
+		var lo, hi int
+		switch {
+		case part == "*":
+			lo, hi = r.min, r.max
+		case strings.Contains(part, "-"):
+			loStr, hiStr, _ := strings.Cut(part, "-")
+			var err error
+			if lo, err = parseValue(loStr, r); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, r); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("范围无效: %q", part)
+			}
+		default:
+			v, err := parseValue(part, r)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			// "5/15" 表示从 5 开始每 15 个单位
+			if step > 1 {
+				hi = r.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue 解析数值或英文缩写
+func parseValue(s string, r fieldRange) (int, error) {
+	if r.names != nil {
+		if v, ok := r.names[strings.ToLower(s)]; ok {
+			return v, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析 %q", s)
+	}
+	if v < r.min || v > r.max {
+		return 0, fmt.Errorf("%d 超出范围 %d-%d", v, r.min, r.max)
+	}
+	return v, nil
+}
+
+// Matches 判断时间（精确到分钟）是否命中表达式
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	return s.dayMatches(t)
+}
+
+// dayMatches 日期与星期的匹配，两者都被限制时满足其一即可
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+// Next 返回 t 之后下一次触发的时间，五年内无匹配时返回零值。
+// 按 t 所在时区的本地时间匹配：夏令时跳过的时刻当天不触发，回拨时重复的时刻只触发一次
+func (s *Schedule) Next(t time.Time) time.Time {
+	start := WallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !WallClock(t).After(start) {
+			// 夏令时回拨后本地时间倒退，跳过已经经过的时刻
+			t = t.Add(time.Minute)
+			continue
+		}
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance 跳到 next。next 落在夏令时跳过的时段时会被 time.Date 规范化，可能不晚于 t，
+// 此时改为前进一小时，保证循环推进
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
+
+// WallClock 本地时间（精确到分钟）按 UTC 表示，用于比较跨越夏令时切换的时刻
+func WallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
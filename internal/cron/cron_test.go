@@ -0,0 +1,206 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"*/5 0-6 1,15 jan-mar mon-fri", false},
+		{"5/15 * * * *", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{"@HOURLY", false},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"1,,2 * * * *", true},
+		{"a * * * *", true},
+		{"@reboot", true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		expr   string
+		minute uint64
+		dow    uint64
+	}{
+		{"0,30 * * * *", 1<<0 | 1<<30, 0x7f},
+		{"10-12 * * * *", 1<<10 | 1<<11 | 1<<12, 0x7f},
+		{"50/5 * * * *", 1<<50 | 1<<55, 0x7f},
+		{"0-10/5 * * * *", 1<<0 | 1<<5 | 1<<10, 0x7f},
+		{"0 * * * 7", 1, 1},
+		{"0 * * * sat,sun", 1, 1<<6 | 1},
+		{"0 * * * 5-7", 1, 1<<5 | 1<<6 | 1},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if s.minute != tt.minute {
+			t.Errorf("Parse(%q) minute = %b, want %b", tt.expr, s.minute, tt.minute)
+		}
+		if s.dow != tt.dow {
+			t.Errorf("Parse(%q) dow = %b, want %b", tt.expr, s.dow, tt.dow)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr string
+		time string
+		want bool
+	}{
+		{"* * * * *", "2024-06-01 12:34", true},
+		{"30 12 * * *", "2024-06-01 12:30", true},
+		{"30 12 * * *", "2024-06-01 12:31", false},
+		{"*/15 * * * *", "2024-06-01 12:45", true},
+		{"*/15 * * * *", "2024-06-01 12:46", false},
+		{"0 9-17 * * mon-fri", "2024-06-03 09:00", true},  // 周一
+		{"0 9-17 * * mon-fri", "2024-06-01 09:00", false}, // 周六
+		{"0 0 * feb *", "2024-02-10 00:00", true},
+		{"0 0 * feb *", "2024-03-10 00:00", false},
+		// 日和周都被限制时取并集：每月 13 日或每周五
+		{"0 0 13 * fri", "2024-09-13 00:00", true}, // 周五且 13 日
+		{"0 0 13 * fri", "2024-06-13 00:00", true}, // 周四，13 日
+		{"0 0 13 * fri", "2024-06-14 00:00", true}, // 周五，14 日
+		{"0 0 13 * fri", "2024-06-12 00:00", false},
+		// 只限制其一时按交集
+		{"0 0 13 * *", "2024-06-14 00:00", false},
+		{"0 0 * * fri", "2024-06-13 00:00", false},
+		// 以 * 开头的步长不算限制（与 vixie cron 一致），仍按交集
+		{"0 0 */2 * fri", "2024-06-14 00:00", false}, // 周五，14 日
+		{"0 0 */2 * fri", "2024-06-21 00:00", true},  // 周五，21 日
+		{"0 0 1-31/2 * fri", "2024-06-14 00:00", true},
+		{"0 0 29 2 *", "2024-02-29 00:00", true},
+		{"0 0 * * 0", "2024-06-02 00:00", true},
+		{"0 0 * * 7", "2024-06-02 00:00", true},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Matches(at(tt.time)); got != tt.want {
+			t.Errorf("%q Matches(%s) = %v, want %v", tt.expr, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-06-01 12:34:56", "2024-06-01 12:35"},
+		{"30 12 * * *", "2024-06-01 12:30:00", "2024-06-02 12:30"},
+		{"30 12 * * *", "2024-06-01 12:29:59", "2024-06-01 12:30"},
+		{"0 0 1 * *", "2024-12-15 08:00:00", "2025-01-01 00:00"},
+		{"0 0 * * mon", "2024-06-01 00:00:00", "2024-06-03 00:00"},
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00"},
+		{"0 0 13 * fri", "2024-06-01 00:00:00", "2024-06-07 00:00"},
+		{"*/20 9-10 * * *", "2024-06-01 10:45:00", "2024-06-02 09:00"},
+		// 2 月 29 日只在闰年出现
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00"},
+		{"0 0 29 2 *", "2023-06-01 00:00:00", "2024-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		from, _ := time.Parse("2006-01-02 15:04:05", tt.from)
+		want, _ := time.Parse("2006-01-02 15:04", tt.want)
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q Next(%s) = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestNextNoMatch(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want zero time", got)
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			// 2024-03-10 02:00 跳到 03:00，当天不存在 02:30
+			name: "spring forward skips missing time",
+			expr: "30 2 * * *",
+			from: time.Date(2024, 3, 10, 0, 0, 0, 0, loc),
+			want: time.Date(2024, 3, 11, 2, 30, 0, 0, loc),
+		},
+		{
+			name: "spring forward keeps later times",
+			expr: "30 3 * * *",
+			from: time.Date(2024, 3, 10, 0, 0, 0, 0, loc),
+			want: time.Date(2024, 3, 10, 3, 30, 0, 0, loc),
+		},
+		{
+			// 2024-11-03 02:00 回拨到 01:00，01:30 出现两次，只触发第一次
+			name: "fall back fires once",
+			expr: "30 1 * * *",
+			from: time.Date(2024, 11, 3, 1, 30, 0, 0, loc),
+			want: time.Date(2024, 11, 4, 1, 30, 0, 0, loc),
+		},
+		{
+			name: "fall back every minute continues after repeated hour",
+			expr: "0 * * * *",
+			from: time.Date(2024, 11, 3, 1, 0, 0, 0, loc),
+			want: time.Date(2024, 11, 3, 2, 0, 0, 0, loc),
+		},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.from, got, tt.want)
+		}
+	}
+}
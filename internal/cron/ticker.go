@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"sync"
+	"time"
+)
+
+// 整分钟时钟：任务调度器和服务定时任务共用同一个循环，保证同一分钟内看到相同的时刻
+
+var (
+	tickerMu   sync.Mutex
+	tickerFns  []func(now time.Time)
+	tickerOnce sync.Once
+)
+
+// EveryMinute 注册在每个整分钟调用的回调，首次注册时启动时钟。
+// 夏令时回拨后重复出现的本地时间不会再次触发，与 Next 的计算保持一致
+func EveryMinute(fn func(now time.Time)) {
+	tickerMu.Lock()
+	tickerFns = append(tickerFns, fn)
+	tickerMu.Unlock()
+
+	tickerOnce.Do(func() {
+		go runTicker()
+	})
+}
+
+// runTicker 对齐到整分钟调用所有回调，回调在各自的 goroutine 中执行，互不阻塞
+func runTicker() {
+	var last time.Time
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(time.Until(next))
+
+		wall := WallClock(next)
+		if !last.IsZero() && !wall.After(last) {
+			continue
+		}
+		last = wall
+
+		tickerMu.Lock()
+		fns := append([]func(now time.Time){}, tickerFns...)
+		tickerMu.Unlock()
+		for _, fn := range fns {
+			go fn(next)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"homedash/internal/audit"
+	"homedash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ctxAuditTarget = "auditTarget"
+
+var auditLog *audit.Log
+
+// InitAudit 初始化审计日志
+func InitAudit(l *audit.Log) {
+	auditLog = l
+}
+
+// auditActions 修改类接口对应的审计操作名，未列出的按「方法 路径」记录
+var auditActions = map[string]string{
+	"POST /api/auth/setup":                              "auth.setup",
+	"POST /api/auth/logout":                             "auth.logout",
+	"PUT /api/auth/password":                            "auth.password",
+	"PUT /api/auth/config":                              "auth.config",
+	"POST /api/auth/totp/setup":                         "totp.setup",
+	"POST /api/auth/totp/enable":                        "totp.enable",
+	"POST /api/auth/totp/disable":                       "totp.disable",
+	"POST /api/auth/totp/recovery-codes":                "totp.recovery-codes",
+	"POST /api/tokens":                                  "token.create",
+	"DELETE /api/tokens/:id":                            "token.revoke",
+	"POST /api/users":                                   "user.create",
+	"PUT /api/users/:id/password":                       "user.reset-password",
+	"PUT /api/users/:id/role":                           "user.role",
+	"DELETE /api/users/:id":                             "user.delete",
+	"DELETE /api/users/:id/totp":                        "user.reset-totp",
+	"POST /api/services":                                "service.create",
+	"PUT /api/services/:id":                             "service.update",
+	"DELETE /api/services/:id":                          "service.delete",
+	"POST /api/services/import-template":                "service.import",
+	"POST /api/services/:id/launch":                     "service.launch",
+	"POST /api/services/:id/stop":                       "service.stop",
+	"POST /api/services/:id/maintenance":                "service.maintenance",
+	"POST /api/services/:id/autostart":                  "service.autostart",
+	"PUT /api/terminal/sessions/:id":                    "terminal.rename",
+	"DELETE /api/terminal/sessions/:id":                 "terminal.kill",
+	"DELETE /api/terminal/recordings/:id":               "terminal.recording-delete",
+	"PUT /api/terminal/config":                          "terminal.config",
+	"PUT /api/webdav/config":                            "webdav.config",
+	"POST /api/ssh/hosts":                               "ssh.host-create",
+	"PUT /api/ssh/hosts/:id":                            "ssh.host-update",
+	"DELETE /api/ssh/hosts/:id":                         "ssh.host-delete",
+	"POST /api/ssh/hosts/:id/trust":                     "ssh.host-trust",
+	"POST /api/upload-icon":                             "service.upload-icon",
+	"POST /api/jobs":                                    "job.create",
+	"PUT /api/jobs/:id":                                 "job.update",
+	"DELETE /api/jobs/:id":                              "job.delete",
+	"POST /api/jobs/:id/run":                            "job.run",
+	"POST /api/job-runs/:runId/cancel":                  "job.cancel",
+	"POST /api/files/mkdir":                             "file.mkdir",
+	"DELETE /api/files":                                 "file.delete",
+	"POST /api/files/upload":                            "file.upload",
+	"POST /api/sftp/keys":                               "sftp.key-add",
+	"DELETE /api/sftp/keys/:id":                         "sftp.key-delete",
+	"POST /api/comfyui/config":                          "comfyui.config",
+	"POST /api/comfyui/workflow/execute":                "comfyui.execute",
+	"POST /api/logs/:service/clear":                     "logs.clear",
+	"POST /api/settings":                                "settings.update",
+	"POST /api/config/import":                           "config.import",
+	"POST /api/config/versions/:file/:version/rollback": "config.rollback",
+	"PUT /api/mounts":                                   "settings.mounts",
+	"POST /api/app-config":                              "settings.app-config",
+	"POST /api/app/restart":                             "app.restart",
+}
+
+// auditSkipped 不经中间件记录的请求：登录由认证事件记录（含失败原因和锁定）
+var auditSkipped = map[string]bool{
+	"POST /api/auth/login": true,
+}
+
+// auditIgnoredMethods WebDAV 客户端频繁发送的锁请求不记录
+var auditIgnoredMethods = map[string]bool{
+	"LOCK":   true,
+	"UNLOCK": true,
+}
+
+// AuditLog 审计中间件：记录全部修改类请求的操作者、来源、目标和结果
+// 需在 AuthRequired 之前注册，以便同时记录被拒绝的请求
+func AuditLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auditLog == nil || readMethods[c.Request.Method] || auditIgnoredMethods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		if auditSkipped[route] {
+			c.Next()
+			return
+		}
+		action, ok := auditActions[route]
+		if !ok {
+			if strings.HasPrefix(c.Request.URL.Path, "/webdav/") {
+				action = "webdav." + strings.ToLower(c.Request.Method)
+			} else {
+				action = strings.ToLower(c.Request.Method) + " " + c.Request.URL.Path
+			}
+		}
+		// 删除后就无法再查到名称，需在处理前确定目标
+		target := auditTarget(c)
+
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if v, ok := c.Get(ctxAuditTarget); ok {
+			target = v.(string)
+		}
+		entry := auditEntry(c, action, target)
+		entry.Status = w.Status()
+		switch {
+		case entry.Status == 401 || entry.Status == 403:
+			entry.Outcome = audit.OutcomeDenied
+		case entry.Status >= 400:
+			entry.Outcome = audit.OutcomeFailure
+		default:
+			entry.Outcome = audit.OutcomeSuccess
+		}
+		if entry.Status >= 400 {
+			entry.Detail = w.errorMessage()
+		}
+		auditLog.Record(entry)
+	}
+}
+
+// auditEntry 根据请求上下文填写操作者信息
+func auditEntry(c *gin.Context, action, target string) audit.Entry {
+	entry := audit.Entry{
+		IP:     c.ClientIP(),
+		Action: action,
+		Target: target,
+	}
+	if user, ok := currentUser(c); ok {
+		entry.Actor = user.Username
+		entry.ActorID = user.ID
+		entry.Via = "session"
+		if token, ok := currentToken(c); ok {
+			entry.Via = "token:" + token.Name
+		} else if via := c.GetString(ctxAuthVia); via != "" {
+			entry.Via = via
+		}
+	}
+	return entry
+}
+
+// auditTarget 推断操作对象：服务和用户显示名称，文件显示路径，其余显示路由参数
+func auditTarget(c *gin.Context) string {
+	if strings.HasPrefix(c.Request.URL.Path, "/webdav/") {
+		target := strings.TrimPrefix(c.Request.URL.Path, "/webdav")
+		if dest := c.GetHeader("Destination"); dest != "" {
+			target += " -> " + dest
+		}
+		return target
+	}
+
+	id := c.Param("id")
+	switch {
+	case id != "" && strings.HasPrefix(c.FullPath(), "/api/services/"):
+		if service, err := serviceStore.Get(id); err == nil {
+			return fmt.Sprintf("%s (%s)", service.Name, id)
+		}
+	case id != "" && strings.HasPrefix(c.FullPath(), "/api/users/") && authManager != nil:
+		if user, ok := authManager.User(id); ok {
+			return fmt.Sprintf("%s (%s)", user.Username, id)
+		}
+	case id != "" && strings.HasPrefix(c.FullPath(), "/api/jobs/") && jobScheduler != nil:
+		if job, ok := jobScheduler.Job(id); ok {
+			return fmt.Sprintf("%s (%s)", job.Name, id)
+		}
+	}
+	if p := c.Query("path"); p != "" {
+		return fileTarget(c.Query("mount"), p)
+	}
+
+	var params []string
+	for _, p := range c.Params {
+		params = append(params, p.Value)
+	}
+	return strings.Join(params, "/")
+}
+
+// setAuditTarget 由处理函数指定审计目标（目标在请求体中时使用）
+func setAuditTarget(c *gin.Context, target string) {
+	c.Set(ctxAuditTarget, target)
+}
+
+// recordAudit 记录非 HTTP 请求形式的操作（如终端命令）
+func recordAudit(c *gin.Context, action, target string, err error) {
+	entry := auditEntry(c, action, target)
+	entry.Outcome = audit.OutcomeSuccess
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Detail = err.Error()
+	}
+	auditLog.Record(entry)
+}
+
+// recordAuditDenied 记录被策略拒绝的操作
+func recordAuditDenied(c *gin.Context, action, target, reason string) {
+	entry := auditEntry(c, action, target)
+	entry.Outcome = audit.OutcomeDenied
+	entry.Detail = reason
+	auditLog.Record(entry)
+}
+
+// RecordAuthEvent 将认证事件（登录、锁定、两步验证变更）写入审计日志
+func RecordAuthEvent(e auth.Event) {
+	if e.Type == auth.EventLockout {
+		log.Printf("⚠ 登录失败次数过多: 用户 %q 来源 %s，%s", e.Username, e.IP, e.Detail)
+	}
+	entry := audit.Entry{
+		Time:    e.Time.UnixMilli(),
+		Actor:   e.Username,
+		ActorID: e.UserID,
+		IP:      e.IP,
+		Action:  e.Type,
+		Outcome: audit.OutcomeSuccess,
+		Detail:  e.Detail,
+	}
+	switch e.Type {
+	case auth.EventLoginFailed:
+		entry.Outcome = audit.OutcomeFailure
+	case auth.EventLockout:
+		entry.Outcome = audit.OutcomeDenied
+	}
+	auditLog.Record(entry)
+}
+
+// auditWriter 保留响应开头的一部分，用于提取失败原因
+type auditWriter struct {
+	gin.ResponseWriter
+	head []byte
+}
+
+const auditBodyLimit = 1024
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if n := auditBodyLimit - len(w.head); n > 0 {
+		if n > len(b) {
+			n = len(b)
+		}
+		w.head = append(w.head, b[:n]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// errorMessage 提取 {"error": "..."} 形式的错误信息
+func (w *auditWriter) errorMessage() string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(w.head, &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	return ""
+}
+
+// parseAuditFilter 解析查询参数
+func parseAuditFilter(c *gin.Context, defaultLimit, maxLimit int) (audit.Filter, error) {
+	f := audit.Filter{
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Target:  c.Query("target"),
+		Outcome: c.Query("outcome"),
+		IP:      c.Query("ip"),
+		Limit:   defaultLimit,
+	}
+	var err error
+	if f.From, err = parseAuditTime(c.Query("from")); err != nil {
+		return f, fmt.Errorf("from 参数无效: %v", err)
+	}
+	if f.To, err = parseAuditTime(c.Query("to")); err != nil {
+		return f, fmt.Errorf("to 参数无效: %v", err)
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return f, fmt.Errorf("limit 参数无效")
+		}
+		f.Limit = n
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+	return f, nil
+}
+
+// parseAuditTime 支持 RFC3339、日期（本地时区）和毫秒时间戳
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// GetAuditLog 查询审计日志
+// 参数：actor、action（前缀）、target（包含）、outcome、ip、from、to、limit
+func GetAuditLog(c *gin.Context) {
+	f, err := parseAuditFilter(c, 200, 5000)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := auditLog.Query(f)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "查询审计日志失败: " + err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	c.JSON(200, entries)
+}
+
+// ExportAuditLog 导出审计日志，format=csv 或 json（默认），筛选参数同查询接口
+func ExportAuditLog(c *gin.Context) {
+	f, err := parseAuditFilter(c, 100000, 100000)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(400, gin.H{"error": "format 只能是 json 或 csv"})
+		return
+	}
+	entries, err := auditLog.Query(f)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "查询审计日志失败: " + err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	filename := "homedash-audit-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		c.JSON(200, entries)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(200)
+	// UTF-8 BOM，便于 Excel 正确识别中文
+	c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"time", "actor", "actorId", "via", "ip", "action", "target", "outcome", "status", "detail"})
+	for _, e := range entries {
+		w.Write([]string{
+			time.UnixMilli(e.Time).Format(time.RFC3339),
+			csvSafe(e.Actor), e.ActorID, csvSafe(e.Via), e.IP, e.Action, csvSafe(e.Target), e.Outcome,
+			strconv.Itoa(e.Status), csvSafe(e.Detail),
+		})
+	}
+	w.Flush()
+}
+
+// csvSafe 防止文件名、命令等内容在表格软件中被当作公式执行
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
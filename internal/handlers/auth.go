@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"homedash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sessionCookie = "homedash_session" // 会话令牌，HttpOnly
+	csrfCookie    = "homedash_csrf"    // CSRF 令牌，供前端读取后放入请求头
+	csrfHeader    = "X-CSRF-Token"
+
+	ctxUser    = "authUser"
+	ctxSession = "authSession"
+	ctxAuthVia = "authVia" // 非会话、非令牌的认证方式（如 WebDAV Basic 认证），用于审计
+)
+
+var authManager *auth.Manager
+
+// InitAuth 初始化认证
+func InitAuth(m *auth.Manager) {
+	authManager = m
+}
+
+// requireAuth 检查认证是否已初始化
+func requireAuth(c *gin.Context) bool {
+	if authManager == nil {
+		c.JSON(503, gin.H{"error": "认证未初始化"})
+		return false
+	}
+	return true
+}
+
+// authExemptPaths 无需登录即可访问的路径（登录流程本身）
+var authExemptPaths = map[string]bool{
+	"/login":           true,
+	"/setup":           true,
+	"/api/auth/status": true,
+	"/api/auth/login":  true,
+	"/api/auth/setup":  true,
+}
+
+// privateStaticFiles web 目录下不允许通过 /static 访问的文件
+var privateStaticFiles = map[string]bool{
+	"settings.json": true,
+	"services.json": true,
+}
+
+// AuthRequired 认证中间件：保护页面、/api、/ws 和 /webdav，并对修改类请求校验 CSRF 令牌
+// 脚本可使用 Authorization: Bearer <API 令牌> 代替登录会话，WebDAV 客户端使用 Basic 认证
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := c.Request.URL.Path
+
+		// 配置文件位于 web 目录，禁止作为静态文件下载
+		if strings.HasPrefix(p, "/static/") {
+			name := path.Base(p)
+			if privateStaticFiles[name] || strings.HasPrefix(name, ".") {
+				c.AbortWithStatus(404)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if authManager == nil {
+			c.Next()
+			return
+		}
+
+		// API 令牌不依赖 Cookie，无需 CSRF 校验；令牌无效时直接拒绝，不回退到会话
+		if bearer, ok := bearerToken(c.Request); ok {
+			apiToken, user, valid := authManager.TokenUser(bearer, c.ClientIP())
+			if !valid {
+				c.AbortWithStatusJSON(401, gin.H{"error": "API 令牌无效或已过期"})
+				return
+			}
+			c.Set(ctxUser, user)
+			c.Set(ctxToken, apiToken)
+			c.Next()
+			return
+		}
+
+		// Basic 认证同样不依赖 Cookie，无需 CSRF 校验
+		if strings.HasPrefix(p, "/webdav/") {
+			if username, password, ok := c.Request.BasicAuth(); ok {
+				if webdavBasicAuth(c, username, password) {
+					c.Next()
+				}
+				return
+			}
+		}
+
+		token, _ := c.Cookie(sessionCookie)
+		session, user, ok := authManager.Session(token)
+		if ok {
+			c.Set(ctxUser, user)
+			c.Set(ctxSession, session)
+		}
+
+		if authExemptPaths[p] {
+			c.Next()
+			return
+		}
+
+		if !ok {
+			if authManager.IsPublic(c.Request.Method, p) {
+				c.Next()
+				return
+			}
+			rejectUnauthenticated(c)
+			return
+		}
+
+		if requiresCSRF(c.Request) && !auth.ValidCSRF(session, c.GetHeader(csrfHeader)) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "CSRF 校验失败，请刷新页面后重试"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// isAPIPath 接口类路径未登录时返回 401，页面路径则跳转到登录页
+func isAPIPath(p string) bool {
+	return strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/ws/") || strings.HasPrefix(p, "/webdav/")
+}
+
+// rejectUnauthenticated 拒绝未登录的请求
+func rejectUnauthenticated(c *gin.Context) {
+	setupRequired := authManager.NeedsSetup()
+	if strings.HasPrefix(c.Request.URL.Path, "/webdav/") && !setupRequired {
+		c.Header("WWW-Authenticate", webdavRealm)
+	}
+	if isAPIPath(c.Request.URL.Path) {
+		c.AbortWithStatusJSON(401, gin.H{"error": "未登录", "setupRequired": setupRequired})
+		return
+	}
+
+	target := "/login"
+	if setupRequired {
+		target = "/setup"
+	}
+	if c.Request.URL.Path != "/" {
+		target += "?next=" + url.QueryEscape(c.Request.URL.RequestURI())
+	}
+	c.Redirect(http.StatusFound, target)
+	c.Abort()
+}
+
+// requiresCSRF 修改类请求需要 CSRF 令牌
+// WebDAV 客户端无法携带自定义请求头，其 PUT/DELETE 等方法跨站请求会被浏览器预检拦截，只需校验 POST
+func requiresCSRF(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/webdav/") {
+		return r.Method == "POST"
+	}
+	return true
+}
+
+// currentUser 获取当前登录用户
+func currentUser(c *gin.Context) (auth.User, bool) {
+	v, ok := c.Get(ctxUser)
+	if !ok {
+		return auth.User{}, false
+	}
+	user, ok := v.(auth.User)
+	return user, ok
+}
+
+// currentSession 获取当前会话
+func currentSession(c *gin.Context) (auth.Session, bool) {
+	v, ok := c.Get(ctxSession)
+	if !ok {
+		return auth.Session{}, false
+	}
+	session, ok := v.(auth.Session)
+	return session, ok
+}
+
+// setSessionCookies 写入会话和 CSRF Cookie
+func setSessionCookies(c *gin.Context, token string, session auth.Session) {
+	secure := c.Request.TLS != nil
+	maxAge := int((session.ExpiresAt - session.CreatedAt) / 1000)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookies 清除会话 Cookie
+func clearSessionCookies(c *gin.Context) {
+	for _, name := range []string{sessionCookie, csrfCookie} {
+		http.SetCookie(c.Writer, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1})
+	}
+}
+
+// startSession 为用户创建会话并返回登录结果
+func startSession(c *gin.Context, user auth.User) {
+	token, session, err := authManager.CreateSession(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "创建会话失败: " + err.Error()})
+		return
+	}
+	setSessionCookies(c, token, session)
+	c.JSON(200, gin.H{"user": user.View(), "csrfToken": session.CSRFToken})
+}
+
+// credentials 登录和初始化请求
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginPage 登录页 / 首次运行初始化页
+func LoginPage(c *gin.Context) {
+	if authManager == nil {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	if _, ok := currentUser(c); ok {
+		c.Redirect(http.StatusFound, safeNext(c.Query("next")))
+		return
+	}
+
+	setup := authManager.NeedsSetup()
+	target := "/login"
+	if setup {
+		target = "/setup"
+	}
+	if c.Request.URL.Path != target {
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+	c.HTML(200, "login.html", gin.H{"Setup": setup, "Next": safeNext(c.Query("next"))})
+}
+
+// safeNext 只允许跳转到站内路径
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// GetAuthStatus 获取登录状态
+func GetAuthStatus(c *gin.Context) {
+	if authManager == nil {
+		c.JSON(200, gin.H{"enabled": false, "authenticated": true})
+		return
+	}
+	result := gin.H{
+		"enabled":       true,
+		"setupRequired": authManager.NeedsSetup(),
+		"authenticated": false,
+	}
+	if user, ok := currentUser(c); ok {
+		session, _ := currentSession(c)
+		result["authenticated"] = true
+		result["user"] = user.View()
+		result["csrfToken"] = session.CSRFToken
+	}
+	c.JSON(200, result)
+}
+
+// SetupAuth 首次运行时创建管理员账号并登录
+func SetupAuth(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req credentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, err := authManager.Setup(req.Username, req.Password)
+	if errors.Is(err, auth.ErrSetupDone) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	startSession(c, user)
+}
+
+// Login 用户名密码登录，启用两步验证时还需验证码；连续失败会按 IP 和用户名退避
+func Login(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		credentials
+		Code string `json:"code"` // 两步验证码或恢复码
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, err := authManager.Login(req.Username, req.Password, req.Code, c.ClientIP())
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(locked.RetryAfter()))
+		c.JSON(429, gin.H{"error": err.Error(), "retryAfter": locked.RetryAfter()})
+	case errors.Is(err, auth.ErrTOTPRequired):
+		c.JSON(401, gin.H{"error": err.Error(), "totpRequired": true})
+	case err != nil:
+		c.JSON(401, gin.H{"error": err.Error()})
+	default:
+		startSession(c, user)
+	}
+}
+
+// Logout 注销当前会话
+func Logout(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	if session, ok := currentSession(c); ok {
+		authManager.DeleteSession(session.ID)
+	}
+	clearSessionCookies(c)
+	c.JSON(200, gin.H{"success": true})
+}
+
+// ChangePassword 修改当前用户密码（同时注销其他设备上的会话）
+func ChangePassword(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, _ := currentUser(c)
+	session, _ := currentSession(c)
+	if _, err := authManager.Authenticate(user.Username, req.OldPassword); err != nil {
+		c.JSON(400, gin.H{"error": "原密码错误"})
+		return
+	}
+	if err := authManager.SetPassword(user.ID, req.NewPassword, session.ID); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// GetUsers 获取用户列表
+func GetUsers(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	users := authManager.Users()
+	result := make([]auth.UserView, 0, len(users))
+	for _, u := range users {
+		result = append(result, u.View())
+	}
+	c.JSON(200, result)
+}
+
+// CreateUser 创建用户（未指定角色时为只读访客）
+func CreateUser(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		credentials
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleViewer
+	}
+	user, err := authManager.CreateUser(req.Username, req.Password, req.Role, req.Permissions)
+	if errors.Is(err, auth.ErrUserExists) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, user.View())
+}
+
+// ResetUserPassword 重置用户密码（注销该用户的全部会话）
+func ResetUserPassword(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	err := authManager.SetPassword(c.Param("id"), req.Password, "")
+	if errors.Is(err, auth.ErrUserNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// DeleteUser 删除用户
+func DeleteUser(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	err := authManager.DeleteUser(c.Param("id"))
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrLastUser), errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": "删除失败: " + err.Error()})
+	default:
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// GetAuthConfig 获取认证配置
+func GetAuthConfig(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	c.JSON(200, authManager.Config())
+}
+
+// UpdateAuthConfig 更新认证配置（公开路由、会话有效期）
+func UpdateAuthConfig(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var cfg auth.Config
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := authManager.SetConfig(cfg); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, authManager.Config())
+}
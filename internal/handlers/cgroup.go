@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	cgroupMountPoint  = "/sys/fs/cgroup"
+	cgroupServicesDir = "homedash-services" // 所有服务 cgroup 的父目录
+	cgroupSelfLeaf    = "homedash-main"     // HomeDash 自身迁入的叶子 cgroup
+	cgroupCPUPeriod   = 100000              // cpu.max 周期（微秒）
+)
+
+var (
+	cgroupMu       sync.Mutex
+	cgroupBase     string // 服务 cgroup 父目录（初始化成功后设置）
+	cgroupInitErr  error
+	cgroupInitDone bool
+)
+
+// cgroupDirName 服务对应的 cgroup 目录名
+func cgroupDirName(serviceID string) string {
+	return "svc-" + strings.Map(func(r rune) rune {
+		if r == '/' || r == '.' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, serviceID)
+}
+
+// ensureCgroupBase 初始化服务 cgroup 父目录并启用控制器
+func ensureCgroupBase() (string, error) {
+	cgroupMu.Lock()
+	defer cgroupMu.Unlock()
+
+	if cgroupInitDone {
+		return cgroupBase, cgroupInitErr
+	}
+	cgroupInitDone = true
+	cgroupBase, cgroupInitErr = initCgroupBase()
+	return cgroupBase, cgroupInitErr
+}
+
+// initCgroupBase 在 HomeDash 自身所在的 cgroup 下创建服务父目录
+func initCgroupBase() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("资源限制仅支持 Linux cgroup v2")
+	}
+	if _, err := os.Stat(filepath.Join(cgroupMountPoint, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("未检测到 cgroup v2 挂载点 %s", cgroupMountPoint)
+	}
+
+	selfPath, err := selfCgroupPath()
+	if err != nil {
+		return "", err
+	}
+	own := filepath.Join(cgroupMountPoint, selfPath)
+
+	// cgroup v2 不允许非根 cgroup 同时包含进程和启用子树控制器，
+	// 因此需要先把 HomeDash 自身迁入叶子 cgroup
+	controllers := readCgroupControllers(filepath.Join(own, "cgroup.controllers"))
+	if len(controllers) == 0 {
+		return "", fmt.Errorf("当前 cgroup 未委派任何控制器: %s", own)
+	}
+	if err := enableSubtreeControllers(own, controllers); err != nil {
+		if !errors.Is(err, syscall.EBUSY) {
+			return "", fmt.Errorf("cgroup 不可写: %v", err)
+		}
+		leaf := filepath.Join(own, cgroupSelfLeaf)
+		if err := os.MkdirAll(leaf, 0755); err != nil {
+			return "", fmt.Errorf("创建 cgroup 失败: %v", err)
+		}
+		if err := moveProcsToCgroup(own, leaf); err != nil {
+			return "", fmt.Errorf("迁移进程到叶子 cgroup 失败: %v", err)
+		}
+		if err := enableSubtreeControllers(own, controllers); err != nil {
+			return "", fmt.Errorf("启用 cgroup 控制器失败: %v", err)
+		}
+	}
+
+	base := filepath.Join(own, cgroupServicesDir)
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return "", fmt.Errorf("创建 cgroup 失败: %v", err)
+	}
+	if err := enableSubtreeControllers(base, controllers); err != nil {
+		return "", fmt.Errorf("启用 cgroup 控制器失败: %v", err)
+	}
+	return base, nil
+}
+
+// selfCgroupPath 读取当前进程的 cgroup v2 路径
+func selfCgroupPath() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("读取 /proc/self/cgroup 失败: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// cgroup v2 的格式为 "0::/path"
+		line := scanner.Text()
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", fmt.Errorf("当前进程不在 cgroup v2 层级中")
+}
+
+// readCgroupControllers 读取可用控制器，只保留需要的几个
+func readCgroupControllers(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	wanted := map[string]bool{"cpu": true, "memory": true, "io": true, "pids": true}
+	var result []string
+	for _, name := range strings.Fields(string(data)) {
+		if wanted[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// enableSubtreeControllers 在指定 cgroup 上为子目录启用控制器
+func enableSubtreeControllers(dir string, controllers []string) error {
+	parts := make([]string, 0, len(controllers))
+	for _, name := range controllers {
+		parts = append(parts, "+"+name)
+	}
+	return os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(parts, " ")), 0644)
+}
+
+// moveProcsToCgroup 把 from 中的所有进程迁移到 to
+func moveProcsToCgroup(from, to string) error {
+	data, err := os.ReadFile(filepath.Join(from, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	for _, pid := range strings.Fields(string(data)) {
+		if err := os.WriteFile(filepath.Join(to, "cgroup.procs"), []byte(pid), 0644); err != nil {
+			// 进程可能已退出
+			if errors.Is(err, syscall.ESRCH) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareServiceCgroup 为服务创建 cgroup 并写入限制，返回 cgroup 目录和未委派的控制器
+func prepareServiceCgroup(serviceID string, limits ResourceLimits) (string, []string, error) {
+	base, err := ensureCgroupBase()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := filepath.Join(base, cgroupDirName(serviceID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, fmt.Errorf("创建服务 cgroup 失败: %v", err)
+	}
+	skipped, err := writeCgroupLimits(dir, limits)
+	return dir, skipped, err
+}
+
+// addProcToCgroup 把已启动的进程放入 cgroup
+func addProcToCgroup(dir string, pid int) error {
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("放入进程失败: %v", err)
+	}
+	return nil
+}
+
+// cgroupLimitFile 一项限制对应的 cgroup 文件
+type cgroupLimitFile struct {
+	controller string
+	name       string
+	value      string
+	requested  bool // 是否配置了该限制（未配置时写入不限制的值）
+}
+
+// cgroupLimitFiles 资源限制对应的 cgroup 文件，零值恢复为不限制
+func cgroupLimitFiles(limits ResourceLimits) []cgroupLimitFile {
+	cpuMax := "max"
+	if limits.CPUQuota > 0 {
+		cpuMax = strconv.Itoa(int(limits.CPUQuota * cgroupCPUPeriod))
+	}
+	memoryMax := "max"
+	if limits.MemoryMax > 0 {
+		memoryMax = strconv.FormatInt(limits.MemoryMax, 10)
+	}
+	pidsMax := "max"
+	if limits.PidsMax > 0 {
+		pidsMax = strconv.Itoa(limits.PidsMax)
+	}
+	ioWeight := 100
+	if limits.IOWeight > 0 {
+		ioWeight = limits.IOWeight
+	}
+
+	return []cgroupLimitFile{
+		{"cpu", "cpu.max", fmt.Sprintf("%s %d", cpuMax, cgroupCPUPeriod), limits.CPUQuota > 0},
+		{"memory", "memory.max", memoryMax, limits.MemoryMax > 0},
+		{"io", "io.weight", fmt.Sprintf("default %d", ioWeight), limits.IOWeight > 0},
+		{"pids", "pids.max", pidsMax, limits.PidsMax > 0},
+	}
+}
+
+// writeCgroupLimits 写入资源限制，返回配置了限制但未委派（文件不存在）的控制器
+func writeCgroupLimits(dir string, limits ResourceLimits) ([]string, error) {
+	var skipped, failed []string
+	for _, f := range cgroupLimitFiles(limits) {
+		path := filepath.Join(dir, f.name)
+		if _, err := os.Stat(path); err != nil {
+			// 控制器未委派时文件不存在，未配置的限制可以忽略
+			if f.requested {
+				skipped = append(skipped, f.controller)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(f.value), 0644); err != nil {
+			failed = append(failed, f.name)
+		}
+	}
+	if len(failed) > 0 {
+		return skipped, fmt.Errorf("写入限制失败: %s", strings.Join(failed, ", "))
+	}
+	return skipped, nil
+}
+
+// missingCgroupControllers 配置了限制但 cgroup 中没有对应文件的控制器
+func missingCgroupControllers(dir string, limits ResourceLimits) []string {
+	var missing []string
+	for _, f := range cgroupLimitFiles(limits) {
+		if _, err := os.Stat(filepath.Join(dir, f.name)); err != nil && f.requested {
+			missing = append(missing, f.controller)
+		}
+	}
+	return missing
+}
+
+// skippedControllersMessage 未委派控制器的说明
+func skippedControllersMessage(skipped []string) string {
+	return "以下控制器未委派，对应限制未生效: " + strings.Join(skipped, ", ")
+}
+
+// getServiceCgroupStatus 读取服务 cgroup 的限制与当前占用
+func getServiceCgroupStatus(serviceID string, limits ResourceLimits, pid int32) *CgroupStatus {
+	status := &CgroupStatus{Limits: limits}
+
+	base, err := ensureCgroupBase()
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	status.Available = true
+
+	dir := filepath.Join(base, cgroupDirName(serviceID))
+	if _, err := os.Stat(dir); err != nil {
+		status.Message = "服务尚未通过 HomeDash 启动，未应用资源限制"
+		return status
+	}
+	status.Path = dir
+
+	status.Skipped = missingCgroupControllers(dir, limits)
+	if pid > 0 {
+		inCgroup := cgroupContainsPid(dir, pid)
+		status.Applied = inCgroup && len(status.Skipped) == 0
+		if !inCgroup {
+			status.Message = "进程不在服务 cgroup 中（可能由外部启动）"
+		} else if len(status.Skipped) > 0 {
+			status.Message = skippedControllersMessage(status.Skipped)
+		}
+	}
+
+	status.Usage = readCgroupUsage(dir)
+	return status
+}
+
+// cgroupContainsPid 检查进程是否在 cgroup 中
+func cgroupContainsPid(dir string, pid int32) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return false
+	}
+	target := strconv.Itoa(int(pid))
+	for _, p := range strings.Fields(string(data)) {
+		if p == target {
+			return true
+		}
+	}
+	return false
+}
+
+// readCgroupUsage 读取 cgroup 当前资源占用
+func readCgroupUsage(dir string) ResourceUsage {
+	usage := ResourceUsage{
+		MemoryCurrent: readCgroupUint(filepath.Join(dir, "memory.current")),
+		PidsCurrent:   readCgroupUint(filepath.Join(dir, "pids.current")),
+	}
+
+	if stat := readCgroupKeyValues(filepath.Join(dir, "cpu.stat")); stat != nil {
+		usage.CPUUsageUsec = stat["usage_usec"]
+	}
+
+	// io.stat 每行一个设备: "8:0 rbytes=1 wbytes=2 ..."
+	if data, err := os.ReadFile(filepath.Join(dir, "io.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			for _, field := range strings.Fields(line) {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					continue
+				}
+				n, _ := strconv.ParseUint(value, 10, 64)
+				switch key {
+				case "rbytes":
+					usage.IOReadBytes += n
+				case "wbytes":
+					usage.IOWriteBytes += n
+				}
+			}
+		}
+	}
+
+	return usage
+}
+
+// readCgroupUint 读取单值 cgroup 文件
+func readCgroupUint(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+// readCgroupKeyValues 读取 "key value" 格式的 cgroup 文件
+func readCgroupKeyValues(path string) map[string]uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	result := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err == nil {
+			result[fields[0]] = n
+		}
+	}
+	return result
+}
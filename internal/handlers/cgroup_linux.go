@@ -0,0 +1,25 @@
+//go:build linux
+
+package handlers
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// startInCgroup 通过 CLONE_INTO_CGROUP 让进程从创建起就位于 cgroup 中（需要 Linux 5.7+）
+func startInCgroup(cmd *exec.Cmd, dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(f.Fd())
+	return cmd.Start()
+}
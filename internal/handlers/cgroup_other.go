@@ -0,0 +1,13 @@
+//go:build !linux
+
+package handlers
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// startInCgroup 资源限制仅支持 Linux cgroup v2
+func startInCgroup(cmd *exec.Cmd, dir string) error {
+	return errors.New("资源限制仅支持 Linux cgroup v2")
+}
@@ -30,7 +30,10 @@ func UpdateComfyUIConfig(c *gin.Context) {
 
 	settings := loadSettings()
 	settings.ComfyUIServerURL = config.ServerURL
-	saveSettings(settings)
+	if err := saveSettings(settings); err != nil {
+		respondSaveError(c, "保存设置失败", err)
+		return
+	}
 
 	c.JSON(200, gin.H{"success": true})
 }
@@ -0,0 +1,705 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"homedash/internal/scheduler"
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	configManifestName    = "manifest.json"
+	configManifestVersion = 1
+	configArchiveMaxSize  = 100 * 1024 * 1024 // 导入归档最大 100MB
+	configEntryMaxSize    = 20 * 1024 * 1024  // 单个文件最大 20MB
+	configTotalMaxSize    = 200 * 1024 * 1024 // 全部文件解压后最大 200MB
+	configMaxEntries      = 2000              // 归档最多包含的条目数
+)
+
+// 归档中允许的资源目录及扩展名
+var configAssetDirs = map[string]map[string]bool{
+	"icons":       {".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".ico": true, ".svg": true},
+	"backgrounds": {".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true},
+}
+
+// ConfigManifest 配置归档清单
+type ConfigManifest struct {
+	Version    int             `json:"version"`
+	ExportedAt int64           `json:"exportedAt"`
+	Services   []ServiceCard   `json:"services"`
+	Settings   UserSettings    `json:"settings"`
+	Jobs       []scheduler.Job `json:"jobs,omitempty"`
+	Files      []string        `json:"files"` // 归档中的图标和背景图（相对路径）
+}
+
+// ConfigImportPlan 导入计划（dry-run 时只返回计划不写入）
+type ConfigImportPlan struct {
+	Mode     string              `json:"mode"`     // "merge" | "replace"
+	Conflict string              `json:"conflict"` // ID 冲突处理 "overwrite" | "skip" | "rename"
+	DryRun   bool                `json:"dryRun"`
+	Applied  bool                `json:"applied"`
+	Services []ConfigItemChange  `json:"services"`
+	Jobs     []ConfigItemChange  `json:"jobs"`
+	Settings []ConfigFieldChange `json:"settings"`
+	Files    []ConfigFileChange  `json:"files"`
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+// ConfigItemChange 服务或任务的变更
+type ConfigItemChange struct {
+	ID     string              `json:"id"`
+	Name   string              `json:"name"`
+	Action string              `json:"action"` // add | update | unchanged | skip | rename | remove
+	NewID  string              `json:"newId,omitempty"`
+	Fields []ConfigFieldChange `json:"fields,omitempty"`
+}
+
+// ConfigFieldChange 字段变更
+type ConfigFieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ConfigFileChange 资源文件变更
+type ConfigFileChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // add | overwrite | unchanged
+}
+
+// ExportConfig 导出服务、设置、任务及图标和背景图为 zip 归档
+func ExportConfig(c *gin.Context) {
+	manifest := ConfigManifest{
+		Version:    configManifestVersion,
+		ExportedAt: time.Now().UnixMilli(),
+		Services:   loadServices(),
+		Settings:   loadSettings(),
+		Files:      []string{},
+	}
+	if jobScheduler != nil {
+		manifest.Jobs = jobScheduler.Jobs()
+	}
+
+	// 收集资源文件
+	for dir, exts := range configAssetDirs {
+		entries, err := os.ReadDir(filepath.Join(webDir, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !exts[strings.ToLower(filepath.Ext(entry.Name()))] {
+				continue
+			}
+			manifest.Files = append(manifest.Files, dir+"/"+entry.Name())
+		}
+	}
+	sort.Strings(manifest.Files)
+
+	filename := fmt.Sprintf("homedash-config-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		c.Status(500)
+		return
+	}
+	w, err := zw.Create(configManifestName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, name := range manifest.Files {
+		if err := addFileToZip(zw, name, filepath.Join(webDir, filepath.FromSlash(name))); err != nil {
+			// 响应已开始写入，只能中止
+			c.Error(err)
+			return
+		}
+	}
+}
+
+// addFileToZip 把文件写入归档
+func addFileToZip(zw *zip.Writer, name, fullPath string) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// ImportConfig 导入配置归档，支持 dry-run、合并/替换模式及 ID 冲突处理
+func ImportConfig(c *gin.Context) {
+	mode := formOrQuery(c, "mode", "merge")
+	conflict := formOrQuery(c, "conflict", "overwrite")
+	dryRun := formOrQuery(c, "dryRun", "false") == "true"
+
+	if mode != "merge" && mode != "replace" {
+		c.JSON(400, gin.H{"error": "mode 必须是 merge 或 replace"})
+		return
+	}
+	if conflict != "overwrite" && conflict != "skip" && conflict != "rename" {
+		c.JSON(400, gin.H{"error": "conflict 必须是 overwrite、skip 或 rename"})
+		return
+	}
+
+	file, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "未找到上传文件"})
+		return
+	}
+	if file.Size > configArchiveMaxSize {
+		c.JSON(400, gin.H{"error": "归档过大，最大 100MB"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(500, gin.H{"error": "打开文件失败"})
+		return
+	}
+	defer f.Close()
+
+	zr, err := zip.NewReader(f, file.Size)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的 zip 归档"})
+		return
+	}
+
+	manifest, assets, err := readConfigArchive(zr)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, services, settings, jobs := planConfigImport(manifest, assets, mode, conflict)
+	plan.DryRun = dryRun
+	if len(plan.Errors) > 0 {
+		c.JSON(422, plan)
+		return
+	}
+	if dryRun {
+		c.JSON(200, plan)
+		return
+	}
+
+	if err := importCommandAccess(c, services, jobs); err != nil {
+		respondCommandDenied(c, err)
+		return
+	}
+	if err := applyConfigImport(plan, services, settings, jobs, assets); err != nil {
+		c.JSON(500, gin.H{"error": "导入失败: " + err.Error(), "plan": plan})
+		return
+	}
+	plan.Applied = true
+	c.JSON(200, plan)
+}
+
+// importCommandAccess 导入会新增或修改服务启动命令、任务命令时，逐条校验终端权限和命令策略
+func importCommandAccess(c *gin.Context, services []ServiceCard, jobs []scheduler.Job) error {
+	current := make(map[string]string)
+	for _, service := range loadServices() {
+		current[service.ID] = launchCommandOf(&service)
+	}
+	for i := range services {
+		command := launchCommandOf(&services[i])
+		if command == "" || command == current[services[i].ID] {
+			continue
+		}
+		if err := commandAccess(c, "service.launchCommand", command); err != nil {
+			return err
+		}
+	}
+	if jobScheduler == nil {
+		return nil
+	}
+	for _, job := range jobs {
+		if existing, ok := jobScheduler.Job(job.ID); ok && existing.Command == job.Command {
+			continue
+		}
+		if err := commandAccess(c, "job.command", job.Command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formOrQuery 优先读取表单字段，其次读取查询参数
+func formOrQuery(c *gin.Context, key, defaultValue string) string {
+	if v := c.PostForm(key); v != "" {
+		return v
+	}
+	return c.DefaultQuery(key, defaultValue)
+}
+
+// readConfigArchive 读取清单及资源文件，限制条目数和解压后的总大小
+func readConfigArchive(zr *zip.Reader) (*ConfigManifest, map[string][]byte, error) {
+	if len(zr.File) > configMaxEntries {
+		return nil, nil, fmt.Errorf("归档条目过多（最多 %d 个）", configMaxEntries)
+	}
+
+	var manifest *ConfigManifest
+	assets := make(map[string][]byte)
+	total := 0
+
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readZipEntry(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 按实际读取的字节计算，不信任条目头中声明的大小
+		total += len(data)
+		if total > configTotalMaxSize {
+			return nil, nil, fmt.Errorf("归档解压后过大，最大 %dMB", configTotalMaxSize/1024/1024)
+		}
+
+		if entry.Name == configManifestName {
+			manifest = &ConfigManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("清单解析失败: %v", err)
+			}
+			continue
+		}
+
+		name, err := cleanAssetPath(entry.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		assets[name] = data
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("归档中缺少 %s", configManifestName)
+	}
+	if manifest.Version > configManifestVersion {
+		return nil, nil, fmt.Errorf("不支持的清单版本: %d", manifest.Version)
+	}
+	return manifest, assets, nil
+}
+
+// readZipEntry 读取归档条目，限制解压后大小
+func readZipEntry(entry *zip.File) ([]byte, error) {
+	if entry.UncompressedSize64 > configEntryMaxSize {
+		return nil, fmt.Errorf("文件过大: %s", entry.Name)
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", entry.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, configEntryMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", entry.Name, err)
+	}
+	if len(data) > configEntryMaxSize {
+		return nil, fmt.Errorf("文件过大: %s", entry.Name)
+	}
+	return data, nil
+}
+
+// cleanAssetPath 校验资源路径，只允许 icons/ 和 backgrounds/ 下的图片
+func cleanAssetPath(name string) (string, error) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	dir, base := path.Split(name)
+	exts, ok := configAssetDirs[strings.TrimSuffix(dir, "/")]
+	if !ok || base == "" || strings.ContainsAny(base, "/\\:*?\"<>|") || strings.HasPrefix(base, ".") {
+		return "", fmt.Errorf("归档包含不允许的文件: %s", name)
+	}
+	if !exts[strings.ToLower(path.Ext(base))] {
+		return "", fmt.Errorf("归档包含不支持的文件类型: %s", name)
+	}
+	return name, nil
+}
+
+// planConfigImport 校验导入内容并计算变更，返回计划及合并后的结果
+func planConfigImport(manifest *ConfigManifest, assets map[string][]byte, mode, conflict string) (*ConfigImportPlan, []ServiceCard, UserSettings, []scheduler.Job) {
+	plan := &ConfigImportPlan{
+		Mode:     mode,
+		Conflict: conflict,
+		Services: []ConfigItemChange{},
+		Jobs:     []ConfigItemChange{},
+		Settings: []ConfigFieldChange{},
+		Files:    []ConfigFileChange{},
+	}
+
+	// 校验服务
+	for i := range manifest.Services {
+		svc := manifest.Services[i]
+		if svc.ID == "" {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("服务 %q 缺少 ID", svc.Name))
+			continue
+		}
+		if err := ValidateServiceConfig(&svc); err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("服务 %s: %v", svc.ID, err))
+		}
+	}
+
+	// 校验任务
+	for i := range manifest.Jobs {
+		if manifest.Jobs[i].ID == "" {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("任务 %q 缺少 ID", manifest.Jobs[i].Name))
+			continue
+		}
+		if err := scheduler.Validate(&manifest.Jobs[i]); err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("任务 %s: %v", manifest.Jobs[i].ID, err))
+		}
+	}
+	if len(manifest.Jobs) > 0 && jobScheduler == nil {
+		plan.Errors = append(plan.Errors, "任务调度器未初始化，无法导入任务")
+	}
+
+	// 合并服务
+	services, serviceChanges, err := mergeConfigItems(loadServices(), manifest.Services,
+		func(s ServiceCard) string { return s.ID },
+		func(s ServiceCard) string { return s.Name },
+		func(s *ServiceCard, id string) { s.ID = id },
+		mode, conflict)
+	if err != nil {
+		plan.Errors = append(plan.Errors, err.Error())
+	}
+	plan.Services = serviceChanges
+
+	// 合并任务
+	var currentJobs []scheduler.Job
+	if jobScheduler != nil {
+		currentJobs = jobScheduler.Jobs()
+	}
+	jobs, jobChanges, err := mergeConfigItems(currentJobs, manifest.Jobs,
+		func(j scheduler.Job) string { return j.ID },
+		func(j scheduler.Job) string { return j.Name },
+		func(j *scheduler.Job, id string) { j.ID = id },
+		mode, conflict)
+	if err != nil {
+		plan.Errors = append(plan.Errors, err.Error())
+	}
+	plan.Jobs = jobChanges
+
+	// 设置：替换模式整体覆盖，合并模式只覆盖导入中非空的字段
+	current := loadSettings()
+	settings := manifest.Settings
+	if mode == "merge" {
+		settings = overlaySettings(current, manifest.Settings)
+	}
+	if err := ValidateUserSettings(&settings); err != nil {
+		plan.Errors = append(plan.Errors, "设置: "+err.Error())
+	}
+	plan.Settings = diffFields(current, settings)
+
+	// 资源文件
+	for _, name := range sortedKeys(assets) {
+		action := "add"
+		if existing, err := os.ReadFile(filepath.Join(webDir, filepath.FromSlash(name))); err == nil {
+			action = "overwrite"
+			if bytes.Equal(existing, assets[name]) {
+				action = "unchanged"
+			}
+		}
+		plan.Files = append(plan.Files, ConfigFileChange{Path: name, Action: action})
+	}
+
+	return plan, services, settings, jobs
+}
+
+// mergeConfigItems 按 ID 合并服务或任务列表
+func mergeConfigItems[T any](current, imported []T, idOf, nameOf func(T) string, setID func(*T, string), mode, conflict string) ([]T, []ConfigItemChange, error) {
+	changes := []ConfigItemChange{}
+
+	seen := make(map[string]bool, len(imported))
+	for _, item := range imported {
+		if seen[idOf(item)] {
+			return nil, nil, fmt.Errorf("导入内容中存在重复的 ID: %s", idOf(item))
+		}
+		seen[idOf(item)] = true
+	}
+
+	index := make(map[string]int, len(current))
+	for i, item := range current {
+		index[idOf(item)] = i
+	}
+
+	if mode == "replace" {
+		for _, item := range imported {
+			change := ConfigItemChange{ID: idOf(item), Name: nameOf(item), Action: "add"}
+			if i, ok := index[idOf(item)]; ok {
+				change.Fields = diffFields(current[i], item)
+				change.Action = "update"
+				if len(change.Fields) == 0 {
+					change.Action = "unchanged"
+				}
+			}
+			changes = append(changes, change)
+		}
+		for _, item := range current {
+			if !seen[idOf(item)] {
+				changes = append(changes, ConfigItemChange{ID: idOf(item), Name: nameOf(item), Action: "remove"})
+			}
+		}
+		return append([]T{}, imported...), changes, nil
+	}
+
+	result := append([]T{}, current...)
+	for _, item := range imported {
+		change := ConfigItemChange{ID: idOf(item), Name: nameOf(item)}
+		i, exists := index[idOf(item)]
+		switch {
+		case !exists:
+			change.Action = "add"
+			result = append(result, item)
+		case conflict == "skip":
+			change.Action = "skip"
+		case conflict == "rename":
+			change.Action = "rename"
+			change.NewID = uuid.New().String()[:8]
+			setID(&item, change.NewID)
+			result = append(result, item)
+		default:
+			change.Fields = diffFields(current[i], item)
+			change.Action = "update"
+			if len(change.Fields) == 0 {
+				change.Action = "unchanged"
+			}
+			result[i] = item
+		}
+		changes = append(changes, change)
+	}
+	return result, changes, nil
+}
+
+// overlaySettings 用导入设置中的非空字段覆盖当前设置
+func overlaySettings(current, imported UserSettings) UserSettings {
+	base := toFieldMap(current)
+	for key, value := range toFieldMap(imported) {
+		if value != nil && !reflect.ValueOf(value).IsZero() {
+			base[key] = value
+		}
+	}
+	var merged UserSettings
+	data, _ := json.Marshal(base)
+	json.Unmarshal(data, &merged)
+	return merged
+}
+
+// diffFields 以 JSON 字段为单位比较两个值
+func diffFields(oldValue, newValue interface{}) []ConfigFieldChange {
+	oldMap := toFieldMap(oldValue)
+	newMap := toFieldMap(newValue)
+
+	keys := make(map[string]bool)
+	for k := range oldMap {
+		keys[k] = true
+	}
+	for k := range newMap {
+		keys[k] = true
+	}
+
+	changes := []ConfigFieldChange{}
+	for _, k := range sortedKeys(keys) {
+		if !reflect.DeepEqual(oldMap[k], newMap[k]) {
+			changes = append(changes, ConfigFieldChange{Field: k, Old: oldMap[k], New: newMap[k]})
+		}
+	}
+	return changes
+}
+
+// toFieldMap 把结构体转换为 JSON 字段映射
+func toFieldMap(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// sortedKeys 返回排序后的键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyConfigImport 写入资源文件、任务、设置和服务，任一步失败时按相反顺序回滚已完成的步骤
+func applyConfigImport(plan *ConfigImportPlan, services []ServiceCard, settings UserSettings, jobs []scheduler.Job, assets map[string][]byte) error {
+	// 先取得回滚所需的当前状态，读取失败时不写入任何内容
+	previousSettings, err := loadSettingsChecked()
+	if err != nil {
+		return fmt.Errorf("读取当前设置失败: %v", err)
+	}
+	replaceJobs := jobScheduler != nil && (len(plan.Jobs) > 0 || plan.Mode == "replace")
+	var previousJobs []scheduler.Job
+	if replaceJobs {
+		previousJobs = jobScheduler.Jobs()
+	}
+
+	var undo []func()
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	restoreFiles, err := writeImportAssets(plan.Files, assets)
+	if err != nil {
+		return err
+	}
+	undo = append(undo, restoreFiles)
+
+	if replaceJobs {
+		if err := jobScheduler.ReplaceJobs(jobs); err != nil {
+			rollback()
+			return fmt.Errorf("导入任务失败: %v", err)
+		}
+		undo = append(undo, func() {
+			if err := jobScheduler.ReplaceJobs(previousJobs); err != nil {
+				log.Printf("回滚任务失败: %v", err)
+			}
+		})
+	}
+
+	if err := saveSettings(settings); err != nil {
+		rollback()
+		return fmt.Errorf("保存设置失败: %v", err)
+	}
+	applyMounts(settings)
+	undo = append(undo, func() {
+		if err := saveSettings(previousSettings); err != nil {
+			log.Printf("回滚设置失败: %v", err)
+		}
+		applyMounts(previousSettings)
+	})
+
+	_, err = serviceStore.Update(func([]ServiceCard) ([]ServiceCard, error) {
+		return services, nil
+	})
+	if err != nil {
+		rollback()
+		return fmt.Errorf("保存服务失败: %v", err)
+	}
+	return nil
+}
+
+// importedAsset 已暂存的资源文件及其原内容
+type importedAsset struct {
+	target   string
+	tmp      string
+	previous []byte
+	existed  bool
+}
+
+// writeImportAssets 先把全部资源写入同目录的临时文件，都成功后再逐个替换，返回恢复原文件的函数
+func writeImportAssets(files []ConfigFileChange, assets map[string][]byte) (func(), error) {
+	var staged []importedAsset
+	removeTemps := func() {
+		for _, item := range staged {
+			os.Remove(item.tmp)
+		}
+	}
+
+	for _, file := range files {
+		if file.Action == "unchanged" {
+			continue
+		}
+		item := importedAsset{target: filepath.Join(webDir, filepath.FromSlash(file.Path))}
+		previous, err := os.ReadFile(item.target)
+		switch {
+		case err == nil:
+			item.previous, item.existed = previous, true
+		case !os.IsNotExist(err):
+			removeTemps()
+			return nil, fmt.Errorf("读取 %s 失败: %v", file.Path, err)
+		}
+		tmp, err := stageFile(item.target, assets[file.Path])
+		if err != nil {
+			removeTemps()
+			return nil, fmt.Errorf("写入 %s 失败: %v", file.Path, err)
+		}
+		item.tmp = tmp
+		staged = append(staged, item)
+	}
+
+	replaced := 0
+	restore := func() {
+		for _, item := range staged[:replaced] {
+			var err error
+			if item.existed {
+				err = storage.WriteFileAtomic(item.target, item.previous, 0644)
+			} else {
+				err = os.Remove(item.target)
+			}
+			if err != nil {
+				log.Printf("回滚 %s 失败: %v", item.target, err)
+			}
+		}
+	}
+	for _, item := range staged {
+		if err := os.Rename(item.tmp, item.target); err != nil {
+			restore()
+			removeTemps()
+			return nil, fmt.Errorf("写入 %s 失败: %v", item.target, err)
+		}
+		replaced++
+	}
+	return restore, nil
+}
+
+// stageFile 在目标所在目录写入临时文件，返回临时文件路径
+func stageFile(target string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(target), ".import-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	// CreateTemp 创建的文件权限为 0600，与直接写入的资源保持一致
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
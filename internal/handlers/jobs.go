@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+
+	"homedash/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+var jobScheduler *scheduler.Scheduler
+
+// InitScheduler 初始化任务调度器
+func InitScheduler(s *scheduler.Scheduler) {
+	jobScheduler = s
+}
+
+// NewJobCommand 构建任务进程，与服务启动命令使用相同的解析规则
+func NewJobCommand(ctx context.Context, command string) (*exec.Cmd, error) {
+	cmd, err := newLaunchCommand(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = dataDir
+	setProcessTreeKill(cmd)
+	return cmd, nil
+}
+
+// requireScheduler 检查调度器是否已初始化
+func requireScheduler(c *gin.Context) bool {
+	if jobScheduler == nil {
+		c.JSON(503, gin.H{"error": "任务调度器未初始化"})
+		return false
+	}
+	return true
+}
+
+// jobWithState 任务及其运行状态
+func jobWithState(job scheduler.Job) gin.H {
+	return gin.H{
+		"id":        job.ID,
+		"name":      job.Name,
+		"cron":      job.Cron,
+		"command":   job.Command,
+		"timeout":   job.Timeout,
+		"policy":    job.Policy,
+		"enabled":   job.Enabled,
+		"createdAt": job.CreatedAt,
+		"updatedAt": job.UpdatedAt,
+		"running":   jobScheduler.IsRunning(job.ID),
+	}
+}
+
+// GetJobs 获取任务列表
+func GetJobs(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	jobs := jobScheduler.Jobs()
+	result := make([]gin.H, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, jobWithState(job))
+	}
+	c.JSON(200, result)
+}
+
+// GetJob 获取单个任务
+func GetJob(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	job, ok := jobScheduler.Job(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "任务不存在"})
+		return
+	}
+	c.JSON(200, jobWithState(job))
+}
+
+// CreateJob 创建任务
+func CreateJob(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	var job scheduler.Job
+	if err := c.ShouldBindJSON(&job); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !requireCommandAccess(c, "job.command", job.Command) {
+		return
+	}
+
+	created, err := jobScheduler.CreateJob(job)
+	if errors.Is(err, scheduler.ErrSave) {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, created)
+}
+
+// UpdateJob 更新任务
+func UpdateJob(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	var job scheduler.Job
+	if err := c.ShouldBindJSON(&job); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !requireCommandAccess(c, "job.command", job.Command) {
+		return
+	}
+
+	updated, err := jobScheduler.UpdateJob(c.Param("id"), job)
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, scheduler.ErrSave) {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, updated)
+}
+
+// DeleteJob 删除任务
+func DeleteJob(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	err := jobScheduler.DeleteJob(c.Param("id"))
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// TriggerJob 手动触发任务，任务命令需通过当前用户的终端命令策略
+func TriggerJob(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	job, ok := jobScheduler.Job(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": scheduler.ErrJobNotFound.Error()})
+		return
+	}
+	if !requireCommandAccess(c, "job.command", job.Command) {
+		return
+	}
+	run, err := jobScheduler.Trigger(c.Param("id"), scheduler.TriggerManual)
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, scheduler.ErrQueueFull):
+		c.JSON(429, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.JSON(200, run)
+	}
+}
+
+// GetJobRuns 获取执行记录，可按任务过滤
+func GetJobRuns(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	jobID := c.Param("id")
+	if jobID == "" {
+		jobID = c.Query("job")
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	c.JSON(200, jobScheduler.Runs(jobID, limit))
+}
+
+// GetJobRun 获取单次执行详情（含输出）
+func GetJobRun(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	run, ok := jobScheduler.Run(c.Param("runId"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "执行记录不存在"})
+		return
+	}
+	c.JSON(200, run)
+}
+
+// CancelJobRun 取消正在进行或排队中的执行
+func CancelJobRun(c *gin.Context) {
+	if !requireScheduler(c) {
+		return
+	}
+	err := jobScheduler.Cancel(c.Param("runId"))
+	if errors.Is(err, scheduler.ErrRunNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
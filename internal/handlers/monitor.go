@@ -6,13 +6,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var monitorHub *monitor.Hub
+var (
+	monitorHub *monitor.Hub
+	eventHub   *monitor.Hub // 推送给所有已登录页面的事件（不受监控权限限制）
+)
 
 // InitMonitor 初始化监控Hub
 func InitMonitor(hub *monitor.Hub) {
+	hub.CheckOrigin = checkWSOrigin
 	monitorHub = hub
 }
 
+// InitEvents 初始化事件推送Hub
+func InitEvents(hub *monitor.Hub) {
+	hub.CheckOrigin = checkWSOrigin
+	eventHub = hub
+}
+
 // GetMonitorHub 获取监控Hub
 func GetMonitorHub() *monitor.Hub {
 	return monitorHub
@@ -27,6 +37,15 @@ func HandleMonitorWebSocket(c *gin.Context) {
 	monitorHub.HandleWebSocket(c.Writer, c.Request)
 }
 
+// HandleEventsWebSocket 处理事件推送WebSocket连接（配置被外部修改等通知）
+func HandleEventsWebSocket(c *gin.Context) {
+	if eventHub == nil {
+		c.JSON(500, gin.H{"error": "事件服务未初始化"})
+		return
+	}
+	eventHub.HandleWebSocket(c.Writer, c.Request)
+}
+
 // GetProcesses 获取进程列表
 func GetProcesses(c *gin.Context) {
 	processes := monitor.GetTopProcesses(20)
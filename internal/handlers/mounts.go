@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"homedash/internal/mounts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mounts 当前的挂载点（可以并发调用）
+func Mounts() []mounts.Mount {
+	return fileMounts.List()
+}
+
+// LoadMounts 从设置加载挂载点
+func LoadMounts() {
+	applyMounts(loadSettings())
+}
+
+// applyMounts 应用设置中的挂载点
+func applyMounts(settings UserSettings) {
+	fileMounts.Set(settingsMounts(settings))
+}
+
+// settingsMounts 设置中的挂载点，未配置时由旧的 webdavRoot（或默认目录）生成一个挂载点
+func settingsMounts(settings UserSettings) []mounts.Mount {
+	if len(settings.Mounts) > 0 {
+		return settings.Mounts
+	}
+	root := settings.WebdavRoot
+	if root == "" {
+		root = defaultRoot
+	}
+	return []mounts.Mount{{Name: mounts.DefaultName, Path: root}}
+}
+
+// findMount 按名称查找挂载点，名称为空时使用第一个
+func findMount(name string) (mounts.Mount, bool) {
+	list := Mounts()
+	if name == "" {
+		if len(list) == 0 {
+			return mounts.Mount{}, false
+		}
+		return list[0], true
+	}
+	return mounts.Find(list, name)
+}
+
+// GetMounts 获取挂载点
+func GetMounts(c *gin.Context) {
+	config, _ := loadWebdavConfig()
+	c.JSON(200, gin.H{"mounts": Mounts(), "webdavDisabled": config.Disabled})
+}
+
+// UpdateMounts 修改挂载点，立即对文件管理、WebDAV 和 SFTP 生效
+func UpdateMounts(c *gin.Context) {
+	var req struct {
+		Mounts []mounts.Mount `json:"mounts"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求"})
+		return
+	}
+	list, err := mounts.Validate(req.Mounts)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := loadSettings()
+	settings.Mounts = list
+	settings.WebdavRoot = "" // 已由挂载点代替
+	if err := saveSettings(settings); err != nil {
+		respondSaveError(c, "保存设置失败", err)
+		return
+	}
+	applyMounts(settings)
+	c.JSON(200, gin.H{"success": true, "mounts": list})
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const configHistoryLimit = 20 // 每个配置保留的历史版本数
+
+var backend storage.Backend // 持久化后端（JSON 文件或 SQLite）
+
+// InitStorage 设置持久化后端
+func InitStorage(b storage.Backend) {
+	backend = b
+}
+
+// GetStorage 获取持久化后端
+func GetStorage() storage.Backend {
+	return backend
+}
+
+// ConfigCorruptError 配置无法解析
+type ConfigCorruptError struct {
+	File string
+	Err  error
+}
+
+func (e *ConfigCorruptError) Error() string {
+	return fmt.Sprintf("配置文件 %s 已损坏: %v", e.File, e.Err)
+}
+
+func (e *ConfigCorruptError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigVersion 配置历史版本
+type ConfigVersion struct {
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+	SavedAt int64  `json:"savedAt"`
+	Current bool   `json:"current"` // 与当前内容一致
+}
+
+// DiffLine 差异行
+type DiffLine struct {
+	Type string `json:"type"` // "same" | "add" | "del"
+	Text string `json:"text"`
+}
+
+// configFileSpec 可版本化的配置
+type configFileSpec struct {
+	check    func(data []byte) error // 仅检查能否解析
+	validate func(data []byte) error // 解析并做完整校验（用于外部修改）
+	lock     func() func()
+	apply    func(data []byte) // 回滚或外部修改后需要同步到内存的状态
+}
+
+// configFiles 可通过 API 查看和回滚的配置，键与存储后端的文档键一致
+var configFiles = map[string]configFileSpec{
+	storage.KeyServices: {
+		check:    checkServicesJSON,
+		validate: validateServicesJSON,
+		lock: func() func() {
+			servicesMu.Lock()
+			return servicesMu.Unlock
+		},
+	},
+	storage.KeySettings: {
+		check:    checkSettingsJSON,
+		validate: validateSettingsJSON,
+		lock: func() func() {
+			settingsMu.Lock()
+			return settingsMu.Unlock
+		},
+		apply: func(data []byte) {
+			var settings UserSettings
+			if json.Unmarshal(data, &settings) == nil {
+				applyMounts(settings)
+			}
+		},
+	},
+}
+
+// configDisplayName 用于提示的配置名称（JSON 后端为文件名）
+func configDisplayName(name string) string {
+	if fb, ok := backend.(storage.FileBackend); ok {
+		return filepath.Base(fb.Path(name))
+	}
+	return name
+}
+
+// loadConfig 读取配置文档，不存在时返回 nil
+func loadConfig(name string) ([]byte, error) {
+	data, err := backend.Load(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// checkServicesJSON 检查服务文件能否解析
+func checkServicesJSON(data []byte) error {
+	var services []ServiceCard
+	return describeJSONError(data, json.Unmarshal(data, &services))
+}
+
+// checkSettingsJSON 检查设置文件能否解析
+func checkSettingsJSON(data []byte) error {
+	var settings UserSettings
+	return describeJSONError(data, json.Unmarshal(data, &settings))
+}
+
+// describeJSONError 为 JSON 错误补充行号和列号
+func describeJSONError(data []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 {
+		return err
+	}
+
+	line, col := offsetToLineCol(data, offset)
+	return fmt.Errorf("第 %d 行第 %d 列: %v", line, col, err)
+}
+
+// offsetToLineCol 把字节偏移转换为行号和列号（从 1 开始）
+func offsetToLineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// writeVersionedConfig 写入配置并记录历史版本
+// 当前内容无法解析时拒绝覆盖，以便从历史版本中恢复；调用方需持有对应的锁
+func writeVersionedConfig(name string, data []byte, check func([]byte) error) error {
+	existing, err := loadConfig(name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := check(existing); err != nil {
+			return &ConfigCorruptError{File: configDisplayName(name), Err: err}
+		}
+	}
+	return commitConfigVersion(name, data)
+}
+
+// commitConfigVersion 写入配置并保存历史版本
+func commitConfigVersion(name string, data []byte) error {
+	// 先登记内容，避免监听器把本次写入当作外部修改
+	rememberConfigContent(name, data)
+	if err := backend.Save(name, data); err != nil {
+		return err
+	}
+	rememberGoodConfig(name, data)
+	if err := saveConfigVersion(name, data); err != nil {
+		log.Printf("保存 %s 历史版本失败: %v", name, err)
+	}
+	return nil
+}
+
+// saveConfigVersion 保存一个历史版本（与最新版本相同时跳过）
+func saveConfigVersion(name string, data []byte) error {
+	versions, err := backend.Versions(name)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		if latest, err := backend.LoadVersion(name, versions[0].ID); err == nil && bytes.Equal(latest, data) {
+			return nil
+		}
+	}
+	return backend.SaveVersion(name, time.Now().Format(storage.VersionLayout), data, configHistoryLimit)
+}
+
+// readConfigVersion 读取历史版本内容
+func readConfigVersion(name, version string) ([]byte, error) {
+	return backend.LoadVersion(name, version)
+}
+
+// lookupConfigFile 解析路由中的配置文件名
+func lookupConfigFile(c *gin.Context) (string, configFileSpec, bool) {
+	name := c.Param("file")
+	spec, ok := configFiles[name]
+	if !ok {
+		c.JSON(404, gin.H{"error": "未知的配置文件，仅支持 services 和 settings"})
+	}
+	return name, spec, ok
+}
+
+// GetConfigStatus 获取配置健康状态
+func GetConfigStatus(c *gin.Context) {
+	result := gin.H{"backend": backend.Kind()}
+	for name, spec := range configFiles {
+		versions, _ := backend.Versions(name)
+		status := gin.H{"ok": true, "versions": len(versions)}
+		data, err := loadConfig(name)
+		if err != nil {
+			status["ok"] = false
+			status["error"] = err.Error()
+		} else if data != nil {
+			if err := spec.check(data); err != nil {
+				status["ok"] = false
+				status["error"] = (&ConfigCorruptError{File: configDisplayName(name), Err: err}).Error()
+			}
+		}
+		result[name] = status
+	}
+	c.JSON(200, result)
+}
+
+// GetConfigVersions 列出配置的历史版本
+func GetConfigVersions(c *gin.Context) {
+	name, _, ok := lookupConfigFile(c)
+	if !ok {
+		return
+	}
+
+	list, err := backend.Versions(name)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	current, _ := loadConfig(name)
+	versions := make([]ConfigVersion, 0, len(list))
+	for _, v := range list {
+		data, err := backend.LoadVersion(name, v.ID)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, ConfigVersion{
+			Version: v.ID,
+			Size:    v.Size,
+			SavedAt: v.SavedAt.UnixMilli(),
+			Current: bytes.Equal(data, current),
+		})
+	}
+	c.JSON(200, versions)
+}
+
+// GetConfigVersion 获取某个历史版本的内容
+func GetConfigVersion(c *gin.Context) {
+	name, _, ok := lookupConfigFile(c)
+	if !ok {
+		return
+	}
+	data, err := readConfigVersion(name, c.Param("version"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "版本不存在"})
+		return
+	}
+	c.Data(200, "application/json; charset=utf-8", data)
+}
+
+// DiffConfigVersion 比较历史版本与当前文件（或另一个版本）的差异
+func DiffConfigVersion(c *gin.Context) {
+	name, _, ok := lookupConfigFile(c)
+	if !ok {
+		return
+	}
+	data, err := readConfigVersion(name, c.Param("version"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "版本不存在"})
+		return
+	}
+
+	against := c.DefaultQuery("against", "current")
+	var base []byte
+	if against == "current" {
+		base, _ = loadConfig(name)
+	} else if base, err = readConfigVersion(name, against); err != nil {
+		c.JSON(404, gin.H{"error": "对比版本不存在"})
+		return
+	}
+
+	lines, exact := diffLines(string(base), string(data))
+	added, deleted := 0, 0
+	for _, l := range lines {
+		switch l.Type {
+		case "add":
+			added++
+		case "del":
+			deleted++
+		}
+	}
+	c.JSON(200, gin.H{
+		"version": c.Param("version"),
+		"against": against,
+		"added":   added,
+		"deleted": deleted,
+		"exact":   exact, // false 表示差异过大，中间部分整体显示为删除再添加
+		"lines":   lines,
+	})
+}
+
+// RollbackConfigVersion 回滚到指定历史版本（即使当前文件已损坏）
+func RollbackConfigVersion(c *gin.Context) {
+	name, spec, ok := lookupConfigFile(c)
+	if !ok {
+		return
+	}
+	data, err := readConfigVersion(name, c.Param("version"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "版本不存在"})
+		return
+	}
+	if err := spec.check(data); err != nil {
+		c.JSON(422, gin.H{"error": "历史版本无法解析: " + err.Error()})
+		return
+	}
+
+	unlock := spec.lock()
+	err = commitConfigVersion(name, data)
+	unlock()
+	if err != nil {
+		c.JSON(500, gin.H{"error": "回滚失败: " + err.Error()})
+		return
+	}
+	if spec.apply != nil {
+		spec.apply(data)
+	}
+
+	log.Printf("配置 %s 已回滚到版本 %s", name, c.Param("version"))
+	c.JSON(200, gin.H{"success": true})
+}
+
+// maxDiffCells 逐行比较时 LCS 表的最大单元数（约 32MB），超出时退化为整体替换
+const maxDiffCells = 4 * 1024 * 1024
+
+// diffLines 基于最长公共子序列的逐行差异。先去掉相同的首尾行，只对中间部分计算 LCS；
+// 中间部分过大时整体显示为删除再添加，exact 为 false
+func diffLines(oldText, newText string) (lines []DiffLine, exact bool) {
+	a := strings.Split(strings.TrimRight(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(newText, "\n"), "\n")
+	if oldText == "" {
+		a = nil
+	}
+	if newText == "" {
+		b = nil
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines = make([]DiffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, text := range a[:prefix] {
+		lines = append(lines, DiffLine{Type: "same", Text: text})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	exact = (len(middleA)+1)*(len(middleB)+1) <= maxDiffCells
+	if exact {
+		lines = appendLCSDiff(lines, middleA, middleB)
+	} else {
+		for _, text := range middleA {
+			lines = append(lines, DiffLine{Type: "del", Text: text})
+		}
+		for _, text := range middleB {
+			lines = append(lines, DiffLine{Type: "add", Text: text})
+		}
+	}
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, DiffLine{Type: "same", Text: text})
+	}
+	return lines, exact
+}
+
+// appendLCSDiff 按最长公共子序列追加 a 到 b 的逐行差异
+func appendLCSDiff(lines []DiffLine, a, b []string) []DiffLine {
+	// lcs[i*width+j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Type: "same", Text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			lines = append(lines, DiffLine{Type: "del", Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Type: "add", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{Type: "del", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{Type: "add", Text: b[j]})
+	}
+	return lines
+}
+
+// respondSaveError 返回保存失败信息，配置文件已损坏时返回 409 提示先回滚
+func respondSaveError(c *gin.Context, message string, err error) {
+	var corrupt *ConfigCorruptError
+	if errors.As(err, &corrupt) {
+		c.JSON(409, gin.H{"error": err.Error() + "，请先回滚到可用的历史版本", "corrupt": true})
+		return
+	}
+	c.JSON(500, gin.H{"error": message + ": " + err.Error()})
+}
+
+// rememberLatestGoodVersion 当前文件损坏时，以最近一个可解析的历史版本作为读取的内容
+func rememberLatestGoodVersion(name string, spec configFileSpec) {
+	versions, err := backend.Versions(name)
+	if err != nil {
+		return
+	}
+	for _, v := range versions {
+		data, err := backend.LoadVersion(name, v.ID)
+		if err == nil && spec.check(data) == nil {
+			log.Printf("在修复前将使用 %s 的历史版本 %s", configDisplayName(name), v.ID)
+			rememberGoodConfig(name, data)
+			return
+		}
+	}
+}
+
+// CheckConfigFiles 启动时检查配置：损坏时输出警告（保存会被拒绝），
+// 完好且尚无历史版本时记录一个初始版本作为回滚起点
+func CheckConfigFiles() {
+	for name, spec := range configFiles {
+		data, err := loadConfig(name)
+		if err != nil || data == nil {
+			continue
+		}
+		if err := spec.check(data); err != nil {
+			log.Printf("⚠ %v，修改将被拒绝，可通过 /api/config/versions/%s 回滚", &ConfigCorruptError{File: configDisplayName(name), Err: err}, name)
+			rememberLatestGoodVersion(name, spec)
+			continue
+		}
+		rememberConfigContent(name, data)
+		rememberGoodConfig(name, data)
+		if versions, err := backend.Versions(name); err == nil && len(versions) == 0 {
+			if err := saveConfigVersion(name, data); err != nil {
+				log.Printf("保存 %s 初始版本失败: %v", name, err)
+			}
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os/exec"
 	"path/filepath"
 	"runtime"
@@ -29,42 +31,100 @@ func LaunchService(c *gin.Context) {
 		return
 	}
 
-	// 优先使用 LaunchCommand，否则使用 LaunchPath（向后兼容）
-	var launchCmd string
-	if service.LaunchCommand != "" {
-		launchCmd = service.LaunchCommand
-	} else if service.LaunchPath != "" {
-		launchCmd = service.LaunchPath
-	} else {
+	if service.LaunchCommand == "" && service.LaunchPath == "" {
 		c.JSON(400, gin.H{"error": "服务未配置启动命令或启动路径"})
 		return
 	}
 
-	if err := launchService(launchCmd); err != nil {
+	cgroupStatus, err := launchService(service)
+	if err != nil {
 		c.JSON(500, gin.H{"error": "启动失败: " + err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"success": true})
+	resp := gin.H{"success": true}
+	if cgroupStatus != nil {
+		resp["cgroup"] = cgroupStatus
+	}
+	c.JSON(200, resp)
 }
 
-// launchService 启动服务进程
-func launchService(launchCmd string) error {
+// launchService 启动服务进程，配置了资源限制时放入独立 cgroup
+// 返回的 CgroupStatus 描述资源限制是否生效（未配置限制时为 nil）
+func launchService(service *ServiceCard) (*CgroupStatus, error) {
+	launchCmd := launchCommandOf(service)
+	cmd, err := newLaunchCommand(context.Background(), launchCmd)
+	if err != nil {
+		return nil, err
+	}
+	// 回收退出的服务进程，避免僵尸进程被误认为仍在运行
+	defer func() {
+		if cmd.Process != nil {
+			go cmd.Wait()
+		}
+	}()
+	if service.ResourceLimits == nil {
+		return nil, cmd.Start()
+	}
+
+	// 资源限制失败不影响启动，降级为无限制运行并返回原因
+	status := &CgroupStatus{Limits: *service.ResourceLimits}
+	dir, skipped, err := prepareServiceCgroup(service.ID, *service.ResourceLimits)
+	status.Path, status.Skipped = dir, skipped
+	if err != nil {
+		status.Available = dir != ""
+		status.Message = "资源限制未生效: " + err.Error()
+		log.Printf("服务 %s 资源限制未生效: %v", service.ID, err)
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+	status.Available = true
+
+	// 直接在 cgroup 中启动进程，启动过程中 fork 出的子进程也受限制
+	if err := startInCgroup(cmd, dir); err != nil {
+		// 内核不支持 CLONE_INTO_CGROUP（Linux 5.7 以下）时先启动再迁入，迁入前创建的子进程不受限制
+		if cmd, err = newLaunchCommand(context.Background(), launchCmd); err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		if err := addProcToCgroup(dir, cmd.Process.Pid); err != nil {
+			status.Message = "资源限制未生效: " + err.Error()
+			log.Printf("服务 %s 资源限制未生效: %v", service.ID, err)
+			return status, nil
+		}
+	}
+
+	status.Applied = len(skipped) == 0
+	if !status.Applied {
+		status.Message = skippedControllersMessage(skipped)
+		log.Printf("服务 %s 部分资源限制未生效: %s", service.ID, strings.Join(skipped, ", "))
+	}
+	return status, nil
+}
+
+// launchCommandOf 服务实际执行的启动命令：优先使用 LaunchCommand，否则使用 LaunchPath（向后兼容）
+func launchCommandOf(service *ServiceCard) string {
+	if service.LaunchCommand != "" {
+		return service.LaunchCommand
+	}
+	return service.LaunchPath
+}
+
+// newLaunchCommand 按启动命令构建进程，ctx 取消时终止进程
+func newLaunchCommand(ctx context.Context, launchCmd string) (*exec.Cmd, error) {
 	// 假设 launchCmd 是 `C:\alist.exe server` parts 应该是 ["C:\alist.exe", "server"]
 	parts := parseCommand(launchCmd)
 	if len(parts) == 0 {
-		return fmt.Errorf("启动命令为空")
+		return nil, fmt.Errorf("启动命令为空")
 	}
 
 	// 直接执行，不要嵌套 cmd.exe /c start
 	// 第一个元素是程序名，后面的解构为参数
-	cmd := exec.Command(parts[0], parts[1:]...)
-	err := cmd.Start()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return exec.CommandContext(ctx, parts[0], parts[1:]...), nil
 }
 
 // parseCommand 解析命令字符串，支持引号包裹的参数
@@ -122,6 +182,9 @@ func GetServiceProcessStatus(c *gin.Context) {
 	}
 
 	status := checkServiceProcess(service.ProcessName, service.LaunchPath, service.LaunchCommand)
+	if service.ResourceLimits != nil {
+		status.Cgroup = getServiceCgroupStatus(service.ID, *service.ResourceLimits, status.PID)
+	}
 	c.JSON(200, status)
 }
 
@@ -149,22 +212,31 @@ func StopService(c *gin.Context) {
 		return
 	}
 
-	// 先检查进程是否存在
-	status := checkServiceProcess(service.ProcessName, service.LaunchPath, service.LaunchCommand)
-	if !status.Running {
-		c.JSON(200, gin.H{"success": true, "message": "进程未运行"})
+	running, err := stopService(service)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "停止失败: " + err.Error()})
 		return
 	}
-
-	// 停止进程
-	if err := stopServiceProcess(status.PID); err != nil {
-		c.JSON(500, gin.H{"error": "停止失败: " + err.Error()})
+	if !running {
+		c.JSON(200, gin.H{"success": true, "message": "进程未运行"})
 		return
 	}
 
 	c.JSON(200, gin.H{"success": true})
 }
 
+// stopService 停止服务进程，返回停止前进程是否在运行
+func stopService(service *ServiceCard) (bool, error) {
+	// 先检查进程是否存在
+	status := checkServiceProcess(service.ProcessName, service.LaunchPath, service.LaunchCommand)
+	if !status.Running {
+		return false, nil
+	}
+
+	// 停止进程
+	return true, stopServiceProcess(status.PID)
+}
+
 // checkServiceProcess 检测服务进程是否存在
 // processName: 进程名（优先使用），如果为空则从 launchPath 或 launchCommand 提取
 func checkServiceProcess(processName, launchPath, launchCommand string) ProcessStatus {
@@ -219,18 +291,16 @@ func stopServiceProcess(pid int32) error {
 		err := cmd.Run()
 		if err == nil {
 			// 等待进程退出（最多 5 秒）
-			for i := 0; i < 10; i++ {
-				exists, _ := process.PidExists(pid)
-				if !exists {
-					return nil
-				}
-				time.Sleep(500 * time.Millisecond)
+			if waitProcessExit(pid, 5*time.Second) {
+				return nil
 			}
 		}
 
 		// 如果优雅关闭失败或超时，强制终止
 		cmd = exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", pid))
-		return cmd.Run()
+		if err := cmd.Run(); err != nil {
+			return err
+		}
 	} else {
 		// Linux/Mac 上使用 kill 命令
 		// 先尝试 SIGTERM
@@ -240,15 +310,43 @@ func stopServiceProcess(pid int32) error {
 		}
 		proc.Terminate()
 
-		// 等待进程退出
-		time.Sleep(2 * time.Second)
+		// 等待进程退出，超时后强制终止
+		if waitProcessExit(pid, 5*time.Second) {
+			return nil
+		}
+		proc.Kill()
+	}
 
-		// 如果还在运行，强制终止
-		exists, _ := process.PidExists(pid)
-		if exists {
-			proc.Kill()
+	if !waitProcessExit(pid, 5*time.Second) {
+		return fmt.Errorf("进程 %d 未能退出", pid)
+	}
+	return nil
+}
+
+// waitProcessExit 等待进程退出，超时返回 false
+func waitProcessExit(pid int32, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if processExited(pid) {
+			return true
 		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
 
-		return nil
+// processExited 进程是否已退出（尚未被回收的僵尸进程视为已退出）
+func processExited(pid int32) bool {
+	exists, err := process.PidExists(pid)
+	if err != nil || !exists {
+		return true
+	}
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return true
 	}
+	status, err := proc.Status()
+	return err == nil && len(status) > 0 && status[0] == process.Zombie
 }
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package handlers
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessTreeKill 让命令在独立进程组中运行，取消时终止整个进程组
+func setProcessTreeKill(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
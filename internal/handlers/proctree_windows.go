@@ -0,0 +1,19 @@
+//go:build windows
+
+package handlers
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessTreeKill 取消时通过 taskkill /T 终止命令及其所有子进程
+func setProcessTreeKill(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"homedash/internal/auth"
+	"homedash/internal/terminal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readMethods 只读请求，其余方法均视为修改
+var readMethods = map[string]bool{
+	"GET":      true,
+	"HEAD":     true,
+	"OPTIONS":  true,
+	"PROPFIND": true, // WebDAV 列目录
+}
+
+// RequireArea 按请求方法校验对功能区域的读或写权限
+func RequireArea(area string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access := auth.AccessWrite
+		if readMethods[c.Request.Method] {
+			access = auth.AccessRead
+		}
+		checkAccess(c, area, access)
+	}
+}
+
+// RequireAreaAccess 校验对功能区域的指定访问级别（用于终端等以 GET 建立连接的写操作）
+func RequireAreaAccess(area, access string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkAccess(c, area, access)
+	}
+}
+
+// checkAccess 未登录的请求只有经 AuthRequired 放行的公开路由才能到达这里，仅允许只读访问；
+// 修改操作和终端（含 SSH）即使路由被配置为公开也要求登录。
+// 使用 API 令牌时还需令牌的权限范围包含该访问
+func checkAccess(c *gin.Context, area, access string) {
+	user, ok := currentUser(c)
+	if !ok && authManager != nil && (access == auth.AccessWrite || area == auth.AreaTerminal) {
+		c.AbortWithStatusJSON(401, gin.H{"error": "未登录"})
+		return
+	}
+	if ok && !user.Can(area, access) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "没有权限", "permission": auth.Permission(area, access)})
+		return
+	}
+	if token, ok := currentToken(c); ok && !token.Allows(area, access) {
+		c.AbortWithStatusJSON(403, gin.H{"error": "API 令牌的权限范围不足", "permission": auth.Permission(area, access)})
+		return
+	}
+	c.Next()
+}
+
+// commandDeniedError 设置或执行命令被拒绝，携带响应的状态码和内容
+type commandDeniedError struct {
+	status int
+	body   gin.H
+}
+
+func (e *commandDeniedError) Error() string {
+	return fmt.Sprint(e.body["error"])
+}
+
+// commandAccess 设置或手动执行会在服务器上运行的命令（服务启动命令、定时任务）与使用终端等同：
+// 需要 terminal:write 权限，且命令须通过当前角色的终端命令策略
+func commandAccess(c *gin.Context, action, command string) error {
+	permission := auth.Permission(auth.AreaTerminal, auth.AccessWrite)
+	if authManager != nil {
+		user, ok := currentUser(c)
+		if !ok {
+			return &commandDeniedError{401, gin.H{"error": "未登录"}}
+		}
+		if !user.Can(auth.AreaTerminal, auth.AccessWrite) {
+			return &commandDeniedError{403, gin.H{"error": "设置或执行命令需要终端权限", "permission": permission}}
+		}
+		if token, ok := currentToken(c); ok && !token.Allows(auth.AreaTerminal, auth.AccessWrite) {
+			return &commandDeniedError{403, gin.H{"error": "API 令牌的权限范围不足", "permission": permission}}
+		}
+	}
+	config, err := loadTerminalConfig()
+	if err != nil {
+		return &commandDeniedError{500, gin.H{"error": err.Error()}}
+	}
+	// 启动命令和定时任务不经过 shell，直接按参数执行，按 POSIX 规则检查即可
+	if err := terminalPolicy(c, config).Check(command, terminal.ShellPOSIX); err != nil {
+		recordAuditDenied(c, action, command, err.Error())
+		return &commandDeniedError{403, gin.H{"error": err.Error(), "denied": true}}
+	}
+	return nil
+}
+
+// requireCommandAccess 同 commandAccess，拒绝时写入响应并返回 false
+func requireCommandAccess(c *gin.Context, action, command string) bool {
+	if err := commandAccess(c, action, command); err != nil {
+		respondCommandDenied(c, err)
+		return false
+	}
+	return true
+}
+
+// respondCommandDenied 写入 commandAccess 返回的拒绝响应，err 不是拒绝错误时返回 false
+func respondCommandDenied(c *gin.Context, err error) bool {
+	var denied *commandDeniedError
+	if !errors.As(err, &denied) {
+		return false
+	}
+	c.AbortWithStatusJSON(denied.status, denied.body)
+	return true
+}
+
+// RequireAdmin 仅管理员可访问（用户与认证配置管理）
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authManager == nil {
+			c.Next()
+			return
+		}
+		user, ok := currentUser(c)
+		_, viaToken := currentToken(c)
+		if !ok || !user.IsAdmin() || viaToken {
+			c.AbortWithStatusJSON(403, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetMe 当前用户及其可访问的功能区域，供前端隐藏无权限的页面
+func GetMe(c *gin.Context) {
+	if authManager == nil {
+		access := map[string]string{}
+		for _, area := range auth.Areas {
+			access[area] = auth.AccessWrite
+		}
+		c.JSON(200, gin.H{"authenticated": false, "admin": true, "access": access})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "未登录"})
+		return
+	}
+	result := gin.H{
+		"authenticated": true,
+		"user":          user.View(),
+		"admin":         user.IsAdmin(),
+		"access":        user.Access(),
+	}
+	// 使用 API 令牌时返回令牌实际可用的访问级别
+	if token, ok := currentToken(c); ok {
+		access := map[string]string{}
+		for area, level := range user.Access() {
+			if token.Allows(area, level) {
+				access[area] = level
+			} else if token.Allows(area, auth.AccessRead) {
+				access[area] = auth.AccessRead
+			}
+		}
+		result["admin"] = false
+		result["access"] = access
+		result["token"] = token.View()
+	}
+	c.JSON(200, result)
+}
+
+// UpdateUserRole 修改用户角色
+func UpdateUserRole(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, err := authManager.SetRole(c.Param("id"), req.Role, req.Permissions)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, user.View())
+}
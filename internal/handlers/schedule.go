@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"homedash/internal/cron"
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const scheduleRunHistoryLimit = 500 // 保留的执行记录条数
+
+var (
+	scheduleRunsMu sync.Mutex // 保护 schedule-runs 文档的读取-修改-保存
+	schedulerOnce  sync.Once
+)
+
+// StartServiceScheduler 启动服务定时任务调度器（与任务调度器共用整分钟时钟）
+func StartServiceScheduler() {
+	schedulerOnce.Do(func() {
+		cron.EveryMinute(tickServiceSchedules)
+	})
+}
+
+// tickServiceSchedules 处理维护到期并执行命中的定时任务
+func tickServiceSchedules(now time.Time) {
+	expireMaintenance(now)
+	services := loadServices()
+
+	for _, service := range services {
+		for _, sch := range service.Schedules {
+			if !sch.Enabled {
+				continue
+			}
+			schedule, err := cron.Parse(sch.Cron)
+			if err != nil {
+				log.Printf("服务 %s 的定时任务 %s 表达式无效: %v", service.ID, sch.ID, err)
+				continue
+			}
+			if !schedule.Matches(now) {
+				continue
+			}
+			// 停止进程最多等待数秒，避免阻塞其他服务
+			go executeScheduledAction(service, sch, now)
+		}
+	}
+}
+
+// expireMaintenance 关闭已到期的维护模式
+func expireMaintenance(now time.Time) {
+	_, err := serviceStore.Update(func(services []ServiceCard) ([]ServiceCard, error) {
+		for i := range services {
+			m := services[i].Maintenance
+			if m != nil && m.Enabled && m.Until > 0 && now.UnixMilli() >= m.Until {
+				log.Printf("服务 %s 维护模式已到期", services[i].ID)
+				services[i].Maintenance = nil
+				services[i].UpdatedAt = now.UnixMilli()
+			}
+		}
+		return services, nil
+	})
+	if err != nil {
+		log.Printf("保存维护状态失败: %v", err)
+	}
+}
+
+// isInMaintenance 判断服务当前是否处于维护模式
+func isInMaintenance(service *ServiceCard, now time.Time) bool {
+	m := service.Maintenance
+	if m == nil || !m.Enabled {
+		return false
+	}
+	return m.Until == 0 || now.UnixMilli() < m.Until
+}
+
+// executeScheduledAction 执行一次定时任务并记录结果
+func executeScheduledAction(service ServiceCard, sch ServiceSchedule, now time.Time) {
+	run := ScheduleRun{
+		ServiceID:  service.ID,
+		ScheduleID: sch.ID,
+		Action:     sch.Action,
+		StartedAt:  time.Now().UnixMilli(),
+	}
+
+	if isInMaintenance(&service, now) {
+		run.Status = "skipped"
+		run.Message = "服务处于维护模式"
+	} else if msg, err := runServiceAction(&service, sch.Action); err != nil {
+		run.Status = "failed"
+		run.Message = err.Error()
+		log.Printf("服务 %s 定时%s失败: %v", service.ID, sch.Action, err)
+	} else {
+		run.Status = "success"
+		run.Message = msg
+	}
+
+	run.FinishedAt = time.Now().UnixMilli()
+	recordScheduleRun(run)
+}
+
+// runServiceAction 执行启动/停止/重启动作
+func runServiceAction(service *ServiceCard, action string) (string, error) {
+	if service.LaunchCommand == "" && service.LaunchPath == "" {
+		return "", fmt.Errorf("服务未配置启动命令或启动路径")
+	}
+
+	switch action {
+	case "start":
+		status := checkServiceProcess(service.ProcessName, service.LaunchPath, service.LaunchCommand)
+		if status.Running {
+			return "进程已在运行", nil
+		}
+		cgroupStatus, err := launchService(service)
+		if err != nil {
+			return "", err
+		}
+		if cgroupStatus != nil && !cgroupStatus.Applied {
+			return cgroupStatus.Message, nil
+		}
+		return "已启动", nil
+	case "stop":
+		running, err := stopService(service)
+		if err != nil {
+			return "", err
+		}
+		if !running {
+			return "进程未运行", nil
+		}
+		return "已停止", nil
+	case "restart":
+		// stopService 等待旧进程退出后才返回，避免新进程与旧进程争用端口或文件
+		if _, err := stopService(service); err != nil {
+			return "", err
+		}
+		if _, err := launchService(service); err != nil {
+			return "", err
+		}
+		return "已重启", nil
+	default:
+		return "", fmt.Errorf("未知的动作: %s", action)
+	}
+}
+
+// loadScheduleRuns 读取执行记录（按执行顺序），不存在时返回空列表；调用方需持有 scheduleRunsMu
+func loadScheduleRuns() ([]ScheduleRun, error) {
+	var runs []ScheduleRun
+	data, err := backend.Load(storage.KeyScheduleRuns)
+	if errors.Is(err, storage.ErrNotFound) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("定时任务执行记录无法解析: %v", err)
+	}
+	return runs, nil
+}
+
+// recordScheduleRun 将执行记录写入存储后端，超出上限时丢弃最旧的
+func recordScheduleRun(run ScheduleRun) {
+	scheduleRunsMu.Lock()
+	defer scheduleRunsMu.Unlock()
+
+	runs, err := loadScheduleRuns()
+	if err != nil {
+		log.Printf("读取定时任务执行记录失败: %v", err)
+		return
+	}
+	runs = append(runs, run)
+	if len(runs) > scheduleRunHistoryLimit {
+		runs = runs[len(runs)-scheduleRunHistoryLimit:]
+	}
+	data, err := json.Marshal(runs)
+	if err == nil {
+		err = backend.Save(storage.KeyScheduleRuns, data)
+	}
+	if err != nil {
+		log.Printf("保存定时任务执行记录失败: %v", err)
+	}
+}
+
+// assignScheduleIDs 为新增的定时任务生成 ID
+func assignScheduleIDs(service *ServiceCard) {
+	for i := range service.Schedules {
+		if service.Schedules[i].ID == "" {
+			service.Schedules[i].ID = uuid.New().String()[:8]
+		}
+	}
+}
+
+// GetScheduleRuns 获取定时任务执行记录（最新的在前）
+func GetScheduleRuns(c *gin.Context) {
+	serviceID := c.Query("service")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	scheduleRunsMu.Lock()
+	history, err := loadScheduleRuns()
+	scheduleRunsMu.Unlock()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs := make([]ScheduleRun, 0)
+	for i := len(history) - 1; i >= 0 && len(runs) < limit; i-- {
+		if serviceID != "" && history[i].ServiceID != serviceID {
+			continue
+		}
+		runs = append(runs, history[i])
+	}
+
+	c.JSON(200, runs)
+}
+
+// GetServiceSchedules 获取服务定时任务及下次执行时间
+func GetServiceSchedules(c *gin.Context) {
+	id := c.Param("id")
+	services := loadServices()
+	var service *ServiceCard
+	for i := range services {
+		if services[i].ID == id {
+			service = &services[i]
+			break
+		}
+	}
+
+	if service == nil {
+		c.JSON(404, gin.H{"error": "服务不存在"})
+		return
+	}
+
+	now := time.Now()
+	schedules := make([]gin.H, 0, len(service.Schedules))
+	for _, sch := range service.Schedules {
+		item := gin.H{
+			"id":      sch.ID,
+			"action":  sch.Action,
+			"cron":    sch.Cron,
+			"enabled": sch.Enabled,
+		}
+		if schedule, err := cron.Parse(sch.Cron); err == nil {
+			if next := schedule.Next(now); !next.IsZero() {
+				item["nextRun"] = next.UnixMilli()
+			}
+		}
+		schedules = append(schedules, item)
+	}
+
+	c.JSON(200, gin.H{
+		"schedules":   schedules,
+		"maintenance": isInMaintenance(service, now),
+	})
+}
+
+// UpdateServiceMaintenance 开启或关闭服务维护模式
+func UpdateServiceMaintenance(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Enabled  bool   `json:"enabled"`
+		Duration int    `json:"duration"` // 持续时间（分钟），0 表示手动关闭
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求"})
+		return
+	}
+	if req.Duration < 0 {
+		c.JSON(400, gin.H{"error": "持续时间无效"})
+		return
+	}
+
+	now := time.Now()
+	service, err := serviceStore.Modify(id, AnyVersion, func(service *ServiceCard) error {
+		if req.Enabled {
+			service.Maintenance = &MaintenanceMode{Enabled: true, Reason: req.Reason}
+			if req.Duration > 0 {
+				service.Maintenance.Until = now.Add(time.Duration(req.Duration) * time.Minute).UnixMilli()
+			}
+		} else {
+			service.Maintenance = nil
+		}
+		service.UpdatedAt = now.UnixMilli()
+		return nil
+	})
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	c.JSON(200, service)
+}
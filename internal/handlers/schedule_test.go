@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestScheduleRunsPersisted 执行记录写入存储后端，重新打开后仍可查询
+func TestScheduleRunsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	InitStorage(storage.NewJSONBackend(dir, nil))
+	for i := 0; i < scheduleRunHistoryLimit+5; i++ {
+		serviceID := "a"
+		if i%2 == 1 {
+			serviceID = "b"
+		}
+		recordScheduleRun(ScheduleRun{ServiceID: serviceID, Action: "start", Status: "success", StartedAt: int64(i)})
+	}
+
+	// 模拟重启：重新打开存储
+	InitStorage(storage.NewJSONBackend(dir, nil))
+	runs, err := loadScheduleRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != scheduleRunHistoryLimit {
+		t.Fatalf("保留 %d 条记录, want %d", len(runs), scheduleRunHistoryLimit)
+	}
+	if runs[0].StartedAt != 5 {
+		t.Errorf("最旧的记录 = %d, want 5", runs[0].StartedAt)
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/schedules/runs?service=b&limit=3", nil)
+	GetScheduleRuns(c)
+	var got []ScheduleRun
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	last := int64(scheduleRunHistoryLimit + 3) // 最后一条 b 的记录
+	want := []int64{last, last - 2, last - 4}
+	if len(got) != len(want) {
+		t.Fatalf("返回 %d 条记录, want %d", len(got), len(want))
+	}
+	for i, run := range got {
+		if run.ServiceID != "b" || run.StartedAt != want[i] {
+			t.Errorf("第 %d 条 = %s/%d, want b/%d", i, run.ServiceID, run.StartedAt, want[i])
+		}
+	}
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
 	"sync"
 	"time"
 
+	"homedash/internal/storage"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -27,8 +29,8 @@ var defaultServiceTemplates = []ServiceCard{
 
 // InitDefaultServices 初始化默认服务列表
 func InitDefaultServices() {
-	if _, err := os.Stat(servicesFile); err == nil {
-		return // 文件已存在
+	if data, err := loadConfig(storage.KeyServices); err != nil || data != nil {
+		return // 已存在（或无法读取，不覆盖）
 	}
 
 	defaultServices := []ServiceCard{
@@ -43,15 +45,36 @@ func InitDefaultServices() {
 		{ID: "moonlight", Name: "Moonlight", Description: "游戏串流客户端", Port: 0, Icon: "🌙", Enabled: false, CreatedAt: time.Now().UnixMilli()},
 	}
 
-	saveServices(defaultServices)
+	serviceStore.Update(func([]ServiceCard) ([]ServiceCard, error) {
+		return defaultServices, nil
+	})
 }
 
 // GetServices 获取服务列表
 func GetServices(c *gin.Context) {
-	services := loadServices()
+	services, err := serviceStore.List()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error(), "corrupt": true})
+		return
+	}
 	c.JSON(200, services)
 }
 
+// GetService 获取单个服务（ETag 为版本号，可用于 If-Match）
+func GetService(c *gin.Context) {
+	service, err := serviceStore.Get(c.Param("id"))
+	if errors.Is(err, ErrServiceNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	setServiceETag(c, service)
+	c.JSON(200, service)
+}
+
 // CreateService 创建服务
 func CreateService(c *gin.Context) {
 	var service ServiceCard
@@ -65,22 +88,25 @@ func CreateService(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if command := launchCommandOf(&service); command != "" && !requireCommandAccess(c, "service.launchCommand", command) {
+		return
+	}
 
 	// 生成 ID 和时间戳
 	service.ID = uuid.New().String()[:8]
+	assignScheduleIDs(&service)
 	service.CreatedAt = time.Now().UnixMilli()
 	service.UpdatedAt = service.CreatedAt
 	service.Enabled = true
 
-	services := loadServices()
-	services = append(services, service)
-
-	if err := saveServices(services); err != nil {
-		c.JSON(500, gin.H{"error": "保存失败"})
+	created, err := serviceStore.Create(service)
+	if err != nil {
+		respondStoreError(c, err)
 		return
 	}
 
-	c.JSON(200, service)
+	setServiceETag(c, created)
+	c.JSON(200, created)
 }
 
 // UpdateService 更新服务
@@ -98,54 +124,57 @@ func UpdateService(c *gin.Context) {
 		return
 	}
 
-	services := loadServices()
-	found := false
-	for i, s := range services {
-		if s.ID == id {
-			updated.ID = id
-			updated.CreatedAt = s.CreatedAt
-			updated.UpdatedAt = time.Now().UnixMilli()
-			services[i] = updated
-			found = true
-			break
-		}
+	// If-Match 优先，其次使用请求体中的版本号（未提供时不检查）
+	fallback := AnyVersion
+	if updated.Version > 0 {
+		fallback = updated.Version
+	}
+	expected, ok := expectedVersion(c, fallback)
+	if !ok {
+		return
 	}
 
-	if !found {
-		c.JSON(404, gin.H{"error": "服务不存在"})
+	// 修改启动命令与使用终端等同。在加锁之外检查（读取终端配置、写审计日志），
+	// 保存时服务已被其他请求修改则返回冲突，检查结果只对读取时的版本有效
+	current, err := serviceStore.Get(id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	if command := launchCommandOf(&updated); command != "" && command != launchCommandOf(&current) &&
+		!requireCommandAccess(c, "service.launchCommand", command) {
 		return
 	}
 
-	if err := saveServices(services); err != nil {
-		c.JSON(500, gin.H{"error": "保存失败"})
+	result, err := serviceStore.Modify(id, expected, func(service *ServiceCard) error {
+		if service.Version != current.Version {
+			return ErrVersionConflict
+		}
+		updated.ID = id
+		assignScheduleIDs(&updated)
+		updated.CreatedAt = service.CreatedAt
+		updated.UpdatedAt = time.Now().UnixMilli()
+		*service = updated
+		return nil
+	})
+	if err != nil {
+		respondStoreError(c, err)
 		return
 	}
 
-	c.JSON(200, updated)
+	setServiceETag(c, result)
+	c.JSON(200, result)
 }
 
 // DeleteService 删除服务
 func DeleteService(c *gin.Context) {
-	id := c.Param("id")
-	services := loadServices()
-	newServices := make([]ServiceCard, 0)
-	found := false
-
-	for _, s := range services {
-		if s.ID == id {
-			found = true
-		} else {
-			newServices = append(newServices, s)
-		}
-	}
-
-	if !found {
-		c.JSON(404, gin.H{"error": "服务不存在"})
+	expected, ok := expectedVersion(c, AnyVersion)
+	if !ok {
 		return
 	}
 
-	if err := saveServices(newServices); err != nil {
-		c.JSON(500, gin.H{"error": "保存失败"})
+	if err := serviceStore.Delete(c.Param("id"), expected); err != nil {
+		respondStoreError(c, err)
 		return
 	}
 
@@ -154,28 +183,28 @@ func DeleteService(c *gin.Context) {
 
 // ImportServiceTemplate 导入推荐模板
 func ImportServiceTemplate(c *gin.Context) {
-	services := loadServices()
 	now := time.Now().UnixMilli()
-
-	for _, tmpl := range defaultServiceTemplates {
-		// 检查是否已存在同名服务
-		exists := false
-		for _, s := range services {
-			if s.ID == tmpl.ID || s.Name == tmpl.Name {
-				exists = true
-				break
+	services, err := serviceStore.Update(func(services []ServiceCard) ([]ServiceCard, error) {
+		for _, tmpl := range defaultServiceTemplates {
+			// 检查是否已存在同名服务
+			exists := false
+			for _, s := range services {
+				if s.ID == tmpl.ID || s.Name == tmpl.Name {
+					exists = true
+					break
+				}
+			}
+			if !exists {
+				newService := tmpl
+				newService.CreatedAt = now
+				newService.UpdatedAt = now
+				services = append(services, newService)
 			}
 		}
-		if !exists {
-			newService := tmpl
-			newService.CreatedAt = now
-			newService.UpdatedAt = now
-			services = append(services, newService)
-		}
-	}
-
-	if err := saveServices(services); err != nil {
-		c.JSON(500, gin.H{"error": "保存失败"})
+		return services, nil
+	})
+	if err != nil {
+		respondStoreError(c, err)
 		return
 	}
 
@@ -201,10 +230,16 @@ func PingAllServices(c *gin.Context) {
 	}
 	resultChan := make(chan pingResultWrapper, len(services))
 
+	now := time.Now()
 	for _, s := range services {
 		if !s.Enabled || s.Port == 0 {
 			continue
 		}
+		// 维护中的服务不做检测，避免误报
+		if isInMaintenance(&s, now) {
+			resultChan <- pingResultWrapper{result: maintenancePingResult(&s), service: s}
+			continue
+		}
 		wg.Add(1)
 		go func(service ServiceCard) {
 			defer wg.Done()
@@ -276,10 +311,28 @@ func PingService(c *gin.Context) {
 		return
 	}
 
+	if isInMaintenance(targetService, time.Now()) {
+		c.JSON(200, maintenancePingResult(targetService))
+		return
+	}
+
 	result := pingService(id, serverIP, targetService.Port)
 	c.JSON(200, result)
 }
 
+// maintenancePingResult 维护模式下的检测结果
+func maintenancePingResult(service *ServiceCard) PingResult {
+	message := "维护中"
+	if service.Maintenance.Reason != "" {
+		message = "维护中: " + service.Maintenance.Reason
+	}
+	return PingResult{
+		ID:      service.ID,
+		Status:  "maintenance",
+		Message: message,
+	}
+}
+
 // pingService 检测服务连通性（带超时控制）
 func pingService(id, host string, port int) PingResult {
 	result := PingResult{
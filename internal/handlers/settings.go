@@ -58,7 +58,11 @@ func getBackgroundList(webDir string) []BackgroundInfo {
 
 // GetSettings 获取用户设置
 func GetSettings(c *gin.Context) {
-	settings := loadSettings()
+	settings, err := loadSettingsChecked()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error(), "corrupt": true})
+		return
+	}
 	c.JSON(200, settings)
 }
 
@@ -76,48 +80,17 @@ func UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	// 如果设置了新的 WebDAV 根目录，更新全局变量
-	if settings.WebdavRoot != "" {
-		webdavRoot = settings.WebdavRoot
-	}
+	// 挂载点通过 /api/mounts 修改，这里保留已保存的值，避免页面提交较早加载的设置时将其覆盖
+	saved := loadSettings()
+	settings.Mounts, settings.WebdavRoot = saved.Mounts, saved.WebdavRoot
 
 	if err := saveSettings(settings); err != nil {
-		c.JSON(500, gin.H{"error": "保存设置失败"})
+		respondSaveError(c, "保存设置失败", err)
 		return
 	}
 	c.JSON(200, gin.H{"success": true})
 }
 
-// GetWebdavRoot 获取WebDAV根目录
-func GetWebdavRoot(c *gin.Context) {
-	c.JSON(200, gin.H{"root": webdavRoot})
-}
-
-// UpdateWebdavRoot 更新WebDAV根目录
-func UpdateWebdavRoot(c *gin.Context) {
-	var req struct {
-		Root string `json:"root"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "无效的请求"})
-		return
-	}
-
-	// 验证路径是否存在
-	if _, err := os.Stat(req.Root); os.IsNotExist(err) {
-		c.JSON(400, gin.H{"error": "目录不存在"})
-		return
-	}
-
-	// 更新全局变量和设置
-	webdavRoot = req.Root
-	settings := loadSettings()
-	settings.WebdavRoot = req.Root
-	saveSettings(settings)
-
-	c.JSON(200, gin.H{"success": true, "root": webdavRoot})
-}
-
 // GetAppConfig 获取应用配置
 func GetAppConfig(c *gin.Context, port string) {
 	config := AppConfig{
@@ -162,17 +135,9 @@ func UpdateServiceAutoStart(c *gin.Context) {
 		return
 	}
 
-	services := loadServices()
-	var service *ServiceCard
-	for i := range services {
-		if services[i].ID == id {
-			service = &services[i]
-			break
-		}
-	}
-
-	if service == nil {
-		c.JSON(404, gin.H{"error": "服务不存在"})
+	service, err := serviceStore.Get(id)
+	if err != nil {
+		respondStoreError(c, err)
 		return
 	}
 
@@ -187,14 +152,14 @@ func UpdateServiceAutoStart(c *gin.Context) {
 	}
 
 	// 更新服务配置
-	service.AutoStart = req.AutoStart
-	for i := range services {
-		if services[i].ID == id {
-			services[i] = *service
-			break
-		}
+	_, err = serviceStore.Modify(id, AnyVersion, func(service *ServiceCard) error {
+		service.AutoStart = req.AutoStart
+		return nil
+	})
+	if err != nil {
+		respondStoreError(c, err)
+		return
 	}
-	saveServices(services)
 
 	c.JSON(200, gin.H{"success": true})
 }
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+
+	"homedash/internal/sftpd"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	sftpServer *sftpd.Server
+	sftpKeys   *sftpd.Keys
+)
+
+// InitSFTP 初始化 SFTP 服务器和公钥，server 为 nil 表示未启用
+func InitSFTP(server *sftpd.Server, keys *sftpd.Keys) {
+	sftpServer = server
+	sftpKeys = keys
+}
+
+// GetSFTPStatus 获取 SFTP 连接信息
+func GetSFTPStatus(c *gin.Context) {
+	if sftpServer == nil {
+		c.JSON(200, gin.H{"enabled": false})
+		return
+	}
+	_, port, _ := net.SplitHostPort(sftpServer.Addr())
+	user, _ := currentUser(c)
+	c.JSON(200, gin.H{
+		"enabled":     true,
+		"port":        port,
+		"fingerprint": sftpServer.Fingerprint(),
+		"username":    user.Username,
+	})
+}
+
+// requireSFTPKeys 检查公钥存储已初始化
+func requireSFTPKeys(c *gin.Context) bool {
+	if sftpKeys == nil {
+		c.JSON(500, gin.H{"error": "SFTP 服务未初始化"})
+		return false
+	}
+	return true
+}
+
+// GetSFTPKeys 获取当前用户的 SFTP 公钥
+func GetSFTPKeys(c *gin.Context) {
+	if !requireSFTPKeys(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	c.JSON(200, sftpKeys.List(user.ID))
+}
+
+// AddSFTPKey 添加 SFTP 公钥
+func AddSFTPKey(c *gin.Context) {
+	if !requireSFTPKeys(c) {
+		return
+	}
+	var req struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"publicKey"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(400, gin.H{"error": "启用登录认证后才能添加公钥"})
+		return
+	}
+	key, err := sftpKeys.Add(user.ID, req.Name, req.PublicKey)
+	if err != nil {
+		status := 400
+		if errors.Is(err, sftpd.ErrKeyDuplicate) {
+			status = 409
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	setAuditTarget(c, key.Name+" ("+key.Fingerprint+")")
+	c.JSON(200, key)
+}
+
+// DeleteSFTPKey 删除 SFTP 公钥
+func DeleteSFTPKey(c *gin.Context) {
+	if !requireSFTPKeys(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	for _, key := range sftpKeys.List(user.ID) {
+		if key.ID == c.Param("id") {
+			setAuditTarget(c, key.Name+" ("+key.Fingerprint+")")
+		}
+	}
+	if err := sftpKeys.Delete(user.ID, c.Param("id")); err != nil {
+		status := 500
+		if errors.Is(err, sftpd.ErrKeyNotFound) {
+			status = 404
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"homedash/internal/sshterm"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	sshHosts      *sshterm.Hosts
+	sshKnownHosts *sshterm.KnownHosts
+)
+
+// InitSSH 初始化 SSH 主机配置和 known_hosts
+func InitSSH(hosts *sshterm.Hosts, known *sshterm.KnownHosts) {
+	sshHosts = hosts
+	sshKnownHosts = known
+}
+
+// sshHostRequest 新建和修改主机的请求，修改时密码和私钥留空表示不变
+type sshHostRequest struct {
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	User       string `json:"user"`
+	AuthType   string `json:"authType"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"privateKey"`
+	Passphrase string `json:"passphrase"`
+}
+
+func (r sshHostRequest) host() sshterm.Host {
+	return sshterm.Host{
+		Name:       r.Name,
+		Host:       r.Host,
+		Port:       r.Port,
+		User:       r.User,
+		AuthType:   r.AuthType,
+		Password:   r.Password,
+		PrivateKey: r.PrivateKey,
+		Passphrase: r.Passphrase,
+	}
+}
+
+// requireSSH 检查 SSH 功能已初始化
+func requireSSH(c *gin.Context) bool {
+	if sshHosts == nil || sshKnownHosts == nil {
+		c.JSON(500, gin.H{"error": "SSH 服务未初始化"})
+		return false
+	}
+	return true
+}
+
+// GetSSHHosts 获取当前用户保存的 SSH 主机
+func GetSSHHosts(c *gin.Context) {
+	if !requireSSH(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	views := []sshterm.HostView{}
+	for _, host := range sshHosts.List(user.ID) {
+		views = append(views, host.View())
+	}
+	c.JSON(200, views)
+}
+
+// CreateSSHHost 保存 SSH 主机
+func CreateSSHHost(c *gin.Context) {
+	if !requireSSH(c) {
+		return
+	}
+	var req sshHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, _ := currentUser(c)
+	host, err := sshHosts.Create(user.ID, req.host())
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	setAuditTarget(c, host.Name+" ("+host.Label()+")")
+	c.JSON(200, host.View())
+}
+
+// UpdateSSHHost 修改 SSH 主机
+func UpdateSSHHost(c *gin.Context) {
+	if !requireSSH(c) {
+		return
+	}
+	var req sshHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, _ := currentUser(c)
+	host, err := sshHosts.Update(user.ID, c.Param("id"), req.host())
+	if errors.Is(err, sshterm.ErrHostNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	setAuditTarget(c, host.Name+" ("+host.Label()+")")
+	c.JSON(200, host.View())
+}
+
+// DeleteSSHHost 删除 SSH 主机
+func DeleteSSHHost(c *gin.Context) {
+	if !requireSSH(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	if host, err := sshHosts.Get(user.ID, c.Param("id")); err == nil {
+		setAuditTarget(c, host.Name+" ("+host.Label()+")")
+	}
+	if err := sshHosts.Delete(user.ID, c.Param("id")); err != nil {
+		status := 500
+		if errors.Is(err, sshterm.ErrHostNotFound) {
+			status = 404
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// TrustSSHHost 信任主机当前的密钥。请求需带上用户核对过的指纹，
+// 服务端重新获取主机密钥，指纹一致时才写入 known_hosts，避免信任了连接期间被替换的密钥
+func TrustSSHHost(c *gin.Context) {
+	if !requireSSH(c) {
+		return
+	}
+	var req struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Fingerprint == "" {
+		c.JSON(400, gin.H{"error": "请提供主机密钥指纹"})
+		return
+	}
+	user, _ := currentUser(c)
+	host, err := sshHosts.Get(user.ID, c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	setAuditTarget(c, host.Label()+" "+req.Fingerprint)
+
+	key, err := sshterm.ScanHostKey(host.Address())
+	if err != nil {
+		c.JSON(502, gin.H{"error": "获取主机密钥失败: " + err.Error()})
+		return
+	}
+	if fingerprint := ssh.FingerprintSHA256(key); fingerprint != strings.TrimSpace(req.Fingerprint) {
+		c.JSON(409, gin.H{"error": "主机密钥已变化（当前为 " + fingerprint + "），请重新核对", "fingerprint": fingerprint})
+		return
+	}
+	if err := sshKnownHosts.Trust(host.Address(), key); err != nil {
+		c.JSON(500, gin.H{"error": "写入 known_hosts 失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnyVersion 不做版本检查
+const AnyVersion int64 = -1
+
+var (
+	ErrServiceNotFound = errors.New("服务不存在")
+	ErrVersionConflict = errors.New("服务已被其他人修改，请刷新后重试")
+)
+
+// ServiceStore 服务列表存储
+// 所有修改都在同一把锁内完成「读取 - 修改 - 写入」，并发请求不会互相覆盖；
+// 每个服务带有版本号，内容变化时自动递增，用于乐观并发控制
+type ServiceStore struct{}
+
+var serviceStore = &ServiceStore{}
+
+// readServicesLocked 读取服务列表（文件被改坏时使用最近一次有效的内容），调用方需持有 servicesMu
+func readServicesLocked() ([]ServiceCard, error) {
+	var services []ServiceCard
+	data, err := loadGoodConfig(storage.KeyServices, validateServicesJSON)
+	if err != nil || data == nil {
+		return services, err
+	}
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, &ConfigCorruptError{File: configDisplayName(storage.KeyServices), Err: describeJSONError(data, err)}
+	}
+	return services, nil
+}
+
+// List 返回全部服务
+func (s *ServiceStore) List() ([]ServiceCard, error) {
+	servicesMu.RLock()
+	defer servicesMu.RUnlock()
+	return readServicesLocked()
+}
+
+// Get 返回单个服务
+func (s *ServiceStore) Get(id string) (ServiceCard, error) {
+	services, err := s.List()
+	if err != nil {
+		return ServiceCard{}, err
+	}
+	for _, service := range services {
+		if service.ID == id {
+			return service, nil
+		}
+	}
+	return ServiceCard{}, ErrServiceNotFound
+}
+
+// Update 在事务中修改服务列表：fn 收到当前列表，返回新列表或错误（返回错误时不写入）
+// 内容有变化的服务版本号加一，新服务版本号为 1；列表没有任何变化时不写文件
+func (s *ServiceStore) Update(fn func(services []ServiceCard) ([]ServiceCard, error)) ([]ServiceCard, error) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+
+	current, err := readServicesLocked()
+	if err != nil {
+		return nil, err
+	}
+	before, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// 传入副本，fn 出错时不影响当前数据
+	working := make([]ServiceCard, len(current))
+	copy(working, current)
+	updated, err := fn(working)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]ServiceCard, len(current))
+	for _, service := range current {
+		previous[service.ID] = service
+	}
+	for i := range updated {
+		old, ok := previous[updated[i].ID]
+		if !ok {
+			updated[i].Version = 1
+			continue
+		}
+		updated[i].Version = old.Version
+		if !sameServiceContent(old, updated[i]) {
+			updated[i].Version++
+		}
+	}
+
+	after, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(before, after) {
+		return updated, nil
+	}
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeVersionedConfig(storage.KeyServices, data, checkServicesJSON); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// sameServiceContent 比较两个服务的内容（忽略版本号）
+func sameServiceContent(a, b ServiceCard) bool {
+	a.Version, b.Version = 0, 0
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// Create 追加一个服务
+func (s *ServiceStore) Create(service ServiceCard) (ServiceCard, error) {
+	var created ServiceCard
+	_, err := s.Update(func(services []ServiceCard) ([]ServiceCard, error) {
+		return append(services, service), nil
+	})
+	if err == nil {
+		created = service
+		created.Version = 1
+	}
+	return created, err
+}
+
+// Modify 修改单个服务；expected 不为 AnyVersion 时要求当前版本号一致，否则返回 ErrVersionConflict
+func (s *ServiceStore) Modify(id string, expected int64, fn func(service *ServiceCard) error) (ServiceCard, error) {
+	index := -1
+	services, err := s.Update(func(services []ServiceCard) ([]ServiceCard, error) {
+		for i := range services {
+			if services[i].ID != id {
+				continue
+			}
+			if expected != AnyVersion && services[i].Version != expected {
+				return nil, ErrVersionConflict
+			}
+			if err := fn(&services[i]); err != nil {
+				return nil, err
+			}
+			services[i].ID = id
+			index = i
+			return services, nil
+		}
+		return nil, ErrServiceNotFound
+	})
+	if err != nil {
+		return ServiceCard{}, err
+	}
+	return services[index], nil
+}
+
+// Delete 删除单个服务；expected 含义同 Modify
+func (s *ServiceStore) Delete(id string, expected int64) error {
+	_, err := s.Update(func(services []ServiceCard) ([]ServiceCard, error) {
+		for i := range services {
+			if services[i].ID != id {
+				continue
+			}
+			if expected != AnyVersion && services[i].Version != expected {
+				return nil, ErrVersionConflict
+			}
+			return append(services[:i], services[i+1:]...), nil
+		}
+		return nil, ErrServiceNotFound
+	})
+	return err
+}
+
+// expectedVersion 读取 If-Match 请求头（如 "3" 或 3），未提供或为 * 时返回 fallback
+func expectedVersion(c *gin.Context, fallback int64) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return fallback, true
+	}
+	header = strings.TrimPrefix(header, "W/")
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version < 0 {
+		c.JSON(400, gin.H{"error": "无效的 If-Match 版本号"})
+		return 0, false
+	}
+	return version, true
+}
+
+// setServiceETag 在响应头中返回服务版本号
+func setServiceETag(c *gin.Context, service ServiceCard) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(service.Version, 10)))
+}
+
+// respondStoreError 将存储错误转换为响应
+func respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrServiceNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrVersionConflict):
+		body := gin.H{"error": err.Error()}
+		if current, getErr := serviceStore.Get(c.Param("id")); getErr == nil {
+			body["current"] = current
+			setServiceETag(c, current)
+		}
+		c.JSON(409, body)
+	default:
+		respondSaveError(c, "保存失败", err)
+	}
+}
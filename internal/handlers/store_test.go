@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTempStorage 使用临时目录作为 JSON 后端
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	InitStorage(storage.NewJSONBackend(t.TempDir(), nil))
+	configHashMu.Lock()
+	lastGood = make(map[string][]byte)
+	configHashMu.Unlock()
+}
+
+func TestServiceStoreConcurrentCreate(t *testing.T) {
+	useTempStorage(t)
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			service := ServiceCard{ID: fmt.Sprintf("svc-%d", i), Name: fmt.Sprintf("服务 %d", i)}
+			if _, err := serviceStore.Create(service); err != nil {
+				t.Errorf("Create(%s): %v", service.ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	services, err := serviceStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != n {
+		t.Fatalf("保存了 %d 个服务, want %d", len(services), n)
+	}
+	seen := make(map[string]bool)
+	for _, service := range services {
+		if service.Version != 1 {
+			t.Errorf("%s version = %d, want 1", service.ID, service.Version)
+		}
+		seen[service.ID] = true
+	}
+	if len(seen) != n {
+		t.Errorf("服务 ID 重复或丢失: %v", seen)
+	}
+}
+
+func TestServiceStoreConcurrentModify(t *testing.T) {
+	useTempStorage(t)
+	if _, err := serviceStore.Create(ServiceCard{ID: "counter", Name: "计数"}); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 30
+	versions := make(chan int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service, err := serviceStore.Modify("counter", AnyVersion, func(service *ServiceCard) error {
+				service.Port++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Modify: %v", err)
+				return
+			}
+			versions <- service.Version
+		}()
+	}
+	wg.Wait()
+	close(versions)
+
+	// 每次修改得到不同的版本号，且都大于初始版本
+	seen := make(map[int64]bool)
+	for v := range versions {
+		if v <= 1 || seen[v] {
+			t.Errorf("版本号 %d 重复或未递增", v)
+		}
+		seen[v] = true
+	}
+
+	service, err := serviceStore.Get("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Port != n {
+		t.Errorf("port = %d, want %d（有修改丢失）", service.Port, n)
+	}
+	if service.Version != n+1 {
+		t.Errorf("version = %d, want %d", service.Version, n+1)
+	}
+}
+
+func TestServiceStoreVersionCheck(t *testing.T) {
+	useTempStorage(t)
+	if _, err := serviceStore.Create(ServiceCard{ID: "a", Name: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rename := func(name string) func(*ServiceCard) error {
+		return func(service *ServiceCard) error {
+			service.Name = name
+			return nil
+		}
+	}
+	if _, err := serviceStore.Modify("a", 1, rename("B")); err != nil {
+		t.Fatalf("Modify(version 1): %v", err)
+	}
+	if _, err := serviceStore.Modify("a", 1, rename("C")); err != ErrVersionConflict {
+		t.Fatalf("过期版本: err = %v, want ErrVersionConflict", err)
+	}
+	// 内容没有变化时版本号不变
+	service, err := serviceStore.Modify("a", 2, rename("B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Version != 2 {
+		t.Errorf("未变化时 version = %d, want 2", service.Version)
+	}
+}
+
+func TestUpdateServiceStaleIfMatch(t *testing.T) {
+	useTempStorage(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/services/:id", UpdateService)
+
+	if _, err := serviceStore.Create(ServiceCard{ID: "a", Name: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(ifMatch, name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/services/a", strings.NewReader(`{"name":"`+name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := put(`"1"`, "B"); w.Code != 200 {
+		t.Fatalf("If-Match 1: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	w := put(`"1"`, "C")
+	if w.Code != 409 {
+		t.Fatalf("过期的 If-Match: status = %d, want 409", w.Code)
+	}
+	var body struct {
+		Current ServiceCard `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Current.Version != 2 || body.Current.Name != "B" {
+		t.Errorf("current = %+v, want version 2 name B", body.Current)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, want \"2\"", etag)
+	}
+
+	service, _ := serviceStore.Get("a")
+	if service.Name != "B" {
+		t.Errorf("过期的修改被写入: name = %s", service.Name)
+	}
+}
@@ -38,6 +38,7 @@ func LoadTemplates() (*template.Template, error) {
 			filepath.Join(templateDir, "pages", "docker.html"),
 			filepath.Join(templateDir, "pages", "comfyui.html"),
 			filepath.Join(templateDir, "pages", "settings.html"),
+			filepath.Join(templateDir, "pages", "login.html"),
 		}
 
 		templateCache, err = template.New("master.html").
@@ -1,25 +1,36 @@
 package handlers
 
 import (
-	"fmt"
-	"net/http"
-	"os/exec"
-	"runtime"
-	"strings"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"homedash/internal/sshterm"
+	"homedash/internal/terminal"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
 var termUpgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin:     checkWSOrigin,
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
+// terminalMessage 浏览器发送的控制消息（文本帧）；键盘输入以二进制帧发送
+type terminalMessage struct {
+	Type    string `json:"type"` // input | resize | signal；行模式为 command | cancel
+	Data    string `json:"data,omitempty"`
+	Cols    uint16 `json:"cols,omitempty"`
+	Rows    uint16 `json:"rows,omitempty"`
+	Signal  string `json:"signal,omitempty"`  // INT | TERM | KILL
+	Timeout int    `json:"timeout,omitempty"` // 行模式命令的超时秒数
+}
+
 // HandleTerminalWebSocket 处理终端WebSocket连接
+// 默认连接到持久的伪终端会话（支持 vim、top 等交互式程序），?mode=line 使用逐行执行命令的行模式
 func HandleTerminalWebSocket(c *gin.Context) {
 	conn, err := termUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -27,73 +38,187 @@ func HandleTerminalWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	// 确定使用的 shell
-	var shell string
-	var shellArgs []string
-	if runtime.GOOS == "windows" {
-		shell = "powershell.exe"
-		shellArgs = []string{"-NoLogo", "-NoProfile", "-Command", "-"}
-	} else {
-		shell = "/bin/bash"
-		shellArgs = []string{}
+	if c.Query("mode") == "line" {
+		handleLineTerminal(c, conn)
+		return
 	}
+	handlePTYTerminal(c, conn)
+}
 
-	// 发送欢迎消息
-	welcomeMsg := fmt.Sprintf("HomeDash Terminal - 连接到: %s", shell)
-	conn.WriteMessage(websocket.TextMessage, []byte(welcomeMsg))
+// handlePTYTerminal 伪终端模式：?session=<id> 重新连接已有会话（先回放最近的输出），否则新建会话。
+// 连接 SSH 主机时若主机密钥未被信任，发送 {"type":"hostkey"} 后断开；
+// 连接后先发送 {"type":"session"}，输出以二进制帧推送，shell 退出时发送 {"type":"exit"}；
+// 浏览器断开后会话继续运行
+func handlePTYTerminal(c *gin.Context, conn *websocket.Conn) {
+	var writeMu sync.Mutex
+	writeJSON := func(v interface{}) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.WriteJSON(v)
+	}
+	if terminalSessions == nil {
+		writeJSON(gin.H{"type": "error", "message": "终端服务未初始化"})
+		return
+	}
+	config, err := loadTerminalConfig()
+	if err != nil {
+		writeJSON(gin.H{"type": "error", "message": err.Error()})
+		return
+	}
+	// 交互式 shell 中的输入无法逐条检查，受命令策略限制的角色只能使用行模式
+	if !terminalPolicy(c, config).Empty() {
+		target := c.Query("session")
+		if target == "" && c.Query("ssh") != "" {
+			target = "ssh " + c.Query("ssh")
+		}
+		recordAuditDenied(c, "terminal.open", target, errTerminalLineOnly.Error())
+		writeJSON(gin.H{"type": "error", "message": errTerminalLineOnly.Error(), "lineOnly": true})
+		return
+	}
 
-	// 处理命令
-	for {
-		_, message, err := conn.ReadMessage()
+	cols, _ := strconv.ParseUint(c.Query("cols"), 10, 16)
+	rows, _ := strconv.ParseUint(c.Query("rows"), 10, 16)
+	var session *terminal.Session
+	if id := c.Query("session"); id != "" {
+		s, ok := terminalSessions.Get(id)
+		if !ok || !canUseTerminalSession(c, s.Info()) {
+			writeJSON(gin.H{"type": "error", "message": terminal.ErrSessionNotFound.Error()})
+			return
+		}
+		session = s
+		if cols > 0 && rows > 0 {
+			session.Resize(uint16(cols), uint16(rows))
+		}
+		recordAudit(c, "terminal.attach", terminalSessionLabel(session.Info()), nil)
+	} else {
+		s, err := createTerminalSession(c, config, uint16(cols), uint16(rows))
+		var hostKeyErr *sshterm.HostKeyError
+		if errors.As(err, &hostKeyErr) {
+			// 前端向用户展示指纹，确认后调用信任接口再重新连接
+			writeJSON(gin.H{"type": "hostkey", "message": hostKeyErr.Error(), "host": c.Query("ssh"), "fingerprint": hostKeyErr.Fingerprint, "changed": hostKeyErr.Changed})
+			return
+		}
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				// 连接关闭
-			}
-			break
+			writeJSON(gin.H{"type": "error", "message": "启动终端失败: " + err.Error()})
+			return
 		}
+		session = s
+	}
 
-		cmdStr := strings.TrimSpace(string(message))
-		if cmdStr == "" {
-			continue
-		}
+	attachment, replay := session.Attach()
+	defer session.Detach(attachment)
+	writeJSON(gin.H{"type": "session", "session": session.Info()})
+	writeBinary := func(data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+	if len(replay) > 0 {
+		writeBinary(replay)
+	}
 
-		// 执行命令
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("powershell.exe", "-NoLogo", "-NoProfile", "-Command", cmdStr)
-		} else {
-			cmd = exec.Command(shell, append(shellArgs, "-c", cmdStr)...)
+	// 输出：会话 → 浏览器
+	go func() {
+		for {
+			select {
+			case data := <-attachment.Output():
+				if writeBinary(data) != nil {
+					return
+				}
+			case <-attachment.Done():
+				for len(attachment.Output()) > 0 {
+					writeBinary(<-attachment.Output())
+				}
+				select {
+				case <-session.Exited():
+					writeJSON(gin.H{"type": "exit", "code": session.ExitCode()})
+				default:
+					// 连接跟不上输出被会话断开，浏览器可重新连接
+					writeJSON(gin.H{"type": "detached"})
+				}
+				writeMu.Lock()
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				writeMu.Unlock()
+				conn.Close()
+				return
+			}
 		}
-		cmd.Dir = webdavRoot
+	}()
 
-		// 合并 stdout 和 stderr
-		output, err := cmd.CombinedOutput()
+	// 输入：浏览器 → 会话
+	for {
+		msgType, data, err := conn.ReadMessage()
 		if err != nil {
-			// 如果有输出，先发送输出
-			if len(output) > 0 {
-				// 按行发送，过滤空行
-				lines := strings.Split(string(output), "\n")
-				for _, line := range lines {
-					line = strings.TrimRight(line, "\r\n")
-					if line != "" {
-						conn.WriteMessage(websocket.TextMessage, []byte("\x1b[31m"+line+"\x1b[0m"))
-					}
-				}
-			} else {
-				conn.WriteMessage(websocket.TextMessage, []byte("\x1b[31m执行失败: "+err.Error()+"\x1b[0m"))
-			}
+			return
+		}
+		if msgType == websocket.BinaryMessage {
+			session.Write(data)
 			continue
 		}
 
-		// 按行发送输出，过滤空行
-		if len(output) > 0 {
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				line = strings.TrimRight(line, "\r\n")
-				if line != "" {
-					conn.WriteMessage(websocket.TextMessage, []byte(line))
-				}
+		var msg terminalMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch msg.Type {
+		case "input":
+			session.Write([]byte(msg.Data))
+		case "resize":
+			if msg.Cols > 0 && msg.Rows > 0 {
+				session.Resize(msg.Cols, msg.Rows)
 			}
+		case "signal":
+			if err := session.Signal(terminal.Signal(msg.Signal)); err != nil {
+				writeJSON(gin.H{"type": "error", "message": err.Error()})
+			}
+		}
+	}
+}
+
+// createTerminalSession 新建会话：?ssh=<主机 ID> 连接保存的 SSH 主机，否则启动本地 shell
+func createTerminalSession(c *gin.Context, config TerminalConfig, cols, rows uint16) (*terminal.Session, error) {
+	user, _ := currentUser(c)
+	name := c.Query("name")
+	hostID := c.Query("ssh")
+	if hostID == "" {
+		shell, args := config.shell()
+		s, err := terminalSessions.Create(terminal.Options{
+			Shell: shell,
+			Args:  args,
+			Dir:   config.dir(),
+			Env:   config.env(),
+			Cols:  cols,
+			Rows:  rows,
+		}, name, user.ID, user.Username)
+		target := shell
+		if err == nil {
+			target = terminalSessionLabel(s.Info())
 		}
+		recordAudit(c, "terminal.open", target, err)
+		return s, err
+	}
+
+	if sshHosts == nil {
+		return nil, errors.New("SSH 服务未初始化")
+	}
+	host, err := sshHosts.Get(user.ID, hostID)
+	if err != nil {
+		return nil, err
+	}
+	if cols == 0 || rows == 0 {
+		cols, rows = 80, 24
+	}
+	if name == "" {
+		name = host.Name
+	}
+	info := terminal.SessionInfo{Name: name, OwnerID: user.ID, Owner: user.Username, Shell: "ssh " + host.Label(), Cols: cols, Rows: rows}
+	s, err := terminalSessions.CreateWith(info, func() (terminal.PTY, error) {
+		return sshterm.Dial(host, sshKnownHosts, cols, rows)
+	})
+	target := "ssh " + host.Label()
+	if err == nil {
+		target = terminalSessionLabel(s.Info()) + " " + target
 	}
+	recordAudit(c, "terminal.ssh", target, err)
+	return s, err
 }
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"homedash/internal/auth"
+	"homedash/internal/storage"
+	"homedash/internal/terminal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TerminalConfig 终端配置（仅管理员可修改），零值使用系统默认 shell 并从第一个挂载点的目录启动
+type TerminalConfig struct {
+	Shell    string                     `json:"shell"`    // shell 可执行文件，空为系统默认
+	Args     []string                   `json:"args"`     // 伪终端模式的 shell 参数，行模式不使用
+	Dir      string                     `json:"dir"`      // 起始目录，空为第一个挂载点的目录
+	Env      map[string]string          `json:"env"`      // 追加的环境变量
+	Policies map[string]terminal.Policy `json:"policies"` // 按角色的命令策略
+}
+
+// errTerminalLineOnly 受命令策略限制时不能打开交互式终端
+var errTerminalLineOnly = errors.New("当前角色受命令策略限制，只能在行模式下执行命令")
+
+// loadTerminalConfig 读取终端配置，未配置时返回零值
+func loadTerminalConfig() (TerminalConfig, error) {
+	var config TerminalConfig
+	if backend == nil {
+		return config, nil
+	}
+	data, err := backend.Load(storage.KeyTerminal)
+	if errors.Is(err, storage.ErrNotFound) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("终端配置无法解析: %v", err)
+	}
+	return config, nil
+}
+
+// validate 检查并规范化终端配置
+func (t *TerminalConfig) validate() error {
+	t.Shell = strings.TrimSpace(t.Shell)
+	if t.Shell != "" {
+		if _, err := exec.LookPath(t.Shell); err != nil {
+			return fmt.Errorf("找不到 shell %q", t.Shell)
+		}
+	} else {
+		t.Args = nil
+	}
+
+	t.Dir = strings.TrimSpace(t.Dir)
+	if t.Dir != "" {
+		if !filepath.IsAbs(t.Dir) {
+			return errors.New("起始目录必须是绝对路径")
+		}
+		if info, err := os.Stat(t.Dir); err != nil || !info.IsDir() {
+			return errors.New("起始目录不存在")
+		}
+	}
+
+	for key, value := range t.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") || strings.ContainsRune(value, 0) {
+			return fmt.Errorf("无效的环境变量 %q", key)
+		}
+	}
+
+	for role, policy := range t.Policies {
+		switch role {
+		case auth.RoleAdmin, auth.RoleOperator, auth.RoleViewer, auth.RoleCustom:
+		default:
+			return fmt.Errorf("命令策略中的角色 %q 无效", role)
+		}
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		if policy.Empty() {
+			delete(t.Policies, role)
+		}
+	}
+	return nil
+}
+
+// shell 伪终端模式使用的 shell 和参数
+func (t TerminalConfig) shell() (string, []string) {
+	if t.Shell == "" {
+		return terminal.DefaultShell()
+	}
+	return t.Shell, t.Args
+}
+
+// dir 起始目录
+func (t TerminalConfig) dir() string {
+	if t.Dir != "" {
+		return t.Dir
+	}
+	if m, ok := findMount(""); ok {
+		return m.Path
+	}
+	return defaultRoot
+}
+
+// env 追加的环境变量（KEY=VALUE，按名称排序）
+func (t TerminalConfig) env() []string {
+	env := make([]string, 0, len(t.Env))
+	for key, value := range t.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// lineShellPath 行模式使用的 shell，未配置时 Windows 为 PowerShell，其他系统为 bash
+func (t TerminalConfig) lineShellPath() string {
+	if t.Shell != "" {
+		return t.Shell
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell.exe"
+	}
+	return "/bin/bash"
+}
+
+// lineShellFamily 行模式 shell 的语法，命令策略按它拆分命令
+func (t TerminalConfig) lineShellFamily() terminal.ShellFamily {
+	return terminal.ShellFamilyOf(t.lineShellPath())
+}
+
+// lineShell 行模式执行单条命令的 shell 及其参数
+func (t TerminalConfig) lineShell(cmdStr string) (string, []string) {
+	shell := t.lineShellPath()
+	switch t.lineShellFamily() {
+	case terminal.ShellPowerShell:
+		return shell, []string{"-NoLogo", "-NoProfile", "-Command", cmdStr}
+	case terminal.ShellCmd:
+		return shell, []string{"/C", cmdStr}
+	default:
+		return shell, []string{"-c", cmdStr}
+	}
+}
+
+// terminalPolicy 当前用户角色的命令策略（未启用认证时按管理员处理）
+func terminalPolicy(c *gin.Context, config TerminalConfig) terminal.Policy {
+	role := auth.RoleAdmin
+	if user, ok := currentUser(c); ok {
+		role = user.Role
+	}
+	return config.Policies[role]
+}
+
+// GetTerminalConfig 获取终端配置
+func GetTerminalConfig(c *gin.Context) {
+	config, err := loadTerminalConfig()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, config)
+}
+
+// UpdateTerminalConfig 修改终端配置，对之后打开的会话和执行的命令生效
+func UpdateTerminalConfig(c *gin.Context) {
+	var config TerminalConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := config.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := backend.Save(storage.KeyTerminal, data); err != nil {
+		c.JSON(500, gin.H{"error": "保存终端配置失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, config)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	lineCommandTimeout    = 10 * time.Minute // 未指定超时时的默认值
+	maxLineCommandTimeout = 24 * time.Hour
+	lineCommandWaitDelay  = 2 * time.Second // 进程结束后等待输出管道关闭的最长时间
+)
+
+// lineCommandResult 行模式命令结束时发送给浏览器的退出消息
+type lineCommandResult struct {
+	Type     string `json:"type"` // exit
+	Code     int    `json:"code"` // 被终止时为 -1
+	Duration int64  `json:"duration"`
+	Canceled bool   `json:"canceled,omitempty"`
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Denied   bool   `json:"denied,omitempty"` // 被命令策略拒绝，未执行
+	Error    string `json:"error,omitempty"`
+}
+
+// handleLineTerminal 行模式：每条命令独立执行，输出按到达顺序实时推送。
+// 浏览器发送 {"type":"command","data":"...","timeout":秒} 执行命令（纯文本帧同样视为命令），
+// {"type":"cancel"} 取消正在运行的命令并终止其进程树；命令执行前按当前角色的命令策略检查，被拒绝时直接发送 {"type":"exit","denied":true}；
+// 服务端连接后发送 {"type":"ready"}，输出为 {"type":"output","stream":"stdout|stderr"}，结束时发送 {"type":"exit"}
+func handleLineTerminal(c *gin.Context, conn *websocket.Conn) {
+	var writeMu sync.Mutex
+	writeJSON := func(v interface{}) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.WriteJSON(v)
+	}
+
+	config, err := loadTerminalConfig()
+	if err != nil {
+		writeJSON(gin.H{"type": "error", "message": err.Error()})
+		return
+	}
+	shell, _ := config.lineShell("")
+	writeJSON(gin.H{"type": "ready", "shell": shell})
+
+	// 连接断开时终止仍在运行的命令，并等待其结束后再返回
+	ctx, cancelAll := context.WithCancel(c.Request.Context())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancelAll()
+
+	var mu sync.Mutex
+	var cancelRunning context.CancelCauseFunc
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg terminalMessage
+		if json.Unmarshal(data, &msg) != nil || (msg.Type != "command" && msg.Type != "cancel") {
+			// 兼容旧版页面：纯文本为命令，Ctrl-C 为取消
+			msg = terminalMessage{Type: "command", Data: string(data)}
+			if msg.Data == "\x03" {
+				msg.Type = "cancel"
+			}
+		}
+
+		if msg.Type == "cancel" {
+			mu.Lock()
+			if cancelRunning != nil {
+				cancelRunning(errLineCommandCanceled)
+			}
+			mu.Unlock()
+			continue
+		}
+
+		cmdStr := strings.TrimSpace(msg.Data)
+		if cmdStr == "" {
+			continue
+		}
+		mu.Lock()
+		if cancelRunning != nil {
+			mu.Unlock()
+			writeJSON(gin.H{"type": "error", "message": "已有命令正在运行，可按 Ctrl-C 取消"})
+			continue
+		}
+		// 每条命令都重新读取配置，修改后立即生效
+		config, err := loadTerminalConfig()
+		if err != nil {
+			mu.Unlock()
+			writeJSON(lineCommandResult{Type: "exit", Code: -1, Error: err.Error()})
+			continue
+		}
+		if err := terminalPolicy(c, config).Check(cmdStr, config.lineShellFamily()); err != nil {
+			mu.Unlock()
+			recordAuditDenied(c, "terminal.command", cmdStr, err.Error())
+			writeJSON(lineCommandResult{Type: "exit", Code: -1, Denied: true, Error: err.Error()})
+			continue
+		}
+		timeout := lineCommandTimeout
+		if msg.Timeout > 0 {
+			timeout = time.Duration(msg.Timeout) * time.Second
+			if timeout > maxLineCommandTimeout {
+				timeout = maxLineCommandTimeout
+			}
+		}
+		cmdCtx, cancel := context.WithCancelCause(ctx)
+		cancelRunning = cancel
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runLineCommand(cmdCtx, config, cmdStr, timeout, writeJSON)
+			mu.Lock()
+			cancelRunning = nil
+			mu.Unlock()
+			cancel(nil)
+
+			var auditErr error
+			switch {
+			case result.Error != "":
+				auditErr = errors.New(result.Error)
+			case result.Canceled:
+				auditErr = errLineCommandCanceled
+			case result.TimedOut:
+				auditErr = errLineCommandTimeout
+			case result.Code != 0:
+				auditErr = fmt.Errorf("退出码 %d", result.Code)
+			}
+			recordAudit(c, "terminal.command", cmdStr, auditErr)
+			writeJSON(result)
+		}()
+	}
+}
+
+var (
+	errLineCommandCanceled = errors.New("命令已取消")
+	errLineCommandTimeout  = errors.New("命令执行超时")
+)
+
+// runLineCommand 执行命令并实时推送输出，取消或超时时终止整个进程树
+func runLineCommand(ctx context.Context, config TerminalConfig, cmdStr string, timeout time.Duration, writeJSON func(interface{})) lineCommandResult {
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errLineCommandTimeout)
+	defer cancel()
+
+	shell, args := config.lineShell(cmdStr)
+	cmd := exec.CommandContext(ctx, shell, args...)
+	cmd.Dir = config.dir()
+	if env := config.env(); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	setProcessTreeKill(cmd)
+	// 后台进程可能继承输出管道，进程树结束后不再无限等待管道关闭
+	cmd.WaitDelay = lineCommandWaitDelay
+	stdout := &lineOutputWriter{stream: "stdout", writeJSON: writeJSON}
+	stderr := &lineOutputWriter{stream: "stderr", writeJSON: writeJSON}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	result := lineCommandResult{Type: "exit", Duration: time.Since(start).Milliseconds()}
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errLineCommandCanceled):
+		result.Canceled = true
+	case errors.Is(cause, errLineCommandTimeout):
+		result.TimedOut = true
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case cmd.ProcessState != nil:
+		result.Code = cmd.ProcessState.ExitCode()
+	case errors.As(err, &exitErr):
+		result.Code = exitErr.ExitCode()
+	case err != nil:
+		result.Code = -1
+		result.Error = "执行失败: " + err.Error()
+	}
+	return result
+}
+
+// lineOutputWriter 将命令输出作为消息推送，被截断的多字节字符留到下一段一起发送
+type lineOutputWriter struct {
+	mu        sync.Mutex
+	stream    string
+	writeJSON func(interface{})
+	pending   []byte
+}
+
+func (w *lineOutputWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := append(w.pending, p...)
+	n := len(data)
+	// 最多回看 3 个字节找到最后一个字符的起始字节
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		w.writeJSON(gin.H{"type": "output", "stream": w.stream, "data": string(data[:n])})
+	}
+	return len(p), nil
+}
+
+// Flush 发送剩余的输出
+func (w *lineOutputWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		w.writeJSON(gin.H{"type": "output", "stream": w.stream, "data": string(w.pending)})
+		w.pending = nil
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"homedash/internal/recording"
+	"homedash/internal/terminal"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const maxPlaybackSpeed = 32
+
+var terminalRecordings *recording.Store
+
+// InitTerminalRecordings 初始化终端录像目录，record 为 true 时录制之后新建的全部会话
+func InitTerminalRecordings(store *recording.Store, record bool) {
+	terminalRecordings = store
+	if record && terminalSessions != nil {
+		terminalSessions.SetRecorder(recordTerminalSession)
+	}
+}
+
+func recordTerminalSession(info terminal.SessionInfo) (terminal.Recorder, error) {
+	return terminalRecordings.Create(recording.Meta{
+		SessionID: info.ID,
+		Name:      info.Name,
+		OwnerID:   info.OwnerID,
+		Owner:     info.Owner,
+		Shell:     info.Shell,
+		Cols:      info.Cols,
+		Rows:      info.Rows,
+		StartedAt: info.CreatedAt,
+	})
+}
+
+// canViewTerminalRecording 录像只能由会话创建者或管理员查看
+func canViewTerminalRecording(c *gin.Context, m recording.Meta) bool {
+	if authManager == nil {
+		return true
+	}
+	user, ok := currentUser(c)
+	return ok && (user.ID == m.OwnerID || user.IsAdmin())
+}
+
+// findTerminalRecording 查找当前用户可查看的录像，不存在或无权查看时返回 404
+func findTerminalRecording(c *gin.Context) (recording.Meta, bool) {
+	if terminalRecordings == nil {
+		c.JSON(404, gin.H{"error": recording.ErrNotFound.Error()})
+		return recording.Meta{}, false
+	}
+	m, err := terminalRecordings.Get(c.Param("id"))
+	if err != nil || !canViewTerminalRecording(c, m) {
+		c.JSON(404, gin.H{"error": recording.ErrNotFound.Error()})
+		return recording.Meta{}, false
+	}
+	setAuditTarget(c, m.Name+" ("+m.ID+")")
+	return m, true
+}
+
+// GetTerminalRecordings 获取录像列表（管理员可看到全部用户的录像）
+func GetTerminalRecordings(c *gin.Context) {
+	recordings := []recording.Meta{}
+	if terminalRecordings == nil {
+		c.JSON(200, recordings)
+		return
+	}
+	all, err := terminalRecordings.List()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, m := range all {
+		if canViewTerminalRecording(c, m) {
+			recordings = append(recordings, m)
+		}
+	}
+	c.JSON(200, recordings)
+}
+
+// DownloadTerminalRecording 下载 asciicast 文件，可用 asciinema play 播放
+func DownloadTerminalRecording(c *gin.Context) {
+	m, ok := findTerminalRecording(c)
+	if !ok {
+		return
+	}
+	path, err := terminalRecordings.Path(m.ID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", "application/x-asciicast")
+	c.FileAttachment(path, "terminal-"+m.ID+".cast")
+}
+
+// DeleteTerminalRecording 删除录像（仅管理员）
+func DeleteTerminalRecording(c *gin.Context) {
+	m, ok := findTerminalRecording(c)
+	if !ok {
+		return
+	}
+	if err := terminalRecordings.Delete(m.ID); err != nil {
+		status := 500
+		if errors.Is(err, recording.ErrRecording) {
+			status = 409
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// PlayTerminalRecording 通过 WebSocket 按录制时的节奏回放录像。
+// 参数 speed 为倍速（默认 1），idle 为最长停顿秒数（默认不限制）；
+// 先发送 {"type":"header"}，输出以二进制帧推送，窗口变化发送 {"type":"resize"}，播放完毕发送 {"type":"end"}
+func PlayTerminalRecording(c *gin.Context) {
+	m, ok := findTerminalRecording(c)
+	if !ok {
+		return
+	}
+	path, err := terminalRecordings.Path(m.ID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	reader, err := recording.NewReader(file)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "无法解析录像: " + err.Error()})
+		return
+	}
+
+	speed, _ := strconv.ParseFloat(c.Query("speed"), 64)
+	if speed <= 0 {
+		speed = 1
+	} else if speed > maxPlaybackSpeed {
+		speed = maxPlaybackSpeed
+	}
+	idle, _ := strconv.ParseFloat(c.Query("idle"), 64)
+
+	conn, err := termUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var writeMu sync.Mutex
+	writeJSON := func(v interface{}) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	// 浏览器关闭连接时停止回放
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeJSON(gin.H{"type": "header", "recording": m, "width": reader.Header.Width, "height": reader.Header.Height})
+	err = reader.Play(ctx, speed, idle, func(e recording.Event) error {
+		switch e.Type {
+		case recording.EventOutput:
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			return conn.WriteMessage(websocket.BinaryMessage, []byte(e.Data))
+		case recording.EventResize:
+			cols, rows, ok := strings.Cut(e.Data, "x")
+			if !ok {
+				return nil
+			}
+			w, _ := strconv.Atoi(cols)
+			h, _ := strconv.Atoi(rows)
+			return writeJSON(gin.H{"type": "resize", "cols": w, "rows": h})
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	writeJSON(gin.H{"type": "end"})
+	writeMu.Lock()
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	writeMu.Unlock()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+
+	"homedash/internal/terminal"
+
+	"github.com/gin-gonic/gin"
+)
+
+var terminalSessions *terminal.Manager
+
+// InitTerminal 初始化终端会话管理器
+func InitTerminal(m *terminal.Manager) {
+	terminalSessions = m
+}
+
+// canUseTerminalSession 会话只能由创建者或管理员连接和管理
+func canUseTerminalSession(c *gin.Context, info terminal.SessionInfo) bool {
+	if authManager == nil {
+		return true
+	}
+	user, ok := currentUser(c)
+	return ok && (user.ID == info.OwnerID || user.IsAdmin())
+}
+
+// terminalSessionLabel 审计日志中的会话描述
+func terminalSessionLabel(info terminal.SessionInfo) string {
+	return info.Name + " (" + info.ID + ")"
+}
+
+// findTerminalSession 查找当前用户可管理的会话，不存在或无权访问时返回 404
+func findTerminalSession(c *gin.Context) (terminal.SessionInfo, bool) {
+	if terminalSessions == nil {
+		c.JSON(500, gin.H{"error": "终端服务未初始化"})
+		return terminal.SessionInfo{}, false
+	}
+	s, ok := terminalSessions.Get(c.Param("id"))
+	if !ok || !canUseTerminalSession(c, s.Info()) {
+		c.JSON(404, gin.H{"error": terminal.ErrSessionNotFound.Error()})
+		return terminal.SessionInfo{}, false
+	}
+	info := s.Info()
+	setAuditTarget(c, terminalSessionLabel(info))
+	return info, true
+}
+
+// GetTerminalSessions 获取终端会话列表（管理员可看到全部用户的会话）
+func GetTerminalSessions(c *gin.Context) {
+	if terminalSessions == nil {
+		c.JSON(200, []terminal.SessionInfo{})
+		return
+	}
+	sessions := []terminal.SessionInfo{}
+	for _, info := range terminalSessions.List() {
+		if canUseTerminalSession(c, info) {
+			sessions = append(sessions, info)
+		}
+	}
+	c.JSON(200, sessions)
+}
+
+// RenameTerminalSession 修改会话名称
+func RenameTerminalSession(c *gin.Context) {
+	info, ok := findTerminalSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求"})
+		return
+	}
+	if err := terminalSessions.Rename(info.ID, req.Name); err != nil {
+		status := 400
+		if errors.Is(err, terminal.ErrSessionNotFound) {
+			status = 404
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// KillTerminalSession 结束会话及其中运行的全部程序
+func KillTerminalSession(c *gin.Context) {
+	info, ok := findTerminalSession(c)
+	if !ok {
+		return
+	}
+	if err := terminalSessions.Kill(info.ID); err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
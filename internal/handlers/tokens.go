@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"homedash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ctxToken = "authToken"
+
+// bearerToken 读取 Authorization: Bearer 请求头中的令牌
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// currentToken 当前请求使用的 API 令牌（会话登录时不存在）
+func currentToken(c *gin.Context) (auth.APIToken, bool) {
+	v, ok := c.Get(ctxToken)
+	if !ok {
+		return auth.APIToken{}, false
+	}
+	token, ok := v.(auth.APIToken)
+	return token, ok
+}
+
+// RequireSession 仅允许通过登录会话访问（修改密码、管理令牌等不能用 API 令牌完成）
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := currentToken(c); ok {
+			c.AbortWithStatusJSON(403, gin.H{"error": "该操作需要登录会话，不能使用 API 令牌"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetTokens 获取当前用户的 API 令牌，管理员可通过 ?all=true 查看全部
+func GetTokens(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	userID := user.ID
+	if c.Query("all") == "true" && user.IsAdmin() {
+		userID = ""
+	}
+	tokens := authManager.Tokens(userID)
+	result := make([]auth.APITokenView, 0, len(tokens))
+	for _, t := range tokens {
+		result = append(result, t.View())
+	}
+	c.JSON(200, result)
+}
+
+// CreateToken 创建 API 令牌，明文令牌只在创建时返回一次
+func CreateToken(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"` // 0 表示永不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, _ := currentUser(c)
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, info, err := authManager.CreateToken(user.ID, req.Name, req.Scopes, ttl)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"token": token, "info": info.View()})
+}
+
+// RevokeToken 吊销 API 令牌（本人的令牌，管理员可吊销任意令牌）
+func RevokeToken(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	token, ok := authManager.Token(c.Param("id"))
+	if !ok || (token.UserID != user.ID && !user.IsAdmin()) {
+		c.JSON(404, gin.H{"error": auth.ErrTokenNotFound.Error()})
+		return
+	}
+	err := authManager.RevokeToken(token.ID)
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "吊销失败: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+
+	"homedash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTOTPError 两步验证接口的错误响应
+func respondTOTPError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidTOTP), errors.Is(err, auth.ErrTOTPNotPending),
+		errors.Is(err, auth.ErrTOTPEnabled), errors.Is(err, auth.ErrTOTPDisabled):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": "保存失败: " + err.Error()})
+	}
+}
+
+// confirmPassword 敏感操作前校验当前用户的密码
+func confirmPassword(c *gin.Context) (auth.User, bool) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return auth.User{}, false
+	}
+	user, _ := currentUser(c)
+	if _, err := authManager.Authenticate(user.Username, req.Password); err != nil {
+		c.JSON(400, gin.H{"error": "密码错误"})
+		return auth.User{}, false
+	}
+	return user, true
+}
+
+// BeginTOTP 生成两步验证密钥，返回 otpauth:// 地址供验证器应用扫描
+func BeginTOTP(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	user, _ := currentUser(c)
+	setup, err := authManager.BeginTOTP(user.ID)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+	c.JSON(200, setup)
+}
+
+// EnableTOTP 输入验证码确认启用两步验证，返回恢复码（仅显示一次）
+func EnableTOTP(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	user, _ := currentUser(c)
+	codes, err := authManager.EnableTOTP(user.ID, req.Code)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTOTP 关闭本人的两步验证（需要密码）
+func DisableTOTP(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	user, ok := confirmPassword(c)
+	if !ok {
+		return
+	}
+	if err := authManager.DisableTOTP(user.ID); err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码（需要密码）
+func RegenerateRecoveryCodes(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	user, ok := confirmPassword(c)
+	if !ok {
+		return
+	}
+	codes, err := authManager.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"recoveryCodes": codes})
+}
+
+// ResetUserTOTP 管理员为丢失验证器的用户关闭两步验证
+func ResetUserTOTP(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	if err := authManager.DisableTOTP(c.Param("id")); err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"success": true})
+}
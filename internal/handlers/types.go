@@ -1,5 +1,7 @@
 package handlers
 
+import "homedash/internal/mounts"
+
 // BackgroundInfo 背景图信息
 type BackgroundInfo struct {
 	Name  string `json:"name"`
@@ -12,8 +14,10 @@ type UserSettings struct {
 	ServerIP         string `json:"serverIp"`
 	BackgroundURL    string `json:"backgroundUrl"`
 	Theme            string `json:"theme"`            // "dark" | "light"
-	WebdavRoot       string `json:"webdavRoot"`       // WebDAV 挂载根目录
+	WebdavRoot       string `json:"webdavRoot"`       // 旧版的单一文件根目录，未配置挂载点时使用
 	ComfyUIServerURL string `json:"comfyuiServerUrl"` // ComfyUI服务器地址
+
+	Mounts []mounts.Mount `json:"mounts,omitempty"` // 文件管理、WebDAV 和 SFTP 的挂载点
 }
 
 // ServiceCard 服务卡片
@@ -30,6 +34,65 @@ type ServiceCard struct {
 	AutoStart     bool   `json:"autoStart"`     // 是否开机自启
 	CreatedAt     int64  `json:"createdAt"`
 	UpdatedAt     int64  `json:"updatedAt"`
+	Version       int64  `json:"version"` // 版本号，每次修改递增（乐观并发控制）
+
+	ResourceLimits *ResourceLimits   `json:"resourceLimits,omitempty"` // 资源限制（Linux cgroup v2）
+	Schedules      []ServiceSchedule `json:"schedules,omitempty"`      // 定时启动/停止/重启
+	Maintenance    *MaintenanceMode  `json:"maintenance,omitempty"`    // 维护模式
+}
+
+// ServiceSchedule 服务定时任务
+type ServiceSchedule struct {
+	ID      string `json:"id"`
+	Action  string `json:"action"`  // "start" | "stop" | "restart"
+	Cron    string `json:"cron"`    // cron 表达式（分 时 日 月 周）
+	Enabled bool   `json:"enabled"` // 是否启用
+}
+
+// MaintenanceMode 维护模式，期间不做健康检测告警、不执行定时任务
+type MaintenanceMode struct {
+	Enabled bool   `json:"enabled"`
+	Until   int64  `json:"until,omitempty"`  // 到期时间（毫秒时间戳），0 表示手动关闭
+	Reason  string `json:"reason,omitempty"` // 维护原因
+}
+
+// ScheduleRun 定时任务执行记录
+type ScheduleRun struct {
+	ServiceID  string `json:"serviceId"`
+	ScheduleID string `json:"scheduleId"`
+	Action     string `json:"action"`
+	Status     string `json:"status"` // "success" | "failed" | "skipped"
+	Message    string `json:"message,omitempty"`
+	StartedAt  int64  `json:"startedAt"`
+	FinishedAt int64  `json:"finishedAt"`
+}
+
+// ResourceLimits 服务资源限制，零值表示不限制
+type ResourceLimits struct {
+	CPUQuota  float64 `json:"cpuQuota,omitempty"`  // CPU 配额（核数，1.5 表示最多 150%）
+	MemoryMax int64   `json:"memoryMax,omitempty"` // 内存上限（字节）
+	IOWeight  int     `json:"ioWeight,omitempty"`  // IO 权重（1-10000）
+	PidsMax   int     `json:"pidsMax,omitempty"`   // 最大进程/线程数
+}
+
+// ResourceUsage cgroup 当前资源占用
+type ResourceUsage struct {
+	CPUUsageUsec  uint64 `json:"cpuUsageUsec"`  // 累计 CPU 时间（微秒）
+	MemoryCurrent uint64 `json:"memoryCurrent"` // 当前内存（字节）
+	PidsCurrent   uint64 `json:"pidsCurrent"`   // 当前进程数
+	IOReadBytes   uint64 `json:"ioReadBytes"`   // 累计读取字节
+	IOWriteBytes  uint64 `json:"ioWriteBytes"`  // 累计写入字节
+}
+
+// CgroupStatus 服务 cgroup 状态
+type CgroupStatus struct {
+	Available bool           `json:"available"`         // cgroup v2 是否可用且可写
+	Applied   bool           `json:"applied"`           // 进程已在 cgroup 中且配置的限制全部写入
+	Path      string         `json:"path,omitempty"`    // cgroup 目录
+	Skipped   []string       `json:"skipped,omitempty"` // 配置了限制但未委派的控制器，对应限制未生效
+	Message   string         `json:"message,omitempty"` // 不可用或降级原因
+	Limits    ResourceLimits `json:"limits"`
+	Usage     ResourceUsage  `json:"usage"`
 }
 
 // AppConfig 应用配置
@@ -41,7 +104,7 @@ type AppConfig struct {
 // PingResult 连通性检测结果
 type PingResult struct {
 	ID      string `json:"id"`
-	Status  string `json:"status"`  // "ok" | "slow" | "error"
+	Status  string `json:"status"`  // "ok" | "slow" | "error" | "maintenance"
 	Latency int64  `json:"latency"` // 毫秒
 	Message string `json:"message,omitempty"`
 }
@@ -77,6 +140,7 @@ type DockerImage struct {
 
 // ProcessStatus 进程状态
 type ProcessStatus struct {
-	Running bool  `json:"running"`
-	PID     int32 `json:"pid"`
+	Running bool          `json:"running"`
+	PID     int32         `json:"pid"`
+	Cgroup  *CgroupStatus `json:"cgroup,omitempty"` // 配置了资源限制时返回
 }
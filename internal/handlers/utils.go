@@ -5,23 +5,29 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"homedash/internal/mounts"
+	"homedash/internal/storage"
 )
 
 var (
-	webDir       string
-	settingsFile string
-	servicesFile string
-	settingsMu   sync.RWMutex
-	servicesMu   sync.RWMutex
-	webdavRoot   string // WebDAV 根目录
+	webDir     string
+	settingsMu sync.RWMutex
+	servicesMu sync.RWMutex
+	dataDir    string // 运行数据目录（任务、历史记录等，不对外提供静态访问）
+
+	defaultRoot string // 未配置挂载点时使用的目录（WEBDAV_ROOT 或用户主目录），仅在启动时设置
 )
 
-// InitHandlers 初始化处理器全局变量
-func InitHandlers(wd, sf, svf, wr string) {
+// fileMounts 文件管理、WebDAV 和 SFTP 共用的挂载点，设置修改或热加载时替换
+var fileMounts = mounts.NewTable(nil)
+
+// InitHandlers 初始化处理器全局变量（服务和设置的存储见 InitStorage），
+// root 为未配置挂载点时的默认目录，设置中的挂载点由 LoadMounts 加载
+func InitHandlers(wd, root string) {
 	webDir = wd
-	settingsFile = sf
-	servicesFile = svf
-	webdavRoot = wr
+	defaultRoot = root
+	fileMounts.Set([]mounts.Mount{{Name: mounts.DefaultName, Path: root}})
 }
 
 // GetWebDir 获取web目录
@@ -29,61 +35,54 @@ func GetWebDir() string {
 	return webDir
 }
 
+// SetDataDir 设置运行数据目录
+func SetDataDir(dir string) {
+	dataDir = dir
+}
 
-// SetWebdavRoot 设置WebDAV根目录
-func SetWebdavRoot(root string) {
-	webdavRoot = root
+// GetDataDir 获取运行数据目录
+func GetDataDir() string {
+	return dataDir
 }
 
-// loadServices 加载服务列表
+// loadServices 加载服务列表（文件损坏时返回空列表）
 func loadServices() []ServiceCard {
-	servicesMu.RLock()
-	defer servicesMu.RUnlock()
-
-	var services []ServiceCard
-	data, err := os.ReadFile(servicesFile)
-	if err != nil {
-		return services
-	}
-
-	json.Unmarshal(data, &services)
+	services, _ := serviceStore.List()
 	return services
 }
 
-// saveServices 保存服务列表
-func saveServices(services []ServiceCard) error {
-	servicesMu.Lock()
-	defer servicesMu.Unlock()
-
-	data, err := json.MarshalIndent(services, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(servicesFile, data, 0644)
-}
-
 // LoadSettings 从文件加载用户设置（导出供外部使用）
 func LoadSettings() UserSettings {
 	return loadSettings()
 }
 
-// loadSettings 从文件加载用户设置
+// loadSettings 从文件加载用户设置（文件损坏时返回默认设置）
 func loadSettings() UserSettings {
+	settings, _ := loadSettingsChecked()
+	return settings
+}
+
+// loadSettingsChecked 从文件加载用户设置（文件被改坏时使用最近一次有效的内容），
+// 没有可用的内容且文件无法解析时返回默认设置和 ConfigCorruptError
+func loadSettingsChecked() (UserSettings, error) {
 	settingsMu.RLock()
 	defer settingsMu.RUnlock()
 
-	settings := UserSettings{
+	defaults := UserSettings{
 		ServerIP:      "localhost",
 		BackgroundURL: "",
 	}
 
-	data, err := os.ReadFile(settingsFile)
-	if err != nil {
-		return settings
+	data, err := loadGoodConfig(storage.KeySettings, validateSettingsJSON)
+	if err != nil || data == nil {
+		return defaults, err
 	}
 
-	json.Unmarshal(data, &settings)
-	return settings
+	settings := defaults
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return defaults, &ConfigCorruptError{File: configDisplayName(storage.KeySettings), Err: describeJSONError(data, err)}
+	}
+	return settings, nil
 }
 
 // saveSettings 保存用户设置到文件
@@ -95,7 +94,7 @@ func saveSettings(settings UserSettings) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(settingsFile, data, 0644)
+	return writeVersionedConfig(storage.KeySettings, data, checkSettingsJSON)
 }
 
 // resolveWebDir 解析web目录路径
@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"homedash/internal/cron"
+	"homedash/internal/mounts"
 )
 
 // ValidateServiceConfig 验证服务配置
@@ -71,6 +74,33 @@ func ValidateServiceConfig(service *ServiceCard) error {
 		}
 	}
 
+	// 验证资源限制（如果提供）
+	if service.ResourceLimits != nil {
+		if err := validateResourceLimits(service.ResourceLimits); err != nil {
+			return err
+		}
+	}
+
+	// 验证定时任务（如果提供）
+	if len(service.Schedules) > 0 {
+		if service.LaunchCommand == "" && service.LaunchPath == "" {
+			return fmt.Errorf("配置定时任务需要先配置启动命令")
+		}
+		for _, sch := range service.Schedules {
+			if sch.Action != "start" && sch.Action != "stop" && sch.Action != "restart" {
+				return fmt.Errorf("定时任务动作必须是 start、stop 或 restart")
+			}
+			if _, err := cron.Parse(sch.Cron); err != nil {
+				return fmt.Errorf("定时任务表达式无效: %v", err)
+			}
+		}
+	}
+
+	// 验证维护模式（如果提供）
+	if service.Maintenance != nil && service.Maintenance.Until < 0 {
+		return fmt.Errorf("维护到期时间无效")
+	}
+
 	// 验证图标（如果提供）
 	if service.Icon != "" {
 		// 检查是否为 emoji（简单验证）
@@ -89,6 +119,29 @@ func ValidateServiceConfig(service *ServiceCard) error {
 	return nil
 }
 
+// validateResourceLimits 验证资源限制
+func validateResourceLimits(limits *ResourceLimits) error {
+	if limits.CPUQuota < 0 || limits.CPUQuota > 1024 {
+		return fmt.Errorf("CPU 配额无效")
+	}
+	if limits.CPUQuota > 0 && limits.CPUQuota < 0.01 {
+		return fmt.Errorf("CPU 配额过小（最小 0.01 核）")
+	}
+	if limits.MemoryMax < 0 {
+		return fmt.Errorf("内存上限无效")
+	}
+	if limits.MemoryMax > 0 && limits.MemoryMax < 4*1024*1024 {
+		return fmt.Errorf("内存上限过小（最小 4MB）")
+	}
+	if limits.IOWeight != 0 && (limits.IOWeight < 1 || limits.IOWeight > 10000) {
+		return fmt.Errorf("IO 权重必须在 1-10000 之间")
+	}
+	if limits.PidsMax < 0 {
+		return fmt.Errorf("最大进程数无效")
+	}
+	return nil
+}
+
 // ValidateUserSettings 验证用户设置
 func ValidateUserSettings(settings *UserSettings) error {
 	// 验证 ServerIP（如果提供）
@@ -115,6 +168,15 @@ func ValidateUserSettings(settings *UserSettings) error {
 		}
 	}
 
+	// 验证挂载点（如果提供）
+	if len(settings.Mounts) > 0 {
+		list, err := mounts.Validate(settings.Mounts)
+		if err != nil {
+			return err
+		}
+		settings.Mounts = list
+	}
+
 	// 验证 ComfyUI 服务器 URL（如果提供）
 	if settings.ComfyUIServerURL != "" {
 		if !isValidURL(settings.ComfyUIServerURL) {
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"homedash/internal/storage"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const configReloadDelay = 500 * time.Millisecond // 合并编辑器保存时的连续事件
+
+var (
+	configHashMu sync.Mutex
+	configHashes = make(map[string][sha256.Size]byte) // 最近一次由 HomeDash 写入或已应用的内容摘要
+	lastGood     = make(map[string][]byte)            // 最近一次有效的内容，文件被改坏时读取方继续使用
+)
+
+// ConfigChangedEvent 配置文件被外部修改时推送给浏览器的事件
+type ConfigChangedEvent struct {
+	File    string `json:"file"`    // services | settings
+	Applied bool   `json:"applied"` // 校验通过并已生效
+	Error   string `json:"error,omitempty"`
+}
+
+// rememberConfigContent 记录已知的配置内容，监听器据此忽略 HomeDash 自身的写入
+func rememberConfigContent(name string, data []byte) {
+	configHashMu.Lock()
+	configHashes[name] = sha256.Sum256(data)
+	configHashMu.Unlock()
+}
+
+// isKnownConfigContent 内容是否与最近一次写入或应用的一致
+func isKnownConfigContent(name string, data []byte) bool {
+	configHashMu.Lock()
+	defer configHashMu.Unlock()
+	known, ok := configHashes[name]
+	return ok && known == sha256.Sum256(data)
+}
+
+// rememberGoodConfig 记录最近一次有效的配置内容（保存成功、外部修改通过校验或启动时检查通过）
+func rememberGoodConfig(name string, data []byte) {
+	configHashMu.Lock()
+	lastGood[name] = data
+	configHashMu.Unlock()
+}
+
+// loadGoodConfig 读取配置文档；内容与最近一次有效的不同且未通过 validate 时，
+// 返回最近一次有效的内容，直到出现有效的文件。没有有效内容可用时原样返回
+func loadGoodConfig(name string, validate func([]byte) error) ([]byte, error) {
+	data, err := loadConfig(name)
+
+	configHashMu.Lock()
+	good, ok := lastGood[name]
+	configHashMu.Unlock()
+	if !ok || (err == nil && (data == nil || bytes.Equal(data, good))) {
+		return data, err
+	}
+	if err == nil && validate(data) == nil {
+		return data, nil
+	}
+	return good, nil
+}
+
+// validateServicesJSON 解析并校验服务文件
+func validateServicesJSON(data []byte) error {
+	var services []ServiceCard
+	if err := json.Unmarshal(data, &services); err != nil {
+		return describeJSONError(data, err)
+	}
+	for i := range services {
+		if err := ValidateServiceConfig(&services[i]); err != nil {
+			return fmt.Errorf("服务 %q: %v", services[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// validateSettingsJSON 解析并校验设置文件
+func validateSettingsJSON(data []byte) error {
+	var settings UserSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return describeJSONError(data, err)
+	}
+	return ValidateUserSettings(&settings)
+}
+
+// StartConfigWatcher 监听服务和设置文件的外部修改（手动编辑、git 检出等）
+// 仅 JSON 文件后端需要监听，SQLite 后端不支持外部编辑
+func StartConfigWatcher() error {
+	files, ok := backend.(storage.FileBackend)
+	if !ok {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// 监听所在目录而非文件本身：编辑器和原子写入都会以重命名替换文件
+	dirs := make(map[string]bool)
+	for name := range configFiles {
+		dirs[filepath.Dir(files.Path(name))] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go watchConfigLoop(watcher, files)
+	return nil
+}
+
+// watchConfigLoop 处理文件事件，同一文件的连续事件合并后再重新加载
+func watchConfigLoop(watcher *fsnotify.Watcher, files storage.FileBackend) {
+	pending := make(map[string]*time.Timer)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			for name := range configFiles {
+				if filepath.Clean(event.Name) != filepath.Clean(files.Path(name)) {
+					continue
+				}
+				if timer, ok := pending[name]; ok {
+					timer.Reset(configReloadDelay)
+				} else {
+					name := name
+					pending[name] = time.AfterFunc(configReloadDelay, func() { reloadConfigFile(name) })
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("配置文件监听错误: %v", err)
+		}
+	}
+}
+
+// reloadConfigFile 校验并应用外部修改后的配置文件
+func reloadConfigFile(name string) {
+	spec := configFiles[name]
+	data, err := backend.Load(name)
+	if err != nil {
+		// 重命名替换过程中文件可能暂时不存在，等待后续事件
+		return
+	}
+	if isKnownConfigContent(name, data) {
+		return
+	}
+
+	if err := spec.validate(data); err != nil {
+		log.Printf("⚠ 检测到 %s 被外部修改，但校验未通过，已忽略: %v", configDisplayName(name), err)
+		broadcastConfigChanged(ConfigChangedEvent{File: name, Error: err.Error()})
+		return
+	}
+
+	unlock := spec.lock()
+	rememberConfigContent(name, data)
+	rememberGoodConfig(name, data)
+	if err := saveConfigVersion(name, data); err != nil {
+		log.Printf("保存 %s 历史版本失败: %v", name, err)
+	}
+	unlock()
+	if spec.apply != nil {
+		spec.apply(data)
+	}
+
+	log.Printf("检测到 %s 被外部修改，已重新加载", configDisplayName(name))
+	broadcastConfigChanged(ConfigChangedEvent{File: name, Applied: true})
+}
+
+// broadcastConfigChanged 通知所有已登录的页面（经 /ws/events，不要求监控权限）
+func broadcastConfigChanged(event ConfigChangedEvent) {
+	if eventHub != nil {
+		eventHub.Broadcast("config-changed", event)
+	}
+}
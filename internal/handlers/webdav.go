@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+
+	"homedash/internal/mounts"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/net/webdav"
@@ -35,33 +40,76 @@ func sanitizePath(reqPath string) (string, error) {
 	return cleanPath, nil
 }
 
-// GetFileList 获取文件列表
-func GetFileList(c *gin.Context) {
-	reqPath := c.Query("path")
+// resolveFilePath 定位文件管理请求的本地路径（mount 为空时使用第一个挂载点，或用户限制目录所在的挂载点），
+// 并按 WebDAV 配置中的用户限制拒绝子目录以外的路径和只读用户的写入，失败时已写入响应
+func resolveFilePath(c *gin.Context, mountName, reqPath string, write bool) (mounts.Mount, string, string, bool) {
+	var limits WebDAVUserConfig
+	if user, ok := currentUser(c); ok {
+		var err error
+		if limits, err = userFileLimits(user.ID); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return mounts.Mount{}, "", "", false
+		}
+	}
+	if write && limits.ReadOnly {
+		c.JSON(403, gin.H{"error": "文件为只读访问"})
+		return mounts.Mount{}, "", "", false
+	}
+	if mountName == "" && limits.Root != "" {
+		mountName, _ = mounts.Split(limits.Root)
+	}
+
+	m, ok := findMount(mountName)
+	if !ok {
+		c.JSON(404, gin.H{"error": mounts.ErrNotFound.Error()})
+		return m, "", "", false
+	}
+	if write && m.ReadOnly {
+		c.JSON(403, gin.H{"error": mounts.ErrReadOnly.Error()})
+		return m, "", "", false
+	}
 
 	// 安全检查：防止路径遍历
 	safePath, err := sanitizePath(reqPath)
 	if err != nil {
 		c.JSON(403, gin.H{"error": "非法路径"})
-		return
+		return m, "", "", false
 	}
-
-	fullPath := filepath.Join(webdavRoot, filepath.FromSlash(safePath))
-
-	// 验证完整路径是否在 webdavRoot 下
-	absRoot, err := filepath.Abs(webdavRoot)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "根目录错误"})
-		return
+	if !mounts.Within(limits.Root, path.Join("/", m.Name, safePath)) {
+		c.JSON(403, gin.H{"error": "只能访问 " + limits.Root + " 目录"})
+		return m, "", "", false
 	}
-	absPath, err := filepath.Abs(fullPath)
+	// 验证完整路径是否在挂载点目录下
+	fullPath, err := mounts.Join(m.Path, safePath)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "路径解析失败"})
-		return
+		c.JSON(403, gin.H{"error": "禁止访问"})
+		return m, "", "", false
 	}
+	return m, safePath, fullPath, true
+}
 
-	if !strings.HasPrefix(absPath, absRoot) {
-		c.JSON(403, gin.H{"error": "禁止访问"})
+// isUserRoot 虚拟路径是否为当前用户被限制的目录本身
+func isUserRoot(c *gin.Context, p string) bool {
+	user, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+	limits, err := userFileLimits(user.ID)
+	return err == nil && limits.Root != "" && limits.Root == p
+}
+
+// fileTarget 审计记录中的文件路径（/<挂载点>/<路径>）
+func fileTarget(mountName, p string) string {
+	if mountName == "" {
+		return p
+	}
+	return path.Join("/", mountName, p)
+}
+
+// GetFileList 获取文件列表
+func GetFileList(c *gin.Context) {
+	m, safePath, fullPath, ok := resolveFilePath(c, c.Query("mount"), c.Query("path"), false)
+	if !ok {
 		return
 	}
 
@@ -77,7 +125,7 @@ func GetFileList(c *gin.Context) {
 		if err != nil {
 			continue
 		}
-		relPath := filepath.ToSlash(filepath.Join(safePath, entry.Name()))
+		relPath := path.Join(safePath, entry.Name())
 		files = append(files, FileInfo{
 			Name:    entry.Name(),
 			Path:    relPath,
@@ -96,42 +144,34 @@ func GetFileList(c *gin.Context) {
 	})
 
 	c.JSON(200, gin.H{
-		"path":  safePath,
-		"root":  webdavRoot,
-		"files": files,
+		"mount":    m.Name,
+		"readOnly": m.ReadOnly,
+		"path":     safePath,
+		"root":     m.Path,
+		"files":    files,
 	})
 }
 
 // CreateDirectory 创建文件夹
 func CreateDirectory(c *gin.Context) {
 	var req struct {
-		Path string `json:"path"`
+		Mount string `json:"mount"`
+		Path  string `json:"path"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": "无效的请求"})
 		return
 	}
+	setAuditTarget(c, fileTarget(req.Mount, req.Path))
 
-	// 安全检查
-	safePath, err := sanitizePath(req.Path)
-	if err != nil {
-		c.JSON(403, gin.H{"error": "非法路径"})
-		return
-	}
-
-	fullPath := filepath.Join(webdavRoot, filepath.FromSlash(safePath))
-
-	// 验证完整路径
-	absRoot, _ := filepath.Abs(webdavRoot)
-	absPath, _ := filepath.Abs(fullPath)
-	if !strings.HasPrefix(absPath, absRoot) {
-		c.JSON(403, gin.H{"error": "禁止访问"})
+	_, safePath, fullPath, ok := resolveFilePath(c, req.Mount, req.Path, true)
+	if !ok {
 		return
 	}
 
 	// 验证文件夹名，禁止特殊字符
-	folderName := filepath.Base(safePath)
-	if folderName == "" || strings.ContainsAny(folderName, "/\\:*?\"<>|") {
+	folderName := path.Base(safePath)
+	if folderName == "" || folderName == "/" || strings.ContainsAny(folderName, "/\\:*?\"<>|") {
 		c.JSON(400, gin.H{"error": "无效的文件夹名"})
 		return
 	}
@@ -152,29 +192,17 @@ func DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// 安全检查
-	safePath, err := sanitizePath(reqPath)
-	if err != nil {
-		c.JSON(403, gin.H{"error": "非法路径"})
+	m, safePath, fullPath, ok := resolveFilePath(c, c.Query("mount"), reqPath, true)
+	if !ok {
 		return
 	}
 
-	// 禁止删除根目录
-	if safePath == "/" {
+	// 禁止删除挂载点根目录和用户被限制的目录本身
+	if safePath == "/" || isUserRoot(c, path.Join("/", m.Name, safePath)) {
 		c.JSON(403, gin.H{"error": "禁止删除根目录"})
 		return
 	}
 
-	fullPath := filepath.Join(webdavRoot, filepath.FromSlash(safePath))
-
-	// 验证完整路径
-	absRoot, _ := filepath.Abs(webdavRoot)
-	absPath, _ := filepath.Abs(fullPath)
-	if !strings.HasPrefix(absPath, absRoot) || absPath == absRoot {
-		c.JSON(403, gin.H{"error": "禁止删除"})
-		return
-	}
-
 	if err := os.RemoveAll(fullPath); err != nil {
 		c.JSON(500, gin.H{"error": "删除失败: " + err.Error()})
 		return
@@ -185,20 +213,15 @@ func DeleteFile(c *gin.Context) {
 
 // UploadFile 上传文件
 func UploadFile(c *gin.Context) {
+	mountName := c.PostForm("mount")
 	targetPath := c.PostForm("path")
 
-	// 安全检查
-	safePath, err := sanitizePath(targetPath)
-	if err != nil {
-		c.JSON(403, gin.H{"error": "非法路径"})
-		return
-	}
-
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(400, gin.H{"error": "未找到上传文件"})
 		return
 	}
+	setAuditTarget(c, fileTarget(mountName, strings.TrimSuffix(targetPath, "/")+"/"+file.Filename))
 
 	// 验证文件名
 	filename := file.Filename
@@ -213,17 +236,12 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	fullPath := filepath.Join(webdavRoot, filepath.FromSlash(safePath), filename)
-
-	// 验证完整路径
-	absRoot, _ := filepath.Abs(webdavRoot)
-	absPath, _ := filepath.Abs(fullPath)
-	if !strings.HasPrefix(absPath, absRoot) {
-		c.JSON(403, gin.H{"error": "禁止访问"})
+	_, _, dir, ok := resolveFilePath(c, mountName, targetPath, true)
+	if !ok {
 		return
 	}
 
-	if err := c.SaveUploadedFile(file, fullPath); err != nil {
+	if err := c.SaveUploadedFile(file, filepath.Join(dir, filename)); err != nil {
 		c.JSON(500, gin.H{"error": "保存文件失败: " + err.Error()})
 		return
 	}
@@ -239,20 +257,8 @@ func DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 安全检查
-	safePath, err := sanitizePath(reqPath)
-	if err != nil {
-		c.JSON(403, gin.H{"error": "非法路径"})
-		return
-	}
-
-	fullPath := filepath.Join(webdavRoot, filepath.FromSlash(safePath))
-
-	// 验证完整路径
-	absRoot, _ := filepath.Abs(webdavRoot)
-	absPath, _ := filepath.Abs(fullPath)
-	if !strings.HasPrefix(absPath, absRoot) {
-		c.JSON(403, gin.H{"error": "禁止访问"})
+	_, _, fullPath, ok := resolveFilePath(c, c.Query("mount"), reqPath, false)
+	if !ok {
 		return
 	}
 
@@ -270,11 +276,95 @@ func DownloadFile(c *gin.Context) {
 	c.FileAttachment(fullPath, filepath.Base(fullPath))
 }
 
-// GetWebdavHandler 获取WebDAV处理器
-func GetWebdavHandler() *webdav.Handler {
-	return &webdav.Handler{
+// WebdavMethods /webdav 端点需要注册的请求方法（gin 的 Any 不包含 WebDAV 扩展方法）
+var WebdavMethods = []string{
+	"GET", "HEAD", "OPTIONS", "PUT", "DELETE", "POST",
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// webdavFS /webdav 的文件系统，每次操作时读取当前挂载点，修改挂载点后无需重建
+var webdavFS webdav.FileSystem = mounts.FileSystem{Mounts: Mounts}
+
+var (
+	webdavLocksMu sync.Mutex
+	webdavLocks   = map[string]webdav.LockSystem{} // 按用户的 WebDAV 根目录
+)
+
+// webdavLockSystem 同一根目录共用锁，不同用户的子目录互不影响
+func webdavLockSystem(root string) webdav.LockSystem {
+	webdavLocksMu.Lock()
+	defer webdavLocksMu.Unlock()
+	ls, ok := webdavLocks[root]
+	if !ok {
+		ls = webdav.NewMemLS()
+		webdavLocks[root] = ls
+	}
+	return ls
+}
+
+// webdavWriteTargets 写操作涉及的虚拟路径（COPY 只写入目标）
+func webdavWriteTargets(c *gin.Context, root string) []string {
+	var targets []string
+	if c.Request.Method != "COPY" {
+		targets = append(targets, path.Join("/", root, strings.TrimPrefix(c.Request.URL.Path, "/webdav")))
+	}
+	if dest := c.GetHeader("Destination"); dest != "" {
+		if u, err := url.Parse(dest); err == nil {
+			targets = append(targets, path.Join("/", root, strings.TrimPrefix(u.Path, "/webdav")))
+		}
+	}
+	return targets
+}
+
+// ServeWebdav WebDAV 端点：以挂载点为一级目录，按 WebDAV 配置限制用户的子目录和只读访问
+// 客户端使用 HTTP Basic 认证（不支持 Digest，见 webdavRealm），未开启 HTTPS 时密码以明文传输
+func ServeWebdav(c *gin.Context) {
+	config, err := loadWebdavConfig()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if config.Disabled {
+		c.JSON(404, gin.H{"error": "WebDAV 已停用"})
+		return
+	}
+
+	var limits WebDAVUserConfig
+	if user, ok := currentUser(c); ok {
+		limits = config.Users[user.ID]
+	}
+	if limits.ReadOnly && !readMethods[c.Request.Method] {
+		c.JSON(403, gin.H{"error": "WebDAV 为只读访问"})
+		return
+	}
+	// 文件系统同样会拒绝写入只读挂载点和修改挂载点本身，但 webdav 包会将其报告为 404、405 或 409
+	if !readMethods[c.Request.Method] {
+		list := Mounts()
+		for _, target := range webdavWriteTargets(c, limits.Root) {
+			if _, rest := mounts.Split(target); rest == "/" {
+				c.JSON(403, gin.H{"error": "不能修改挂载点本身"})
+				return
+			}
+			if m, ok := mounts.Lookup(list, target); ok && m.ReadOnly {
+				c.JSON(403, gin.H{"error": "挂载点 " + m.Name + " 为只读"})
+				return
+			}
+		}
+	}
+
+	fs := webdavFS
+	if limits.Root != "" {
+		if info, err := fs.Stat(c.Request.Context(), limits.Root); err != nil || !info.IsDir() {
+			c.JSON(404, gin.H{"error": "WebDAV 目录不存在"})
+			return
+		}
+		fs = mounts.Sub(fs, limits.Root)
+	}
+
+	handler := &webdav.Handler{
 		Prefix:     "/webdav",
-		FileSystem: webdav.Dir(webdavRoot),
-		LockSystem: webdav.NewMemLS(),
+		FileSystem: fs,
+		LockSystem: webdavLockSystem(limits.Root),
 	}
+	handler.ServeHTTP(c.Writer, c.Request)
 }
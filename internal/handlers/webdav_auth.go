@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"homedash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webdavRealm 未登录访问 /webdav 时要求客户端使用 Basic 认证。只支持 Basic：密码以 bcrypt 哈希保存，
+// 无法计算 Digest 认证所需的摘要。Basic 以明文传输密码，需通过 HTTPS（TLS_CERT_FILE 或 TLS_SELF_SIGNED）使用
+const webdavRealm = `Basic realm="HomeDash WebDAV", charset="UTF-8"`
+
+// webdavCredentialTTL Basic 认证结果的缓存时间。WebDAV 客户端每个请求都携带密码，
+// 缓存后不必每次计算 bcrypt，也不会为每个请求记录一次登录
+const webdavCredentialTTL = 10 * time.Minute
+
+// webdavCredential 缓存的认证结果，用户修改密码或启用两步验证后失效
+type webdavCredential struct {
+	userID       string
+	passwordHash string
+	expiresAt    time.Time
+}
+
+var (
+	webdavCredentialsMu sync.Mutex
+	webdavCredentials   = map[[32]byte]webdavCredential{}
+)
+
+func webdavCredentialKey(username, password string) [32]byte {
+	return sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(username)) + "\x00" + password))
+}
+
+// cachedWebdavUser 查找仍然有效的缓存
+func cachedWebdavUser(key [32]byte) (auth.User, bool) {
+	webdavCredentialsMu.Lock()
+	cred, ok := webdavCredentials[key]
+	if ok && time.Now().After(cred.expiresAt) {
+		delete(webdavCredentials, key)
+		ok = false
+	}
+	webdavCredentialsMu.Unlock()
+	if !ok {
+		return auth.User{}, false
+	}
+	user, ok := authManager.User(cred.userID)
+	if !ok || user.PasswordHash != cred.passwordHash || user.TOTPEnabled {
+		return auth.User{}, false
+	}
+	return user, true
+}
+
+func cacheWebdavUser(key [32]byte, user auth.User) {
+	now := time.Now()
+	webdavCredentialsMu.Lock()
+	defer webdavCredentialsMu.Unlock()
+	for k, cred := range webdavCredentials {
+		if now.After(cred.expiresAt) {
+			delete(webdavCredentials, k)
+		}
+	}
+	webdavCredentials[key] = webdavCredential{userID: user.ID, passwordHash: user.PasswordHash, expiresAt: now.Add(webdavCredentialTTL)}
+}
+
+// webdavBasicAuth 校验 WebDAV 客户端的 Basic 认证，密码可以是账号密码或 API 令牌。
+// 启用两步验证的账号只能使用 API 令牌
+func webdavBasicAuth(c *gin.Context, username, password string) bool {
+	ip := c.ClientIP()
+	if auth.IsAPIToken(password) {
+		token, user, ok := authManager.TokenUser(password, ip)
+		if !ok || !strings.EqualFold(user.Username, strings.TrimSpace(username)) {
+			rejectWebdav(c, "API 令牌无效或已过期")
+			return false
+		}
+		c.Set(ctxUser, user)
+		c.Set(ctxToken, token)
+		return true
+	}
+
+	key := webdavCredentialKey(username, password)
+	user, ok := cachedWebdavUser(key)
+	if !ok {
+		var err error
+		user, err = authManager.Login(username, password, "", ip)
+		if errors.Is(err, auth.ErrTOTPRequired) {
+			rejectWebdav(c, "该账号已启用两步验证，请使用 API 令牌作为密码")
+			return false
+		}
+		if err != nil {
+			rejectWebdav(c, err.Error())
+			return false
+		}
+		cacheWebdavUser(key, user)
+	}
+	c.Set(ctxUser, user)
+	c.Set(ctxAuthVia, "webdav:basic")
+	return true
+}
+
+// rejectWebdav 认证失败，要求客户端重新输入账号密码
+func rejectWebdav(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", webdavRealm)
+	c.AbortWithStatusJSON(401, gin.H{"error": message})
+}
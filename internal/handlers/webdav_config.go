@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"homedash/internal/sftpd"
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebDAVConfig /webdav 端点配置（仅管理员可修改），零值为启用且所有用户访问全部挂载点
+// 端点只支持 Basic 认证，启用时应同时开启 HTTPS
+type WebDAVConfig struct {
+	Disabled bool                        `json:"disabled"` // 停用 /webdav 端点（文件管理页面和 SFTP 不受影响）
+	Users    map[string]WebDAVUserConfig `json:"users"`    // 按用户 ID，同时作用于文件管理接口和 SFTP
+}
+
+// WebDAVUserConfig 单个用户访问文件时的限制（/webdav、文件管理接口和 SFTP 共用）
+type WebDAVUserConfig struct {
+	Root     string `json:"root,omitempty"` // 虚拟路径 /<挂载点>/<子目录>，空为全部挂载点
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+var (
+	webdavConfigMu sync.RWMutex
+	webdavConfig   *WebDAVConfig // 首次使用时从存储加载
+)
+
+// loadWebdavConfig 读取 WebDAV 配置（每个 WebDAV 请求都会用到，加载后缓存在内存中）
+func loadWebdavConfig() (WebDAVConfig, error) {
+	webdavConfigMu.RLock()
+	cached := webdavConfig
+	webdavConfigMu.RUnlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	var config WebDAVConfig
+	if backend == nil {
+		return config, nil
+	}
+	data, err := backend.Load(storage.KeyWebDAV)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return config, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("WebDAV 配置无法解析: %v", err)
+		}
+	}
+	webdavConfigMu.Lock()
+	webdavConfig = &config
+	webdavConfigMu.Unlock()
+	return config, nil
+}
+
+// userFileLimits 用户的文件访问限制，未配置时不限制
+func userFileLimits(userID string) (WebDAVUserConfig, error) {
+	config, err := loadWebdavConfig()
+	if err != nil {
+		return WebDAVUserConfig{}, err
+	}
+	return config.Users[userID], nil
+}
+
+// SFTPLimits 供 SFTP 服务器使用的用户访问限制
+func SFTPLimits(userID string) (sftpd.Limits, error) {
+	limits, err := userFileLimits(userID)
+	return sftpd.Limits{Root: limits.Root, ReadOnly: limits.ReadOnly}, err
+}
+
+// validate 检查并规范化 WebDAV 配置
+func (w *WebDAVConfig) validate() error {
+	for id, user := range w.Users {
+		if authManager != nil {
+			if _, ok := authManager.User(id); !ok {
+				return fmt.Errorf("用户 %q 不存在", id)
+			}
+		}
+		if user.Root != "" {
+			root, err := sanitizePath(user.Root)
+			if err != nil {
+				return fmt.Errorf("无效的子目录 %q", user.Root)
+			}
+			user.Root = root
+			if root == "/" {
+				user.Root = ""
+			}
+		}
+		if user == (WebDAVUserConfig{}) {
+			delete(w.Users, id)
+			continue
+		}
+		w.Users[id] = user
+	}
+	return nil
+}
+
+// GetWebdavConfig 获取 WebDAV 配置
+func GetWebdavConfig(c *gin.Context) {
+	config, err := loadWebdavConfig()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, config)
+}
+
+// UpdateWebdavConfig 修改 WebDAV 配置，立即对之后的请求生效
+func UpdateWebdavConfig(c *gin.Context) {
+	var config WebDAVConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if err := config.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	webdavConfigMu.Lock()
+	defer webdavConfigMu.Unlock()
+	if err := backend.Save(storage.KeyWebDAV, data); err != nil {
+		c.JSON(500, gin.H{"error": "保存 WebDAV 配置失败: " + err.Error()})
+		return
+	}
+	webdavConfig = &config
+	c.JSON(200, config)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"homedash/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkWSOrigin WebSocket 握手的来源校验：同源或认证配置中的允许来源
+func checkWSOrigin(r *http.Request) bool {
+	if authManager == nil {
+		return true
+	}
+	return authManager.CheckOrigin(r)
+}
+
+// WebSocketGuard WebSocket 连接的保护：校验 Origin，并要求已登录（会话或 API 令牌）且携带一次性票据（?ticket=）。
+// 票据只能通过 POST /api/ws/ticket 获取（会话需通过 CSRF 校验），其他网站无法代替用户连接；
+// 握手是 GET 请求，但终端、SSH 等连接可执行命令，不能经公开路由匿名访问
+func WebSocketGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkWSOrigin(c.Request) {
+			rejectWebSocket(c, 403, "不允许的来源: "+c.GetHeader("Origin"))
+			return
+		}
+		if authManager == nil {
+			c.Next()
+			return
+		}
+		owner, ok := wsTicketOwner(c)
+		if !ok {
+			rejectWebSocket(c, 401, "未登录")
+			return
+		}
+		if !authManager.ConsumeWSTicket(c.Query("ticket"), owner) {
+			rejectWebSocket(c, 401, "WebSocket 票据无效或已过期")
+			return
+		}
+		c.Next()
+	}
+}
+
+// wsTicketOwner 票据绑定的凭据：登录会话或 API 令牌
+func wsTicketOwner(c *gin.Context) (string, bool) {
+	if token, ok := currentToken(c); ok {
+		return "token:" + token.ID, true
+	}
+	if session, ok := currentSession(c); ok {
+		return "session:" + session.ID, true
+	}
+	return "", false
+}
+
+// rejectWebSocket 拒绝连接并写入审计日志（跨站连接尝试需要留痕）
+func rejectWebSocket(c *gin.Context, status int, message string) {
+	if auditLog != nil {
+		entry := auditEntry(c, "websocket.reject", c.Request.URL.Path)
+		entry.Outcome = audit.OutcomeDenied
+		entry.Status = status
+		entry.Detail = message
+		auditLog.Record(entry)
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
+}
+
+// IssueWSTicket 为当前会话或 API 令牌签发 WebSocket 连接票据（30 秒内有效，只能使用一次）
+func IssueWSTicket(c *gin.Context) {
+	if !requireAuth(c) {
+		return
+	}
+	owner, ok := wsTicketOwner(c)
+	if !ok {
+		c.JSON(401, gin.H{"error": "未登录"})
+		return
+	}
+	ticket, err := authManager.IssueWSTicket(owner)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成票据失败"})
+		return
+	}
+	c.JSON(200, gin.H{"ticket": ticket})
+}
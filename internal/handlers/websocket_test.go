@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"homedash/internal/auth"
+	"homedash/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newWSTestRouter 启用认证的路由：POST /api/ws/ticket 签发票据，GET /ws/test 受 WebSocketGuard 保护
+func newWSTestRouter(t *testing.T) (*gin.Engine, *auth.Manager) {
+	t.Helper()
+	m, err := auth.NewManager(storage.NewJSONBackend(t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	InitAuth(m)
+	t.Cleanup(func() { InitAuth(nil) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthRequired())
+	router.POST("/api/ws/ticket", IssueWSTicket)
+	router.GET("/ws/test", WebSocketGuard(), func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+	return router, m
+}
+
+func TestWebSocketGuard(t *testing.T) {
+	router, m := newWSTestRouter(t)
+	user, err := m.Setup("admin", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie, session, err := m.CreateSession(user.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer, _, err := m.CreateToken(user.ID, "脚本", []string{"monitor:read"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const host = "nas.local:29678"
+	withSession := func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+		r.Header.Set(csrfHeader, session.CSRFToken)
+	}
+	withToken := func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	issue := func(authorize func(*http.Request)) string {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/ws/ticket", nil)
+		r.Host = host
+		authorize(r)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != 200 {
+			t.Fatalf("签发票据: status = %d, body = %s", w.Code, w.Body)
+		}
+		var body struct {
+			Ticket string `json:"ticket"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Ticket
+	}
+	connect := func(authorize func(*http.Request), origin, ticket string) int {
+		r := httptest.NewRequest("GET", "/ws/test?ticket="+ticket, nil)
+		r.Host = host
+		if authorize != nil {
+			authorize(r)
+		}
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	ticket := issue(withSession)
+	if code := connect(withSession, "https://evil.example.com", ticket); code != 403 {
+		t.Errorf("其他来源: status = %d, want 403", code)
+	}
+	ticket = issue(withSession)
+	if code := connect(withSession, "http://"+host, ticket); code != 200 {
+		t.Fatalf("同源且票据有效: status = %d, want 200", code)
+	}
+	if code := connect(withSession, "http://"+host, ticket); code != 401 {
+		t.Errorf("重复使用票据: status = %d, want 401", code)
+	}
+	if code := connect(withSession, "http://"+host, ""); code != 401 {
+		t.Errorf("没有票据: status = %d, want 401", code)
+	}
+
+	// 会话签发的票据不能被 API 令牌使用，令牌同样需要票据
+	ticket = issue(withSession)
+	if code := connect(withToken, "", ticket); code != 401 {
+		t.Errorf("令牌使用会话的票据: status = %d, want 401", code)
+	}
+	if code := connect(withToken, "", ""); code != 401 {
+		t.Errorf("令牌没有票据: status = %d, want 401", code)
+	}
+	if code := connect(withToken, "", issue(withToken)); code != 200 {
+		t.Errorf("令牌且票据有效: status = %d, want 200", code)
+	}
+
+	if code := connect(nil, "", issue(withSession)); code != 401 {
+		t.Errorf("未登录: status = %d, want 401", code)
+	}
+}
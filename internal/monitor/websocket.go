@@ -9,46 +9,65 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// upgrader 未设置 CheckOrigin 时只允许同源连接
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 允许所有来源
-	},
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
+// Event 推送给浏览器的事件消息，以 type 字段与系统状态区分
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
 // Hub 管理所有 WebSocket 连接
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan SystemStats
+	broadcast  chan interface{} // SystemStats 或 Event
 	register   chan *Client
 	unregister chan *Client
-	collector  *Collector
+	collector  *Collector // 为空时只推送事件
 	mu         sync.RWMutex
+
+	// CheckOrigin 校验握手请求的来源，为空时只允许同源
+	CheckOrigin func(r *http.Request) bool
 }
 
 // Client WebSocket 客户端
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
-	send chan SystemStats
+	send chan interface{}
 }
 
 // NewHub 创建新的 Hub
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan SystemStats),
+		broadcast:  make(chan interface{}),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		collector:  NewCollector(),
 	}
 }
 
+// NewEventHub 创建只推送事件、不采集系统信息的 Hub
+func NewEventHub() *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan interface{}),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+	}
+}
+
 // Run 运行 Hub
 func (h *Hub) Run() {
 	// 启动数据采集协程
-	go h.collectLoop()
+	if h.collector != nil {
+		go h.collectLoop()
+	}
 
 	for {
 		select {
@@ -67,11 +86,11 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 			log.Printf("客户端断开，当前连接数: %d", len(h.clients))
 
-		case stats := <-h.broadcast:
+		case msg := <-h.broadcast:
 			h.mu.RLock()
 			for client := range h.clients {
 				select {
-				case client.send <- stats:
+				case client.send <- msg:
 				default:
 					// 发送失败，关闭连接
 					close(client.send)
@@ -103,7 +122,11 @@ func (h *Hub) collectLoop() {
 
 // HandleWebSocket 处理 WebSocket 连接
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	u := upgrader
+	if h.CheckOrigin != nil {
+		u.CheckOrigin = h.CheckOrigin
+	}
+	conn, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket 升级失败: %v", err)
 		return
@@ -112,7 +135,7 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	client := &Client{
 		hub:  h,
 		conn: conn,
-		send: make(chan SystemStats, 10),
+		send: make(chan interface{}, 10),
 	}
 
 	h.register <- client
@@ -124,8 +147,9 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	go client.readPump()
 
 	// 立即发送一次数据
-	stats := h.collector.Collect()
-	client.send <- stats
+	if h.collector != nil {
+		client.send <- h.collector.Collect()
+	}
 }
 
 // writePump 向客户端发送数据
@@ -134,8 +158,8 @@ func (c *Client) writePump() {
 		c.conn.Close()
 	}()
 
-	for stats := range c.send {
-		err := c.conn.WriteJSON(stats)
+	for msg := range c.send {
+		err := c.conn.WriteJSON(msg)
 		if err != nil {
 			log.Printf("发送数据失败: %v", err)
 			return
@@ -168,6 +192,11 @@ func (c *Client) readPump() {
 	}
 }
 
+// Broadcast 向所有已连接的客户端推送事件
+func (h *Hub) Broadcast(eventType string, data interface{}) {
+	h.broadcast <- Event{Type: eventType, Data: data}
+}
+
 // GetClientCount 获取当前连接数
 func (h *Hub) GetClientCount() int {
 	h.mu.RLock()
@@ -0,0 +1,242 @@
+package mounts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// writeFlags 打开文件时表示写入的标志
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+// FileSystem 以挂载点为一级目录的 WebDAV 文件系统。根目录是只读的虚拟目录，
+// 每次操作都通过 Mounts 获取挂载点列表（如 Table.List），挂载点修改后立即生效
+type FileSystem struct {
+	Mounts func() []Mount
+}
+
+// resolve 返回挂载点和子路径，根目录返回零值挂载点
+func (fs FileSystem) resolve(name string) (Mount, string, error) {
+	mountName, rest := Split(name)
+	if mountName == "" {
+		return Mount{}, "/", nil
+	}
+	m, ok := Find(fs.Mounts(), mountName)
+	if !ok {
+		return Mount{}, "", os.ErrNotExist
+	}
+	return m, rest, nil
+}
+
+// writable 检查是否可以修改挂载点中的路径（挂载点本身不能删除或重命名）
+func (fs FileSystem) writable(name string) (Mount, string, error) {
+	m, rest, err := fs.resolve(name)
+	if err != nil {
+		return m, rest, err
+	}
+	if m.Name == "" || rest == "/" || m.ReadOnly {
+		return m, rest, os.ErrPermission
+	}
+	return m, rest, nil
+}
+
+func (fs FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	m, rest, err := fs.resolve(name)
+	if err != nil {
+		return err
+	}
+	if m.Name == "" || rest == "/" {
+		return os.ErrExist
+	}
+	if m.ReadOnly {
+		return os.ErrPermission
+	}
+	return webdav.Dir(m.Path).Mkdir(ctx, rest, perm)
+}
+
+func (fs FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	m, rest, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if flag&writeFlags != 0 && (m.Name == "" || m.ReadOnly) {
+		return nil, os.ErrPermission
+	}
+	if m.Name == "" {
+		return &rootDir{entries: RootEntries(fs.Mounts())}, nil
+	}
+	f, err := webdav.Dir(m.Path).OpenFile(ctx, rest, flag, perm)
+	if err != nil || rest != "/" {
+		return f, err
+	}
+	return mountDir{File: f, name: m.Name}, nil
+}
+
+func (fs FileSystem) RemoveAll(ctx context.Context, name string) error {
+	m, rest, err := fs.writable(name)
+	if err != nil {
+		return err
+	}
+	return webdav.Dir(m.Path).RemoveAll(ctx, rest)
+}
+
+func (fs FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	from, oldRest, err := fs.writable(oldName)
+	if err != nil {
+		return err
+	}
+	to, newRest, err := fs.writable(newName)
+	if err != nil {
+		return err
+	}
+	oldPath, err := Join(from.Path, oldRest)
+	if err != nil {
+		return err
+	}
+	newPath, err := Join(to.Path, newRest)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+func (fs FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	m, rest, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if m.Name == "" {
+		return RootInfo(), nil
+	}
+	info, err := webdav.Dir(m.Path).Stat(ctx, rest)
+	if err != nil || rest != "/" {
+		return info, err
+	}
+	return namedInfo{FileInfo: info, name: m.Name}, nil
+}
+
+// Sub 以 dir 为根目录的文件系统（用于限制用户只能访问某个子目录）
+func Sub(fs webdav.FileSystem, dir string) webdav.FileSystem {
+	return subFS{fs: fs, dir: path.Clean("/" + dir)}
+}
+
+type subFS struct {
+	fs  webdav.FileSystem
+	dir string
+}
+
+func (s subFS) join(name string) string {
+	return path.Join(s.dir, path.Clean("/"+name))
+}
+
+func (s subFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return s.fs.Mkdir(ctx, s.join(name), perm)
+}
+
+func (s subFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	return s.fs.OpenFile(ctx, s.join(name), flag, perm)
+}
+
+func (s subFS) RemoveAll(ctx context.Context, name string) error {
+	if path.Clean("/"+name) == "/" {
+		return os.ErrPermission
+	}
+	return s.fs.RemoveAll(ctx, s.join(name))
+}
+
+func (s subFS) Rename(ctx context.Context, oldName, newName string) error {
+	if path.Clean("/"+oldName) == "/" || path.Clean("/"+newName) == "/" {
+		return os.ErrPermission
+	}
+	return s.fs.Rename(ctx, s.join(oldName), s.join(newName))
+}
+
+func (s subFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return s.fs.Stat(ctx, s.join(name))
+}
+
+// RootInfo 虚拟根目录的文件信息
+func RootInfo() os.FileInfo {
+	return dirInfo{name: "/"}
+}
+
+// RootEntries 根目录下的挂载点（目录无法访问时仍然列出，便于发现配置问题）
+func RootEntries(list []Mount) []os.FileInfo {
+	entries := make([]os.FileInfo, 0, len(list))
+	for _, m := range list {
+		if info, err := os.Stat(m.Path); err == nil {
+			entries = append(entries, namedInfo{FileInfo: info, name: m.Name})
+		} else {
+			entries = append(entries, dirInfo{name: m.Name})
+		}
+	}
+	return entries
+}
+
+// namedInfo 以挂载点名称代替本地目录名
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i namedInfo) Name() string { return i.name }
+
+// dirInfo 虚拟目录
+type dirInfo struct {
+	name string
+}
+
+func (d dirInfo) Name() string       { return d.name }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() any           { return nil }
+
+// mountDir 挂载点目录，Stat 返回挂载点名称
+type mountDir struct {
+	webdav.File
+	name string
+}
+
+func (d mountDir) Stat() (os.FileInfo, error) {
+	info, err := d.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return namedInfo{FileInfo: info, name: d.name}, nil
+}
+
+// rootDir 虚拟根目录，列出全部挂载点
+type rootDir struct {
+	entries []os.FileInfo
+	pos     int
+}
+
+var errIsDir = errors.New("是目录")
+
+func (d *rootDir) Close() error                   { return nil }
+func (d *rootDir) Read([]byte) (int, error)       { return 0, errIsDir }
+func (d *rootDir) Write([]byte) (int, error)      { return 0, os.ErrPermission }
+func (d *rootDir) Seek(int64, int) (int64, error) { return 0, nil }
+func (d *rootDir) Stat() (os.FileInfo, error)     { return RootInfo(), nil }
+func (d *rootDir) Readdir(count int) ([]os.FileInfo, error) {
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
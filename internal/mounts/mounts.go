@@ -0,0 +1,133 @@
+// Package mounts 命名挂载点：文件管理、WebDAV 和 SFTP 以挂载点名称为一级目录访问多个本地目录
+package mounts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// DefaultName 未配置挂载点时，由旧的 WebDAV 根目录生成的挂载点名称
+const DefaultName = "files"
+
+var (
+	ErrNotFound = errors.New("挂载点不存在")
+	ErrReadOnly = errors.New("挂载点为只读")
+)
+
+// Mount 挂载点
+type Mount struct {
+	Name     string `json:"name"`               // 出现在 /webdav/<名称>/ 和 /api/files?mount= 中
+	Path     string `json:"path"`               // 本地目录（绝对路径）
+	ReadOnly bool   `json:"readOnly,omitempty"` // 只读挂载点不允许任何写入
+}
+
+// Validate 检查挂载点名称和目录，返回去除首尾空白后的列表
+func Validate(list []Mount) ([]Mount, error) {
+	if len(list) == 0 {
+		return nil, errors.New("至少需要一个挂载点")
+	}
+	res := make([]Mount, 0, len(list))
+	seen := map[string]bool{}
+	for _, m := range list {
+		m.Name = strings.TrimSpace(m.Name)
+		m.Path = strings.TrimSpace(m.Path)
+		if err := validName(m.Name); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(m.Name)
+		if seen[key] {
+			return nil, fmt.Errorf("挂载点名称 %q 重复", m.Name)
+		}
+		seen[key] = true
+		if !filepath.IsAbs(m.Path) {
+			return nil, fmt.Errorf("挂载点 %s 的目录必须是绝对路径", m.Name)
+		}
+		if info, err := os.Stat(m.Path); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("挂载点 %s 的目录不存在", m.Name)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// validName 名称作为 URL 的一段使用，不能含有路径分隔符和 Windows 文件名中的非法字符
+func validName(name string) error {
+	if name == "" || len([]rune(name)) > 64 {
+		return errors.New("挂载点名称不能为空且不超过 64 个字符")
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\:*?\"<>|") || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("挂载点名称 %q 含有非法字符", name)
+	}
+	return nil
+}
+
+// Find 按名称查找挂载点（不区分大小写）
+func Find(list []Mount, name string) (Mount, bool) {
+	for _, m := range list {
+		if strings.EqualFold(m.Name, name) {
+			return m, true
+		}
+	}
+	return Mount{}, false
+}
+
+// Split 将虚拟路径 /<挂载点>/<子路径> 拆分为挂载点名称和以 / 开头的子路径，根目录的名称为空
+func Split(p string) (name, rest string) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "", "/"
+	}
+	name, rest, _ = strings.Cut(p, "/")
+	return name, "/" + rest
+}
+
+// Within 虚拟路径 p 是否位于目录 root 下（含 root 本身），root 为空表示不限制
+func Within(root, p string) bool {
+	if root == "" {
+		return true
+	}
+	root, p = path.Clean("/"+root), path.Clean("/"+p)
+	return root == "/" || p == root || strings.HasPrefix(p, root+"/")
+}
+
+// Lookup 虚拟路径所在的挂载点
+func Lookup(list []Mount, p string) (Mount, bool) {
+	name, _ := Split(p)
+	if name == "" {
+		return Mount{}, false
+	}
+	return Find(list, name)
+}
+
+// Join 将以 / 开头的子路径转换为挂载点目录下的本地路径，不允许越出该目录
+func Join(root, rest string) (string, error) {
+	rest = path.Clean("/" + filepath.ToSlash(rest))
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absPath := filepath.Join(absRoot, filepath.FromSlash(rest))
+	if absPath != absRoot && !strings.HasPrefix(absPath, strings.TrimSuffix(absRoot, string(filepath.Separator))+string(filepath.Separator)) {
+		return "", os.ErrPermission
+	}
+	return absPath, nil
+}
+
+// Resolve 将虚拟路径转换为挂载点和本地路径；根目录不对应任何本地目录，返回 ErrNotFound
+func Resolve(list []Mount, p string) (Mount, string, error) {
+	name, rest := Split(p)
+	m, ok := Find(list, name)
+	if name == "" || !ok {
+		return Mount{}, "", ErrNotFound
+	}
+	local, err := Join(m.Path, rest)
+	if err != nil {
+		return Mount{}, "", err
+	}
+	return m, local, nil
+}
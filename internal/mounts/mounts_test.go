@@ -0,0 +1,146 @@
+package mounts
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// inside 本地路径是否位于 root 下（含 root 本身）
+func inside(root, p string) bool {
+	return p == root || strings.HasPrefix(p, root+string(filepath.Separator))
+}
+
+func TestJoin(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name string
+		root string
+		rest string
+		want string // 相对 root 的路径（/ 分隔），空表示只检查结果位于 root 下
+	}{
+		{"根目录", root, "/", "."},
+		{"空路径", root, "", "."},
+		{"子路径", root, "/a/b.txt", "a/b.txt"},
+		{"没有前导斜杠", root, "a/b.txt", "a/b.txt"},
+		{"上级目录", root, "..", "."},
+		{"越出根目录", root, "../../etc/passwd", "etc/passwd"},
+		{"中间的上级目录", root, "/a/../../../b", "b"},
+		{"同名前缀", root, "/../" + filepath.Base(root) + "x/secret", filepath.Base(root) + "x/secret"},
+		{"根目录带分隔符", root + string(filepath.Separator), "/a", "a"},
+		{"根目录带分隔符越出", root + string(filepath.Separator), "../../x", "x"},
+		{"反斜杠", root, `..\..\secret`, ""},
+		{"盘符", root, `C:\Windows\System32`, ""},
+		{"盘符相对路径", root, `C:..\..\secret`, ""},
+	}
+	if runtime.GOOS == "windows" {
+		// Windows 上反斜杠是路径分隔符，同样不能越出根目录
+		tests = append(tests,
+			struct{ name, root, rest, want string }{"Windows 反斜杠", root, `..\..\secret`, "secret"},
+			struct{ name, root, rest, want string }{"Windows 混合分隔符", root, `a\..\../..\b`, "b"},
+			struct{ name, root, rest, want string }{"Windows 盘符根目录", `C:\`, `..\Windows`, "Windows"},
+		)
+	} else {
+		tests = append(tests,
+			struct{ name, root, rest, want string }{"文件系统根目录", "/", "/../etc/passwd", "etc/passwd"},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Join(tt.root, tt.rest)
+			if err != nil {
+				t.Fatalf("Join(%q, %q): %v", tt.root, tt.rest, err)
+			}
+			absRoot, _ := filepath.Abs(tt.root)
+			if tt.want != "" {
+				if want := filepath.Join(absRoot, filepath.FromSlash(tt.want)); got != want {
+					t.Errorf("Join(%q, %q) = %q, want %q", tt.root, tt.rest, got, want)
+				}
+			}
+			if !inside(strings.TrimSuffix(absRoot, string(filepath.Separator)), got) && got != absRoot {
+				t.Errorf("Join(%q, %q) = %q 越出了根目录", tt.root, tt.rest, got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	list := []Mount{
+		{Name: "files", Path: filepath.Join(dir, "files")},
+		{Name: "ro", Path: filepath.Join(dir, "ro"), ReadOnly: true},
+	}
+	tests := []struct {
+		p       string
+		mount   string
+		local   string // 相对 dir 的路径（/ 分隔）
+		wantErr error
+	}{
+		{"/files", "files", "files", nil},
+		{"/files/a/b.txt", "files", "files/a/b.txt", nil},
+		{"files/a", "files", "files/a", nil},
+		{"/FILES/a", "files", "files/a", nil},
+		{"/files/../ro/x", "ro", "ro/x", nil},
+		{"/files/../../etc/passwd", "", "", ErrNotFound},
+		{"/files/a/../../../etc", "", "", ErrNotFound},
+		{"/../files/a", "files", "files/a", nil},
+		{"/", "", "", ErrNotFound},
+		{"", "", "", ErrNotFound},
+		{"/missing/a", "", "", ErrNotFound},
+		{"/files/" + `..\..\secret`, "files", "", nil},
+	}
+	for _, tt := range tests {
+		m, local, err := Resolve(list, tt.p)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Resolve(%q): err = %v, want %v", tt.p, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if m.Name != tt.mount {
+			t.Errorf("Resolve(%q): mount = %q, want %q", tt.p, m.Name, tt.mount)
+		}
+		if !inside(m.Path, local) {
+			t.Errorf("Resolve(%q) = %q 越出了挂载点 %q", tt.p, local, m.Path)
+		}
+		if tt.local != "" {
+			if want := filepath.Join(dir, filepath.FromSlash(tt.local)); local != want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.p, local, want)
+			}
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		root string
+		p    string
+		want bool
+	}{
+		{"", "/anything", true},
+		{"/", "/files/a", true},
+		{"/files/a", "/files/a", true},
+		{"/files/a", "/files/a/b", true},
+		{"/files/a", "/files/a/b/../c", true},
+		{"/files/a", "/files/ab", false},
+		{"/files/a", "/files/ab/c", false},
+		{"/files/a", "/files/a-b", false},
+		{"/files/a", "/files", false},
+		{"/files/a", "/", false},
+		{"/files/a", "/files/a/../ab", false},
+		{"/files/a", "/files/a/..", false},
+		{"/files/a/", "/files/a/b", true},
+		{"/files/a/", "/files/ab", false},
+		{"files/a", "files/a/b", true},
+		{"/files/a", "/FILES/a/b", false}, // 区分大小写，大小写不同时按不在目录下处理
+	}
+	for _, tt := range tests {
+		if got := Within(tt.root, tt.p); got != tt.want {
+			t.Errorf("Within(%q, %q) = %v, want %v", tt.root, tt.p, got, tt.want)
+		}
+	}
+}
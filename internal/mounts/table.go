@@ -0,0 +1,32 @@
+package mounts
+
+import "sync"
+
+// Table 可在运行时替换的挂载点列表。文件管理、WebDAV 和 SFTP 在每次操作时读取，
+// 替换后立即对三者生效
+type Table struct {
+	mu   sync.RWMutex
+	list []Mount
+}
+
+// NewTable 创建挂载点列表
+func NewTable(list []Mount) *Table {
+	t := &Table{}
+	t.Set(list)
+	return t
+}
+
+// List 当前的挂载点（副本，调用方可以修改）
+func (t *Table) List() []Mount {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]Mount(nil), t.list...)
+}
+
+// Set 替换全部挂载点
+func (t *Table) Set(list []Mount) {
+	list = append([]Mount(nil), list...)
+	t.mu.Lock()
+	t.list = list
+	t.mu.Unlock()
+}
@@ -0,0 +1,274 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Header asciicast v2 文件的第一行
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"` // 秒
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// 事件类型
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r" // 数据为 "列x行"
+)
+
+// Event 录像中的一个事件，文件中保存为 [时间, 类型, 数据]
+type Event struct {
+	Time float64 // 距开始的秒数
+	Type string
+	Data string
+}
+
+// MarshalJSON 编码为 asciicast 的数组形式
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON 解析 asciicast 的数组形式
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return errors.New("无效的录像事件")
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &e.Data)
+}
+
+// Writer 正在进行的录制，每个事件写一行
+type Writer struct {
+	store *Store
+
+	mu      sync.Mutex
+	file    *os.File
+	meta    Meta
+	start   time.Time
+	pending []byte // 被截断的 UTF-8 字符，与下一段输出合并后再写入
+	closed  bool
+}
+
+func newWriter(s *Store, m Meta) (*Writer, error) {
+	file, err := os.OpenFile(s.castPath(m.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{store: s, file: file, meta: m, start: time.UnixMilli(m.StartedAt)}
+	header := Header{
+		Version:   2,
+		Width:     int(m.Cols),
+		Height:    int(m.Rows),
+		Timestamp: m.StartedAt / 1000,
+		Title:     m.Name,
+		Env:       map[string]string{"SHELL": m.Shell, "TERM": "xterm-256color"},
+	}
+	if err := w.writeLine(header); err != nil {
+		file.Close()
+		os.Remove(s.castPath(m.ID))
+		return nil, err
+	}
+	if err := writeMeta(s.metaPath(m.ID), m); err != nil {
+		file.Close()
+		os.Remove(s.castPath(m.ID))
+		return nil, err
+	}
+	return w, nil
+}
+
+// Meta 录像信息（录制中的大小和时长为当前值）
+func (w *Writer) Meta() Meta {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	m := w.meta
+	if !w.closed {
+		m.Duration = time.Since(w.start).Seconds()
+	}
+	return m
+}
+
+// Output 记录终端输出
+func (w *Writer) Output(data []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	data = append(w.pending, data...)
+	n := completeUTF8(data)
+	w.pending = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		w.writeEvent(EventOutput, string(data[:n]))
+	}
+}
+
+// Resize 记录窗口大小变化
+func (w *Writer) Resize(cols, rows uint16) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.writeEvent(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close 结束录制
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	if len(w.pending) > 0 {
+		w.writeEvent(EventOutput, string(w.pending))
+	}
+	w.closed = true
+	w.meta.EndedAt = time.Now().UnixMilli()
+	w.meta.Duration = time.Since(w.start).Seconds()
+	err := w.file.Close()
+	if metaErr := writeMeta(w.store.metaPath(w.meta.ID), w.meta); err == nil {
+		err = metaErr
+	}
+	w.mu.Unlock()
+
+	w.store.finish(w)
+	return err
+}
+
+func (w *Writer) writeEvent(typ, data string) {
+	// 写入失败（如磁盘已满）时放弃该事件，不影响终端本身
+	w.writeLine(Event{Time: roundTime(time.Since(w.start).Seconds()), Type: typ, Data: data})
+}
+
+func (w *Writer) writeLine(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	n, err := w.file.Write(append(line, '\n'))
+	w.meta.Size += int64(n)
+	return err
+}
+
+// completeUTF8 末尾不完整的多字节字符之前的长度
+func completeUTF8(b []byte) int {
+	// 最多回看 3 个字节找到最后一个字符的起始字节
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax+1; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			return i
+		}
+		break
+	}
+	return len(b)
+}
+
+// roundTime 保留到微秒，与 asciinema 一致
+func roundTime(t float64) float64 {
+	return float64(int64(t*1e6)) / 1e6
+}
+
+// Reader 逐个读取录像事件
+type Reader struct {
+	r      *bufio.Reader
+	Header Header
+}
+
+// NewReader 读取文件头
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	line, err := br.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+	var header Header
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != 2 {
+		return nil, errors.New("仅支持 asciicast v2 格式")
+	}
+	return &Reader{r: br, Header: header}, nil
+}
+
+// Next 下一个事件，读完时返回 io.EOF；无法解析的行被跳过
+func (r *Reader) Next() (Event, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Event
+			if json.Unmarshal(line, &e) == nil {
+				return e, nil
+			}
+		}
+		if err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// Play 按录制时的节奏（除以 speed 倍速）依次回调事件；
+// idleLimit > 0 时事件之间的停顿最多为 idleLimit 秒，跳过长时间的空闲
+func (r *Reader) Play(ctx context.Context, speed, idleLimit float64, emit func(Event) error) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	start := time.Now()
+	var last, elapsed float64 // 录像时间、扣除空闲后的播放时间
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		gap := e.Time - last
+		if gap < 0 {
+			gap = 0
+		}
+		if idleLimit > 0 && gap > idleLimit {
+			gap = idleLimit
+		}
+		last = e.Time
+		elapsed += gap / speed
+
+		if wait := time.Until(start.Add(time.Duration(elapsed * float64(time.Second)))); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package recording 以 asciicast v2 格式（asciinema 使用的格式）录制终端会话，
+// 录像可下载后用 asciinema play 播放，也可在网页中回放
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxAge   = 30 * 24 * time.Hour
+	DefaultMaxBytes = 1 << 30
+
+	pruneInterval = time.Hour
+	castExt       = ".cast"
+	metaExt       = ".json"
+)
+
+var (
+	ErrNotFound  = errors.New("录像不存在")
+	ErrRecording = errors.New("会话仍在录制中")
+)
+
+// validID 录像 ID 只能是生成时的格式，防止路径穿越
+var validID = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// Meta 录像信息，与录像文件同名的 .json 文件保存
+type Meta struct {
+	ID        string  `json:"id"`
+	SessionID string  `json:"sessionId"`
+	Name      string  `json:"name"`
+	OwnerID   string  `json:"ownerId"`
+	Owner     string  `json:"owner"`
+	Shell     string  `json:"shell"`
+	Cols      uint16  `json:"cols"`
+	Rows      uint16  `json:"rows"`
+	StartedAt int64   `json:"startedAt"`         // 毫秒时间戳
+	EndedAt   int64   `json:"endedAt,omitempty"` // 录制中为 0
+	Duration  float64 `json:"duration"`          // 秒
+	Size      int64   `json:"size"`              // 录像文件大小（字节）
+}
+
+// Retention 保留策略：超过 MaxAge 的录像被删除，总大小超过 MaxBytes 时从最旧的开始删除（<=0 不限制）
+type Retention struct {
+	MaxAge   time.Duration
+	MaxBytes int64
+}
+
+// Store 录像目录
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	retention Retention
+	active    map[string]*Writer
+}
+
+// Open 打开录像目录，修复上次异常退出时未结束的录像并按保留策略清理
+func Open(dir string, retention Retention) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, retention: retention, active: make(map[string]*Writer)}
+	s.recover()
+	s.Prune(time.Now())
+	go s.pruneLoop()
+	return s, nil
+}
+
+// Create 开始录制新会话
+func (s *Store) Create(m Meta) (*Writer, error) {
+	m.ID = uuid.New().String()[:8]
+	if m.StartedAt == 0 {
+		m.StartedAt = time.Now().UnixMilli()
+	}
+	w, err := newWriter(s, m)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.active[m.ID] = w
+	s.mu.Unlock()
+	return w, nil
+}
+
+// List 全部录像，按开始时间从新到旧
+func (s *Store) List() ([]Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *Store) list() ([]Meta, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+metaExt))
+	if err != nil {
+		return nil, err
+	}
+	metas := make([]Meta, 0, len(files))
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), metaExt)
+		m, err := s.get(id)
+		if err != nil {
+			continue
+		}
+		metas = append(metas, m)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].StartedAt > metas[j].StartedAt })
+	return metas, nil
+}
+
+// Get 查找录像
+func (s *Store) Get(id string) (Meta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(id)
+}
+
+func (s *Store) get(id string) (Meta, error) {
+	if !validID.MatchString(id) {
+		return Meta{}, ErrNotFound
+	}
+	if w, ok := s.active[id]; ok {
+		return w.Meta(), nil
+	}
+	m, err := readMeta(s.metaPath(id))
+	if err != nil {
+		return Meta{}, ErrNotFound
+	}
+	return m, nil
+}
+
+// Path 录像文件路径
+func (s *Store) Path(id string) (string, error) {
+	if _, err := s.Get(id); err != nil {
+		return "", err
+	}
+	return s.castPath(id), nil
+}
+
+// Delete 删除录像，正在录制的不能删除
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	if _, ok := s.active[id]; ok {
+		return ErrRecording
+	}
+	return s.remove(id)
+}
+
+func (s *Store) remove(id string) error {
+	err := os.Remove(s.castPath(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.metaPath(id))
+}
+
+// Prune 按保留策略删除过期录像，正在录制的不受影响
+func (s *Store) Prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metas, err := s.list()
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, m := range metas {
+		total += m.Size
+	}
+	// 从最旧的开始
+	for i := len(metas) - 1; i >= 0; i-- {
+		m := metas[i]
+		if _, ok := s.active[m.ID]; ok {
+			continue
+		}
+		expired := s.retention.MaxAge > 0 && now.Sub(time.UnixMilli(m.StartedAt)) > s.retention.MaxAge
+		oversize := s.retention.MaxBytes > 0 && total > s.retention.MaxBytes
+		if !expired && !oversize {
+			continue
+		}
+		if s.remove(m.ID) == nil {
+			total -= m.Size
+		}
+	}
+}
+
+func (s *Store) pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Prune(time.Now())
+	}
+}
+
+// recover 程序异常退出时录像没有正常结束，按文件修改时间补全结束时间和大小
+func (s *Store) recover() {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*"+metaExt))
+	for _, file := range files {
+		m, err := readMeta(file)
+		if err != nil || m.EndedAt != 0 {
+			continue
+		}
+		info, err := os.Stat(s.castPath(m.ID))
+		if err != nil {
+			os.Remove(file)
+			continue
+		}
+		m.EndedAt = info.ModTime().UnixMilli()
+		m.Duration = float64(m.EndedAt-m.StartedAt) / 1000
+		m.Size = info.Size()
+		writeMeta(file, m)
+	}
+}
+
+// finish 录制结束
+func (s *Store) finish(w *Writer) {
+	s.mu.Lock()
+	delete(s.active, w.meta.ID)
+	s.mu.Unlock()
+	s.Prune(time.Now())
+}
+
+func (s *Store) castPath(id string) string {
+	return filepath.Join(s.dir, id+castExt)
+}
+
+func (s *Store) metaPath(id string) string {
+	return filepath.Join(s.dir, id+metaExt)
+}
+
+func readMeta(path string) (Meta, error) {
+	var m Meta
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+func writeMeta(path string, m Meta) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"path/filepath"
 
+	"homedash/internal/auth"
 	"homedash/internal/handlers"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,13 @@ func SetupRoutes(router *gin.Engine, webDir string, port string) {
 		router.SetHTMLTemplate(tmpl)
 	}
 
+	// 审计与登录校验（需在注册路由之前添加，审计在前以便记录被拒绝的请求）
+	router.Use(handlers.AuditLog(), handlers.AuthRequired())
+
+	// 登录页 / 首次运行初始化页
+	router.GET("/login", handlers.LoginPage)
+	router.GET("/setup", handlers.LoginPage)
+
 	// SPA模式：所有路由都返回同一个页面（包含所有page-view）
 	router.GET("/", func(c *gin.Context) {
 		c.HTML(200, "home.html", gin.H{})
@@ -50,28 +58,82 @@ func SetupRoutes(router *gin.Engine, webDir string, port string) {
 	// 静态文件服务
 	router.StaticFS("/static", http.Dir(webDir))
 
-	// ========== 首页服务入口 ==========
 	api := router.Group("/api")
+
+	// ========== 登录与用户 ==========
+	{
+		api.GET("/auth/status", handlers.GetAuthStatus)
+		api.POST("/auth/setup", handlers.SetupAuth)
+		api.POST("/auth/login", handlers.Login)
+		api.POST("/auth/logout", handlers.Logout)
+		api.GET("/me", handlers.GetMe)
+
+		// 修改密码和管理 API 令牌只能通过登录会话进行
+		session := api.Group("", handlers.RequireSession())
+		session.PUT("/auth/password", handlers.ChangePassword)
+		session.GET("/tokens", handlers.GetTokens)
+		session.POST("/tokens", handlers.CreateToken)
+		session.DELETE("/tokens/:id", handlers.RevokeToken)
+		session.POST("/auth/totp/setup", handlers.BeginTOTP)
+		session.POST("/auth/totp/enable", handlers.EnableTOTP)
+		session.POST("/auth/totp/disable", handlers.DisableTOTP)
+		session.POST("/auth/totp/recovery-codes", handlers.RegenerateRecoveryCodes)
+		api.POST("/ws/ticket", handlers.IssueWSTicket)
+		router.GET("/ws/events", handlers.WebSocketGuard(), handlers.HandleEventsWebSocket)
+
+		// 用户与认证配置仅管理员可管理
+		admin := api.Group("", handlers.RequireAdmin())
+		admin.GET("/auth/config", handlers.GetAuthConfig)
+		admin.PUT("/auth/config", handlers.UpdateAuthConfig)
+		admin.GET("/users", handlers.GetUsers)
+		admin.POST("/users", handlers.CreateUser)
+		admin.PUT("/users/:id/password", handlers.ResetUserPassword)
+		admin.PUT("/users/:id/role", handlers.UpdateUserRole)
+		admin.DELETE("/users/:id", handlers.DeleteUser)
+		admin.DELETE("/users/:id/totp", handlers.ResetUserTOTP)
+
+		// 审计日志
+		admin.GET("/audit", handlers.GetAuditLog)
+		admin.GET("/audit/export", handlers.ExportAuditLog)
+	}
+
+	// ========== 首页服务入口 ==========
 	{
+		services := api.Group("", handlers.RequireArea(auth.AreaServices))
 		// 服务管理
-		api.GET("/services", handlers.GetServices)
-		api.POST("/services", handlers.CreateService)
-		api.PUT("/services/:id", handlers.UpdateService)
-		api.DELETE("/services/:id", handlers.DeleteService)
-		api.POST("/services/import-template", handlers.ImportServiceTemplate)
-		api.GET("/services/:id/ping", handlers.PingService)
-		api.GET("/ping-all", handlers.PingAllServices)
+		services.GET("/services", handlers.GetServices)
+		services.POST("/services", handlers.CreateService)
+		services.GET("/services/:id", handlers.GetService)
+		services.PUT("/services/:id", handlers.UpdateService)
+		services.DELETE("/services/:id", handlers.DeleteService)
+		services.POST("/services/import-template", handlers.ImportServiceTemplate)
+		services.GET("/services/:id/ping", handlers.PingService)
+		services.GET("/ping-all", handlers.PingAllServices)
 
 		// 服务启动和停止
-		api.POST("/services/:id/launch", handlers.LaunchService)
-		api.GET("/services/:id/process-status", handlers.GetServiceProcessStatus)
-		api.POST("/services/:id/stop", handlers.StopService)
+		services.POST("/services/:id/launch", handlers.LaunchService)
+		services.GET("/services/:id/process-status", handlers.GetServiceProcessStatus)
+		services.POST("/services/:id/stop", handlers.StopService)
+
+		// 定时任务与维护模式
+		services.GET("/services/:id/schedules", handlers.GetServiceSchedules)
+		services.POST("/services/:id/maintenance", handlers.UpdateServiceMaintenance)
+		services.GET("/schedules/runs", handlers.GetScheduleRuns)
+
+		// 服务开机自启
+		services.GET("/services/:id/autostart", handlers.GetServiceAutoStart)
+		services.POST("/services/:id/autostart", handlers.UpdateServiceAutoStart)
+
+		// Favicon 和图标
+		services.GET("/favicon", handlers.GetFavicon)
+		services.POST("/upload-icon", handlers.UploadIcon)
 	}
 
 	// ========== 系统监控 ==========
 	{
-		api.GET("/processes", handlers.GetProcesses)
-		router.GET("/ws/monitor", handlers.HandleMonitorWebSocket)
+		monitor := api.Group("", handlers.RequireArea(auth.AreaMonitor))
+		monitor.GET("/processes", handlers.GetProcesses)
+		router.GET("/ws/monitor", handlers.WebSocketGuard(), handlers.RequireArea(auth.AreaMonitor), handlers.HandleMonitorWebSocket)
 	}
 
 	// ========== 进程管理 ==========
@@ -79,75 +141,127 @@ func SetupRoutes(router *gin.Engine, webDir string, port string) {
 
 	// ========== WEBDAV管理 ==========
 	{
-		api.GET("/files", handlers.GetFileList)
-		api.POST("/files/mkdir", handlers.CreateDirectory)
-		api.DELETE("/files", handlers.DeleteFile)
-		api.POST("/files/upload", handlers.UploadFile)
-		api.GET("/files/download", handlers.DownloadFile)
+		files := api.Group("", handlers.RequireArea(auth.AreaFiles))
+		files.GET("/files", handlers.GetFileList)
+		files.POST("/files/mkdir", handlers.CreateDirectory)
+		files.DELETE("/files", handlers.DeleteFile)
+		files.POST("/files/upload", handlers.UploadFile)
+		files.GET("/files/download", handlers.DownloadFile)
+		files.GET("/mounts", handlers.GetMounts)
+
+		// SFTP：每个用户管理自己的公钥，只读用户也可添加
+		sftp := api.Group("", handlers.RequireAreaAccess(auth.AreaFiles, auth.AccessRead))
+		sftp.GET("/sftp", handlers.GetSFTPStatus)
+		sftp.GET("/sftp/keys", handlers.GetSFTPKeys)
+		sftp.POST("/sftp/keys", handlers.AddSFTPKey)
+		sftp.DELETE("/sftp/keys/:id", handlers.DeleteSFTPKey)
 
 		// WebDAV 服务
-		webdavHandler := handlers.GetWebdavHandler()
-		router.Any("/webdav/*path", func(c *gin.Context) {
-			webdavHandler.ServeHTTP(c.Writer, c.Request)
-		})
+		for _, method := range handlers.WebdavMethods {
+			router.Handle(method, "/webdav/*path", handlers.RequireArea(auth.AreaFiles), handlers.ServeWebdav)
+		}
+		files.GET("/webdav/config", handlers.RequireAdmin(), handlers.GetWebdavConfig)
+		files.PUT("/webdav/config", handlers.RequireAdmin(), handlers.UpdateWebdavConfig)
 	}
 
 	// ========== SSH终端 ==========
 	{
-		router.GET("/ws/terminal", handlers.HandleTerminalWebSocket)
+		// 终端可执行任意命令，连接即需要写权限
+		router.GET("/ws/terminal", handlers.WebSocketGuard(), handlers.RequireAreaAccess(auth.AreaTerminal, auth.AccessWrite), handlers.HandleTerminalWebSocket)
+
+		terminal := api.Group("", handlers.RequireAreaAccess(auth.AreaTerminal, auth.AccessWrite))
+		terminal.GET("/terminal/sessions", handlers.GetTerminalSessions)
+		terminal.PUT("/terminal/sessions/:id", handlers.RenameTerminalSession)
+		terminal.DELETE("/terminal/sessions/:id", handlers.KillTerminalSession)
+
+		// 会话录像：创建者和管理员可查看、下载和回放，只有管理员可以删除
+		terminal.GET("/terminal/recordings", handlers.GetTerminalRecordings)
+		terminal.GET("/terminal/recordings/:id", handlers.DownloadTerminalRecording)
+		terminal.DELETE("/terminal/recordings/:id", handlers.RequireAdmin(), handlers.DeleteTerminalRecording)
+		terminal.GET("/terminal/config", handlers.RequireAdmin(), handlers.GetTerminalConfig)
+		terminal.PUT("/terminal/config", handlers.RequireAdmin(), handlers.UpdateTerminalConfig)
+		// SSH 主机：只能看到和使用自己保存的主机
+		terminal.GET("/ssh/hosts", handlers.GetSSHHosts)
+		terminal.POST("/ssh/hosts", handlers.CreateSSHHost)
+		terminal.PUT("/ssh/hosts/:id", handlers.UpdateSSHHost)
+		terminal.DELETE("/ssh/hosts/:id", handlers.DeleteSSHHost)
+		terminal.POST("/ssh/hosts/:id/trust", handlers.TrustSSHHost)
+		router.GET("/ws/terminal/recordings/:id", handlers.WebSocketGuard(), handlers.RequireAreaAccess(auth.AreaTerminal, auth.AccessWrite), handlers.PlayTerminalRecording)
 	}
 
 	// ========== DOCKER管理 ==========
 	{
-		api.GET("/docker/containers", handlers.GetDockerContainers)
-		api.GET("/docker/images", handlers.GetDockerImages)
+		docker := api.Group("", handlers.RequireArea(auth.AreaDocker))
+		docker.GET("/docker/containers", handlers.GetDockerContainers)
+		docker.GET("/docker/images", handlers.GetDockerImages)
+	}
+
+	// ========== 定时任务 ==========
+	{
+		jobs := api.Group("", handlers.RequireArea(auth.AreaServices))
+		jobs.GET("/jobs", handlers.GetJobs)
+		// 任务命令在服务器上执行，创建、修改和手动执行与使用终端等同
+		command := handlers.RequireAreaAccess(auth.AreaTerminal, auth.AccessWrite)
+		jobs.POST("/jobs", command, handlers.CreateJob)
+		jobs.GET("/jobs/:id", handlers.GetJob)
+		jobs.PUT("/jobs/:id", command, handlers.UpdateJob)
+		jobs.DELETE("/jobs/:id", handlers.DeleteJob)
+		jobs.POST("/jobs/:id/run", command, handlers.TriggerJob)
+		jobs.GET("/jobs/:id/runs", handlers.GetJobRuns)
+		jobs.GET("/job-runs", handlers.GetJobRuns)
+		jobs.GET("/job-runs/:runId", handlers.GetJobRun)
+		jobs.POST("/job-runs/:runId/cancel", handlers.CancelJobRun)
 	}
 
 	// ========== AI绘画管理 ==========
 	{
-		api.GET("/comfyui/config", handlers.GetComfyUIConfig)
-		api.POST("/comfyui/config", handlers.UpdateComfyUIConfig)
-		api.POST("/comfyui/workflow/execute", handlers.ExecuteComfyUIWorkflow)
-		api.GET("/comfyui/workflow/status/:id", handlers.GetComfyUIWorkflowStatus)
+		comfyui := api.Group("", handlers.RequireArea(auth.AreaComfyUI))
+		comfyui.GET("/comfyui/config", handlers.GetComfyUIConfig)
+		comfyui.POST("/comfyui/config", handlers.UpdateComfyUIConfig)
+		comfyui.POST("/comfyui/workflow/execute", handlers.ExecuteComfyUIWorkflow)
+		comfyui.GET("/comfyui/workflow/status/:id", handlers.GetComfyUIWorkflowStatus)
 	}
 
 	// ========== 日志查看器 ==========
 	{
-		api.GET("/logs", handlers.GetLogs)
-		api.GET("/logs/services", handlers.GetLogServices)
-		api.GET("/logs/stream", handlers.StreamLogs)
-		api.POST("/logs/:service/clear", handlers.ClearLogs)
+		logs := api.Group("", handlers.RequireArea(auth.AreaLogs))
+		logs.GET("/logs", handlers.GetLogs)
+		logs.GET("/logs/services", handlers.GetLogServices)
+		logs.GET("/logs/stream", handlers.StreamLogs)
+		logs.POST("/logs/:service/clear", handlers.ClearLogs)
 	}
 
 	// ========== 程序设置 ==========
 	{
-		// 背景图
+		// 背景图与用户设置是界面显示所需，登录用户均可读取
 		api.GET("/backgrounds", handlers.GetBackgrounds)
-
-		// 用户设置
 		api.GET("/settings", handlers.GetSettings)
-		api.POST("/settings", handlers.UpdateSettings)
 		api.GET("/ping", handlers.GetSettings)
 
+		settings := api.Group("", handlers.RequireArea(auth.AreaSettings))
+		settings.POST("/settings", handlers.UpdateSettings)
+
+		// 配置导入导出
+		settings.GET("/config/export", handlers.ExportConfig)
+		settings.POST("/config/import", handlers.ImportConfig)
+
+		// 配置历史版本
+		settings.GET("/config/status", handlers.GetConfigStatus)
+		settings.GET("/config/versions/:file", handlers.GetConfigVersions)
+		settings.GET("/config/versions/:file/:version", handlers.GetConfigVersion)
+		settings.GET("/config/versions/:file/:version/diff", handlers.DiffConfigVersion)
+		settings.POST("/config/versions/:file/:version/rollback", handlers.RollbackConfigVersion)
+
 		// WebDAV 根目录
-		api.GET("/webdav-root", handlers.GetWebdavRoot)
-		api.POST("/webdav-root", handlers.UpdateWebdavRoot)
+		settings.PUT("/mounts", handlers.UpdateMounts)
 
 		// 应用配置
-		api.GET("/app-config", func(c *gin.Context) {
+		settings.GET("/app-config", func(c *gin.Context) {
 			handlers.GetAppConfig(c, port)
 		})
-		api.POST("/app-config", handlers.UpdateAppConfig)
-
-		// 服务开机自启
-		api.GET("/services/:id/autostart", handlers.GetServiceAutoStart)
-		api.POST("/services/:id/autostart", handlers.UpdateServiceAutoStart)
+		settings.POST("/app-config", handlers.UpdateAppConfig)
 
 		// 应用重启
-		api.POST("/app/restart", handlers.RestartApplication)
-
-		// Favicon 和图标
-		api.GET("/favicon", handlers.GetFavicon)
-		api.POST("/upload-icon", handlers.UploadIcon)
+		settings.POST("/app/restart", handlers.RestartApplication)
 	}
 }
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"homedash/internal/storage"
+)
+
+// fileRunStore 以 JSON Lines 追加写入的执行记录，同一 ID 以最后一行为准
+type fileRunStore struct {
+	mu    sync.Mutex
+	path  string
+	lines int // 文件中的行数，超过保留条数两倍时压缩
+}
+
+func newFileRunStore(dataDir string) (*fileRunStore, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	s := &fileRunStore{path: filepath.Join(dataDir, "job-runs.jsonl")}
+	_, lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.lines = lines
+	return s, nil
+}
+
+// LoadRuns 按首次写入的顺序返回最近 limit 条记录
+func (s *fileRunStore) LoadRuns(limit int) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, _, err := s.read()
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return runs, err
+}
+
+// SaveRun 追加一行，读取时覆盖同一 ID 的旧记录
+func (s *fileRunStore) SaveRun(run Run) error {
+	return s.saveRuns([]Run{run})
+}
+
+func (s *fileRunStore) saveRuns(runs []Run) error {
+	var data []byte
+	for _, run := range runs {
+		line, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	s.lines += len(runs)
+	return nil
+}
+
+// PruneRuns 行数超过 keep 的两倍时只保留最近 keep 条记录重写文件
+func (s *fileRunStore) PruneRuns(keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lines <= 2*keep {
+		return nil
+	}
+	runs, _, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(runs) > keep {
+		runs = runs[len(runs)-keep:]
+	}
+	var data []byte
+	for _, run := range runs {
+		line, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := storage.WriteFileAtomic(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.lines = len(runs)
+	return nil
+}
+
+// read 读取全部记录及文件行数，损坏的行（如写入中断）会被跳过
+func (s *fileRunStore) read() ([]Run, int, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var runs []Run
+	index := make(map[string]int)
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		var run Run
+		if json.Unmarshal(scanner.Bytes(), &run) != nil || run.ID == "" {
+			continue
+		}
+		if i, ok := index[run.ID]; ok {
+			runs[i] = run
+			continue
+		}
+		index[run.ID] = len(runs)
+		runs = append(runs, run)
+	}
+	return runs, lines, scanner.Err()
+}
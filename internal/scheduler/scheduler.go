@@ -0,0 +1,608 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"homedash/internal/cron"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxRunHistory        = 1000      // 保留的执行记录条数
+	maxOutputBytes       = 64 * 1024 // 执行中可查看的输出字节数
+	maxStoredOutputBytes = 16 * 1024 // 执行结束后保存的输出字节数
+	maxQueuedRuns        = 10        // 每个任务最多排队的执行数
+)
+
+var (
+	ErrJobNotFound = errors.New("任务不存在")
+	ErrRunNotFound = errors.New("执行记录不存在")
+	ErrQueueFull   = errors.New("排队的执行过多")
+	// ErrSave 任务定义保存失败（存储错误，区别于校验错误）
+	ErrSave = errors.New("保存任务失败")
+)
+
+// CommandBuilder 根据命令字符串构建可执行命令，ctx 取消时应终止进程
+type CommandBuilder func(ctx context.Context, command string) (*exec.Cmd, error)
+
+// Scheduler 定时任务调度器
+type Scheduler struct {
+	store Store
+	build CommandBuilder
+
+	mu      sync.Mutex
+	jobs    []Job
+	runs    []Run                 // 按开始时间升序
+	active  map[string]*activeRun // jobID -> 正在执行
+	pending map[string][]string   // jobID -> 排队的 runID
+	once    sync.Once
+
+	// 执行记录在锁内按顺序入队，由 persistLoop 在锁外逐条写入
+	persistMu    sync.Mutex
+	persistQueue []Run
+	pruneRuns    bool
+	persistWake  chan struct{}
+}
+
+// activeRun 正在执行的任务
+type activeRun struct {
+	runID    string
+	cancel   context.CancelFunc
+	canceled bool
+	output   *tailBuffer
+}
+
+// New 创建调度器并加载已保存的任务和执行记录
+func New(store Store, build CommandBuilder) (*Scheduler, error) {
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		return nil, fmt.Errorf("加载任务失败: %v", err)
+	}
+	runs, err := store.LoadRuns(maxRunHistory)
+	if err != nil {
+		return nil, fmt.Errorf("加载执行记录失败: %v", err)
+	}
+
+	s := &Scheduler{
+		store:       store,
+		build:       build,
+		jobs:        jobs,
+		runs:        runs,
+		active:      make(map[string]*activeRun),
+		pending:     make(map[string][]string),
+		persistWake: make(chan struct{}, 1),
+	}
+
+	// 上次退出时未结束的执行视为中断
+	now := time.Now().UnixMilli()
+	for i := range s.runs {
+		run := &s.runs[i]
+		if run.Status == StatusRunning || run.Status == StatusQueued {
+			run.Status = StatusCanceled
+			run.Error = "HomeDash 重启，执行被中断"
+			if run.FinishedAt == 0 {
+				run.FinishedAt = now
+			}
+			s.persistLocked(*run)
+		}
+	}
+
+	go s.persistLoop()
+	return s, nil
+}
+
+// Start 启动调度（使用共享的整分钟时钟）
+func (s *Scheduler) Start() {
+	s.once.Do(func() {
+		cron.EveryMinute(s.tick)
+	})
+}
+
+// tick 触发所有命中当前分钟的任务
+func (s *Scheduler) tick(now time.Time) {
+	for _, job := range s.Jobs() {
+		if !job.Enabled {
+			continue
+		}
+		schedule, err := cron.Parse(job.Cron)
+		if err != nil {
+			log.Printf("任务 %s 表达式无效: %v", job.ID, err)
+			continue
+		}
+		if !schedule.Matches(now) {
+			continue
+		}
+		if _, err := s.Trigger(job.ID, TriggerSchedule); err != nil {
+			log.Printf("触发任务 %s 失败: %v", job.ID, err)
+		}
+	}
+}
+
+// Validate 验证任务定义并补全默认值
+func Validate(job *Job) error {
+	job.Name = strings.TrimSpace(job.Name)
+	job.Command = strings.TrimSpace(job.Command)
+	if job.Name == "" {
+		return fmt.Errorf("任务名称不能为空")
+	}
+	if len(job.Name) > 100 {
+		return fmt.Errorf("任务名称过长（最大100字符）")
+	}
+	if job.Command == "" {
+		return fmt.Errorf("执行命令不能为空")
+	}
+	if _, err := cron.Parse(job.Cron); err != nil {
+		return err
+	}
+	if job.Timeout < 0 {
+		return fmt.Errorf("超时时间无效")
+	}
+	switch job.Policy {
+	case "":
+		job.Policy = PolicySkip
+	case PolicySkip, PolicyQueue, PolicyReplace:
+	default:
+		return fmt.Errorf("并发策略必须是 skip、queue 或 replace")
+	}
+	return nil
+}
+
+// Jobs 返回所有任务
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.jobs...)
+}
+
+// Job 返回指定任务
+func (s *Scheduler) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.jobIndex(id); i >= 0 {
+		return s.jobs[i], true
+	}
+	return Job{}, false
+}
+
+// IsRunning 任务是否正在执行
+func (s *Scheduler) IsRunning(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.active[id]
+	return ok
+}
+
+// CreateJob 创建任务
+func (s *Scheduler) CreateJob(job Job) (Job, error) {
+	if err := Validate(&job); err != nil {
+		return Job{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.ID = uuid.New().String()[:8]
+	job.CreatedAt = time.Now().UnixMilli()
+	job.UpdatedAt = job.CreatedAt
+
+	jobs := append(append([]Job(nil), s.jobs...), job)
+	if err := s.saveJobsLocked(jobs); err != nil {
+		return Job{}, err
+	}
+	s.jobs = jobs
+	return job, nil
+}
+
+// UpdateJob 更新任务，不影响正在进行的执行
+func (s *Scheduler) UpdateJob(id string, job Job) (Job, error) {
+	if err := Validate(&job); err != nil {
+		return Job{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.jobIndex(id)
+	if i < 0 {
+		return Job{}, ErrJobNotFound
+	}
+	job.ID = id
+	job.CreatedAt = s.jobs[i].CreatedAt
+	job.UpdatedAt = time.Now().UnixMilli()
+
+	jobs := append([]Job(nil), s.jobs...)
+	jobs[i] = job
+	if err := s.saveJobsLocked(jobs); err != nil {
+		return Job{}, err
+	}
+	s.jobs = jobs
+	return job, nil
+}
+
+// DeleteJob 删除任务并终止其正在进行的执行
+func (s *Scheduler) DeleteJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.jobIndex(id)
+	if i < 0 {
+		return ErrJobNotFound
+	}
+
+	jobs := append(append([]Job(nil), s.jobs[:i]...), s.jobs[i+1:]...)
+	if err := s.saveJobsLocked(jobs); err != nil {
+		return err
+	}
+	s.jobs = jobs
+
+	s.dropPendingLocked(id, "任务已删除")
+	if a, ok := s.active[id]; ok {
+		a.canceled = true
+		a.cancel()
+	}
+	return nil
+}
+
+// ReplaceJobs 整体替换任务列表（用于配置导入），被移除的任务会终止其执行
+func (s *Scheduler) ReplaceJobs(jobs []Job) error {
+	for i := range jobs {
+		if err := Validate(&jobs[i]); err != nil {
+			return fmt.Errorf("任务 %s: %v", jobs[i].Name, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.saveJobsLocked(jobs); err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		kept[job.ID] = true
+	}
+	for _, job := range s.jobs {
+		if kept[job.ID] {
+			continue
+		}
+		s.dropPendingLocked(job.ID, "任务已删除")
+		if a, ok := s.active[job.ID]; ok {
+			a.canceled = true
+			a.cancel()
+		}
+	}
+	s.jobs = append([]Job(nil), jobs...)
+	return nil
+}
+
+// Trigger 触发一次执行，按任务的并发策略处理正在进行的执行
+func (s *Scheduler) Trigger(id, trigger string) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.jobIndex(id)
+	if i < 0 {
+		return Run{}, ErrJobNotFound
+	}
+	job := s.jobs[i]
+
+	run := Run{
+		ID:        uuid.New().String()[:8],
+		JobID:     job.ID,
+		JobName:   job.Name,
+		Trigger:   trigger,
+		StartedAt: time.Now().UnixMilli(),
+	}
+
+	active, busy := s.active[id]
+	if !busy {
+		run.Status = StatusRunning
+		s.appendRunLocked(run)
+		s.startLocked(job, run.ID)
+		return run, nil
+	}
+
+	switch job.Policy {
+	case PolicyQueue:
+		if len(s.pending[id]) >= maxQueuedRuns {
+			return Run{}, ErrQueueFull
+		}
+		run.Status = StatusQueued
+		s.pending[id] = append(s.pending[id], run.ID)
+	case PolicyReplace:
+		// 终止当前执行，结束后立即开始新的执行
+		s.dropPendingLocked(id, "被新的执行替换")
+		run.Status = StatusQueued
+		s.pending[id] = []string{run.ID}
+		active.canceled = true
+		active.cancel()
+	default:
+		run.Status = StatusSkipped
+		run.Error = "上一次执行尚未结束"
+		run.FinishedAt = run.StartedAt
+	}
+
+	s.appendRunLocked(run)
+	s.persistLocked(run)
+	return run, nil
+}
+
+// Cancel 终止正在进行或排队中的执行
+func (s *Scheduler) Cancel(runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.runIndex(runID)
+	if i < 0 {
+		return ErrRunNotFound
+	}
+	run := s.runs[i]
+
+	switch run.Status {
+	case StatusRunning:
+		if a, ok := s.active[run.JobID]; ok && a.runID == runID {
+			a.canceled = true
+			a.cancel()
+		}
+	case StatusQueued:
+		queue := s.pending[run.JobID]
+		for j, id := range queue {
+			if id == runID {
+				s.pending[run.JobID] = append(queue[:j:j], queue[j+1:]...)
+				break
+			}
+		}
+		s.finishRunLocked(runID, StatusCanceled, -1, "", false, "已取消")
+	default:
+		return fmt.Errorf("执行已结束")
+	}
+	return nil
+}
+
+// Runs 返回执行记录（最新的在前），jobID 为空时返回所有任务的记录，列表中不含输出
+func (s *Scheduler) Runs(jobID string, limit int) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Run, 0)
+	for i := len(s.runs) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if jobID != "" && s.runs[i].JobID != jobID {
+			continue
+		}
+		run := s.runs[i]
+		run.Output = ""
+		result = append(result, run)
+	}
+	return result
+}
+
+// Run 返回单条执行记录，执行中时附带当前已产生的输出
+func (s *Scheduler) Run(runID string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.runIndex(runID)
+	if i < 0 {
+		return Run{}, false
+	}
+	run := s.runs[i]
+	if run.Status == StatusRunning {
+		if a, ok := s.active[run.JobID]; ok && a.runID == runID {
+			run.Output, run.Truncated = a.output.String()
+			run.Duration = time.Now().UnixMilli() - run.StartedAt
+		}
+	}
+	return run, true
+}
+
+// startLocked 在后台执行任务，调用方需持有锁
+func (s *Scheduler) startLocked(job Job, runID string) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(job.Timeout)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	a := &activeRun{runID: runID, cancel: cancel, output: &tailBuffer{limit: maxOutputBytes}}
+	s.active[job.ID] = a
+
+	// 排队的执行从真正开始时计时
+	if i := s.runIndex(runID); i >= 0 {
+		s.runs[i].Status = StatusRunning
+		s.runs[i].StartedAt = time.Now().UnixMilli()
+		s.persistLocked(s.runs[i])
+	}
+
+	go s.execute(ctx, job, a)
+}
+
+// execute 执行命令并记录结果
+func (s *Scheduler) execute(ctx context.Context, job Job, a *activeRun) {
+	defer a.cancel()
+
+	status, exitCode, errMsg := StatusSuccess, 0, ""
+	cmd, err := s.build(ctx, job.Command)
+	if err == nil {
+		cmd.Stdout = a.output
+		cmd.Stderr = a.output
+		// 子进程继承了输出管道时，避免 Wait 无限等待
+		cmd.WaitDelay = 5 * time.Second
+		err = cmd.Run()
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+	} else {
+		exitCode = -1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status, errMsg = StatusTimeout, fmt.Sprintf("执行超时（%d 秒）", job.Timeout)
+	case a.canceled:
+		status, errMsg = StatusCanceled, "已取消"
+	case err != nil:
+		status, errMsg = StatusFailed, err.Error()
+	}
+
+	output, truncated := a.output.String()
+	s.finishRunLocked(a.runID, status, exitCode, output, truncated, errMsg)
+	delete(s.active, job.ID)
+
+	// 启动排队中的下一次执行（任务可能已被删除或修改）
+	if queue := s.pending[job.ID]; len(queue) > 0 {
+		next := queue[0]
+		s.pending[job.ID] = queue[1:]
+		if i := s.jobIndex(job.ID); i >= 0 {
+			s.startLocked(s.jobs[i], next)
+		} else {
+			s.finishRunLocked(next, StatusCanceled, -1, "", false, "任务已删除")
+		}
+	}
+}
+
+// finishRunLocked 更新执行记录为结束状态，输出只保留末尾 maxStoredOutputBytes 字节
+func (s *Scheduler) finishRunLocked(runID, status string, exitCode int, output string, truncated bool, errMsg string) {
+	i := s.runIndex(runID)
+	if i < 0 {
+		return
+	}
+	run := &s.runs[i]
+	run.Status = status
+	run.ExitCode = exitCode
+	run.Output, run.Truncated = storedOutput(output, truncated)
+	run.Error = errMsg
+	run.FinishedAt = time.Now().UnixMilli()
+	run.Duration = run.FinishedAt - run.StartedAt
+	s.persistLocked(*run)
+}
+
+// dropPendingLocked 取消某任务所有排队的执行
+func (s *Scheduler) dropPendingLocked(jobID, reason string) {
+	for _, runID := range s.pending[jobID] {
+		s.finishRunLocked(runID, StatusCanceled, -1, "", false, reason)
+	}
+	delete(s.pending, jobID)
+}
+
+// appendRunLocked 追加执行记录，超出上限时丢弃最旧的记录
+func (s *Scheduler) appendRunLocked(run Run) {
+	s.runs = append(s.runs, run)
+	if over := len(s.runs) - maxRunHistory; over > 0 {
+		s.runs = append(s.runs[:0:0], s.runs[over:]...)
+		s.persistMu.Lock()
+		s.pruneRuns = true
+		s.persistMu.Unlock()
+	}
+}
+
+// saveJobsLocked 保存任务列表，存储错误包装为 ErrSave
+func (s *Scheduler) saveJobsLocked(jobs []Job) error {
+	if err := s.store.SaveJobs(jobs); err != nil {
+		return fmt.Errorf("%w: %v", ErrSave, err)
+	}
+	return nil
+}
+
+// persistLocked 将执行记录的当前状态加入写入队列，入队在 s.mu 内完成以保证顺序
+func (s *Scheduler) persistLocked(run Run) {
+	s.persistMu.Lock()
+	s.persistQueue = append(s.persistQueue, run)
+	s.persistMu.Unlock()
+	select {
+	case s.persistWake <- struct{}{}:
+	default:
+	}
+}
+
+// persistLoop 在 s.mu 之外逐条写入执行记录，慢速存储不会阻塞触发和查询
+func (s *Scheduler) persistLoop() {
+	for range s.persistWake {
+		s.persistMu.Lock()
+		queue, prune := s.persistQueue, s.pruneRuns
+		s.persistQueue, s.pruneRuns = nil, false
+		s.persistMu.Unlock()
+
+		for _, run := range queue {
+			if err := s.store.SaveRun(run); err != nil {
+				log.Printf("保存执行记录 %s 失败: %v", run.ID, err)
+			}
+		}
+		if prune {
+			if err := s.store.PruneRuns(maxRunHistory); err != nil {
+				log.Printf("清理执行记录失败: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) jobIndex(id string) int {
+	for i := range s.jobs {
+		if s.jobs[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Scheduler) runIndex(id string) int {
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if s.runs[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// storedOutput 截取输出末尾用于保存，不截断在多字节字符中间
+func storedOutput(output string, truncated bool) (string, bool) {
+	if len(output) <= maxStoredOutputBytes {
+		return output, truncated
+	}
+	output = output[len(output)-maxStoredOutputBytes:]
+	for len(output) > 0 && !utf8.RuneStart(output[0]) {
+		output = output[1:]
+	}
+	return output, true
+}
+
+// tailBuffer 只保留最后 limit 字节的输出缓冲区
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0:0], b.buf[over:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String 返回当前内容及是否被截断
+func (b *tailBuffer) String() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf), b.truncated
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sqlRunStore 写入 SQLite 的 job_runs 表（由 storage 的迁移创建）
+type sqlRunStore struct {
+	db *sql.DB
+}
+
+// newSQLRunStore 表为空时导入 JSON 后端留下的 job-runs.jsonl（从 JSON 切换到 SQLite 的情况）
+func newSQLRunStore(db *sql.DB, dataDir string) (*sqlRunStore, error) {
+	s := &sqlRunStore{db: db}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM job_runs`).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return s, nil
+	}
+	legacy := filepath.Join(dataDir, "job-runs.jsonl")
+	if _, err := os.Stat(legacy); err != nil {
+		return s, nil
+	}
+	file := &fileRunStore{path: legacy}
+	runs, err := file.LoadRuns(maxRunHistory)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", legacy, err)
+	}
+	if err := s.saveRuns(runs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+const runColumns = `id, job_id, job_name, trigger_by, status, exit_code, output, truncated, error, started_at, finished_at, duration`
+
+// LoadRuns 按触发顺序返回最近 limit 条记录
+func (s *sqlRunStore) LoadRuns(limit int) ([]Run, error) {
+	query := `SELECT ` + runColumns + ` FROM job_runs ORDER BY seq DESC`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		if err := rows.Scan(&r.ID, &r.JobID, &r.JobName, &r.Trigger, &r.Status, &r.ExitCode, &r.Output, &r.Truncated,
+			&r.Error, &r.StartedAt, &r.FinishedAt, &r.Duration); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
+// SaveRun 插入或更新一条记录，更新时保留原有的顺序
+func (s *sqlRunStore) SaveRun(run Run) error {
+	return s.saveRuns([]Run{run})
+}
+
+func (s *sqlRunStore) saveRuns(runs []Run) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range runs {
+		if _, err := tx.Exec(`INSERT INTO job_runs (`+runColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET status = excluded.status, exit_code = excluded.exit_code,
+				output = excluded.output, truncated = excluded.truncated, error = excluded.error,
+				started_at = excluded.started_at, finished_at = excluded.finished_at, duration = excluded.duration`,
+			r.ID, r.JobID, r.JobName, r.Trigger, r.Status, r.ExitCode, r.Output, r.Truncated,
+			r.Error, r.StartedAt, r.FinishedAt, r.Duration); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneRuns 删除最近 keep 条以外的记录
+func (s *sqlRunStore) PruneRuns(keep int) error {
+	_, err := s.db.Exec(`DELETE FROM job_runs WHERE seq NOT IN (SELECT seq FROM job_runs ORDER BY seq DESC LIMIT ?)`, keep)
+	return err
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"homedash/internal/storage"
+)
+
+// Store 任务与执行记录的持久化接口
+type Store interface {
+	LoadJobs() ([]Job, error)
+	SaveJobs(jobs []Job) error
+	// LoadRuns 按触发顺序返回最近 limit 条执行记录
+	LoadRuns(limit int) ([]Run, error)
+	// SaveRun 插入或更新单条执行记录
+	SaveRun(run Run) error
+	// PruneRuns 只保留最近 keep 条执行记录
+	PruneRuns(keep int) error
+}
+
+// runStore 执行记录的存储，saveRuns 按顺序插入或更新多条记录
+type runStore interface {
+	LoadRuns(limit int) ([]Run, error)
+	SaveRun(run Run) error
+	PruneRuns(keep int) error
+	saveRuns(runs []Run) error
+}
+
+// BackendStore 任务定义保存为存储后端中的 jobs 文档，执行记录逐条写入独立的存储
+type BackendStore struct {
+	runStore
+	backend storage.Backend
+}
+
+// OpenStore 按存储后端打开任务存储：执行记录在 SQLite 后端写入 job_runs 表，
+// JSON 后端追加写入 dataDir/job-runs.jsonl。旧版本整体保存的 job-runs 文档在首次打开时导入
+func OpenStore(backend storage.Backend, dataDir string) (*BackendStore, error) {
+	var runs runStore
+	var err error
+	if db, ok := backend.(*storage.SQLiteBackend); ok {
+		runs, err = newSQLRunStore(db.DB(), dataDir)
+	} else {
+		runs, err = newFileRunStore(dataDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &BackendStore{runStore: runs, backend: backend}
+	if err := s.importLegacyRuns(); err != nil {
+		return nil, fmt.Errorf("导入执行记录失败: %v", err)
+	}
+	return s, nil
+}
+
+// LoadJobs 加载任务列表，不存在时返回空列表
+func (s *BackendStore) LoadJobs() ([]Job, error) {
+	var jobs []Job
+	return jobs, s.load(storage.KeyJobs, &jobs)
+}
+
+// SaveJobs 保存任务列表
+func (s *BackendStore) SaveJobs(jobs []Job) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.backend.Save(storage.KeyJobs, data)
+}
+
+// importLegacyRuns 执行记录存储为空时导入旧的 job-runs 文档
+func (s *BackendStore) importLegacyRuns() error {
+	existing, err := s.LoadRuns(1)
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	var runs []Run
+	if err := s.load(storage.KeyJobRuns, &runs); err != nil || len(runs) == 0 {
+		return err
+	}
+	if len(runs) > maxRunHistory {
+		runs = runs[len(runs)-maxRunHistory:]
+	}
+	for i := range runs {
+		runs[i].Output, runs[i].Truncated = storedOutput(runs[i].Output, runs[i].Truncated)
+	}
+	return s.saveRuns(runs)
+}
+
+func (s *BackendStore) load(key string, v interface{}) error {
+	data, err := s.backend.Load(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+// 并发策略：任务上一次执行尚未结束时再次触发的处理方式
+const (
+	PolicySkip    = "skip"    // 跳过本次触发
+	PolicyQueue   = "queue"   // 排队，等上一次结束后执行
+	PolicyReplace = "replace" // 终止上一次执行，立即开始新的
+)
+
+// 执行状态
+const (
+	StatusQueued   = "queued" // 排队中，等待上一次执行结束
+	StatusRunning  = "running"
+	StatusSuccess  = "success"
+	StatusFailed   = "failed"
+	StatusTimeout  = "timeout"
+	StatusCanceled = "canceled"
+	StatusSkipped  = "skipped"
+)
+
+// 触发方式
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Job 定时任务定义
+type Job struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Cron      string `json:"cron"`    // cron 表达式（分 时 日 月 周）
+	Command   string `json:"command"` // 执行命令（与服务启动命令格式相同）
+	Timeout   int    `json:"timeout"` // 超时时间（秒），0 表示不限制
+	Policy    string `json:"policy"`  // 并发策略 skip | queue | replace
+	Enabled   bool   `json:"enabled"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Run 任务执行记录
+type Run struct {
+	ID         string `json:"id"`
+	JobID      string `json:"jobId"`
+	JobName    string `json:"jobName"`
+	Trigger    string `json:"trigger"` // schedule | manual
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exitCode"`
+	Output     string `json:"output,omitempty"` // 合并的 stdout/stderr（超长时只保留末尾）
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"startedAt"`
+	FinishedAt int64  `json:"finishedAt,omitempty"`
+	Duration   int64  `json:"duration"` // 耗时（毫秒）
+}
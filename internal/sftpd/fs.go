@@ -0,0 +1,347 @@
+package sftpd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+
+	"homedash/internal/audit"
+	"homedash/internal/auth"
+	"homedash/internal/mounts"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// session 已登录的 SFTP 连接，实现 sftp.Handlers 的各个接口。
+// 每次操作都重新检查用户和令牌的权限，修改角色或吊销令牌后立即生效
+type session struct {
+	server *Server
+	conn   *ssh.ServerConn
+}
+
+func (sess *session) userID() string {
+	return sess.conn.Permissions.Extensions[extUserID]
+}
+
+// can 当前是否拥有文件区域的指定权限
+func (sess *session) can(access string) bool {
+	user, ok := sess.server.cfg.Auth.User(sess.userID())
+	if !ok || !user.Can(auth.AreaFiles, access) {
+		return false
+	}
+	if id := sess.conn.Permissions.Extensions[extTokenID]; id != "" {
+		token, ok := sess.server.cfg.Auth.Token(id)
+		return ok && token.UserID == user.ID && token.Allows(auth.AreaFiles, access)
+	}
+	return true
+}
+
+// limits 当前用户的访问限制
+func (sess *session) limits() (Limits, error) {
+	if sess.server.cfg.Limits == nil {
+		return Limits{}, nil
+	}
+	return sess.server.cfg.Limits(sess.userID())
+}
+
+// virtualPath 客户端路径对应的虚拟路径：限制了目录的用户以该目录为根
+func virtualPath(limits Limits, p string) string {
+	return path.Join("/", limits.Root, path.Clean("/"+p))
+}
+
+// resolve 检查权限并转换为本地路径。根目录只列出挂载点（见 isRoot），
+// 写操作不能修改只读挂载点，也不能删除或重命名挂载点本身和用户被限制的目录本身
+func (sess *session) resolve(p, access string) (string, error) {
+	if !sess.can(access) {
+		return "", sftp.ErrSSHFxPermissionDenied
+	}
+	limits, err := sess.limits()
+	if err != nil || (access == auth.AccessWrite && limits.ReadOnly) {
+		return "", sftp.ErrSSHFxPermissionDenied
+	}
+	vp := virtualPath(limits, p)
+	m, local, err := mounts.Resolve(sess.server.cfg.Mounts(), vp)
+	if errors.Is(err, mounts.ErrNotFound) {
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", sftp.ErrSSHFxPermissionDenied
+	}
+	if access == auth.AccessWrite {
+		if _, rest := mounts.Split(vp); m.ReadOnly || rest == "/" || (limits.Root != "" && vp == path.Clean(limits.Root)) {
+			return "", sftp.ErrSSHFxPermissionDenied
+		}
+	}
+	return local, nil
+}
+
+// isRoot 是否为列出挂载点的虚拟根目录（限制了目录的用户没有虚拟根目录）
+func (sess *session) isRoot(p string) bool {
+	limits, err := sess.limits()
+	if err != nil {
+		return false
+	}
+	name, _ := mounts.Split(virtualPath(limits, p))
+	return name == ""
+}
+
+// record 记录写操作
+func (sess *session) record(action, target string, err error) {
+	outcome, detail := audit.OutcomeSuccess, ""
+	if err != nil {
+		outcome, detail = audit.OutcomeFailure, err.Error()
+		if errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+			outcome = audit.OutcomeDenied
+		}
+	}
+	sess.server.record(sess.conn, sess.userID(), sess.conn.Permissions.Extensions[extVia], action, target, outcome, detail)
+}
+
+// clientError 去掉错误中的本地路径，只保留客户端看到的路径
+func clientError(err error, p string) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return &os.PathError{Op: pe.Op, Path: p, Err: pe.Err}
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &os.PathError{Op: le.Op, Path: p, Err: le.Err}
+	}
+	return err
+}
+
+// Fileread 打开文件用于下载
+func (sess *session) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	local, err := sess.resolve(r.Filepath, auth.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return nil, clientError(err, r.Filepath)
+	}
+	return f, nil
+}
+
+// Filewrite 打开文件用于上传
+func (sess *session) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return sess.OpenFile(r)
+}
+
+// OpenFile 按客户端请求的标志打开文件（同时读写时也会调用）
+func (sess *session) OpenFile(r *sftp.Request) (sftp.WriterAtReaderAt, error) {
+	local, err := sess.resolve(r.Filepath, auth.AccessWrite)
+	if err != nil {
+		sess.record("sftp.write", r.Filepath, err)
+		return nil, err
+	}
+	pflags := r.Pflags()
+	flags := os.O_WRONLY
+	if pflags.Read {
+		flags = os.O_RDWR
+	}
+	if pflags.Creat {
+		flags |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	if pflags.Append {
+		flags |= os.O_APPEND
+	}
+	f, err := os.OpenFile(local, flags, 0644)
+	if err != nil {
+		err = clientError(err, r.Filepath)
+	}
+	sess.record("sftp.write", r.Filepath, err)
+	if err != nil {
+		return nil, err
+	}
+	if pflags.Append {
+		return appendFile{f}, nil
+	}
+	return f, nil
+}
+
+// appendFile 追加模式打开的文件。客户端仍通过 WriteAt 指定偏移，
+// 而 O_APPEND 打开的文件不支持 WriteAt，这里忽略偏移写到末尾（与 OpenSSH 一致）
+type appendFile struct {
+	*os.File
+}
+
+func (f appendFile) WriteAt(p []byte, _ int64) (int, error) {
+	return f.Write(p)
+}
+
+// Filecmd 修改类操作：Setstat、Rename、Rmdir、Mkdir、Link、Symlink、Remove
+func (sess *session) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Link", "Symlink":
+		// 链接可以指向根目录之外，不允许创建
+		err := sftp.ErrSSHFxOpUnsupported
+		sess.record("sftp.link", r.Filepath+" → "+r.Target, err)
+		return err
+	}
+
+	local, err := sess.resolve(r.Filepath, auth.AccessWrite)
+	if err != nil {
+		sess.record(cmdActions[r.Method], r.Filepath, err)
+		return err
+	}
+	switch r.Method {
+	case "Setstat":
+		// 修改权限、时间和大小不记录审计
+		return clientError(setstat(local, r), r.Filepath)
+	case "Rename", "PosixRename":
+		target, err := sess.resolve(r.Target, auth.AccessWrite)
+		if err == nil {
+			// SFTP 的 Rename 不覆盖已有文件，PosixRename 覆盖
+			if _, statErr := os.Lstat(target); r.Method == "Rename" && statErr == nil {
+				err = sftp.ErrSSHFxFailure
+			} else {
+				err = clientError(os.Rename(local, target), r.Filepath)
+			}
+		}
+		sess.record("sftp.rename", r.Filepath+" → "+r.Target, err)
+		return err
+	case "Mkdir":
+		err = clientError(os.Mkdir(local, 0755), r.Filepath)
+		sess.record("sftp.mkdir", r.Filepath, err)
+		return err
+	case "Rmdir", "Remove":
+		err = clientError(remove(local, r.Method == "Rmdir"), r.Filepath)
+		sess.record("sftp.delete", r.Filepath, err)
+		return err
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// PosixRename 覆盖目标的重命名（posix-rename@openssh.com）
+func (sess *session) PosixRename(r *sftp.Request) error {
+	return sess.Filecmd(r)
+}
+
+// cmdActions 修改类操作对应的审计操作名
+var cmdActions = map[string]string{
+	"Setstat":     "sftp.setstat",
+	"Rename":      "sftp.rename",
+	"PosixRename": "sftp.rename",
+	"Mkdir":       "sftp.mkdir",
+	"Rmdir":       "sftp.delete",
+	"Remove":      "sftp.delete",
+}
+
+// remove 删除文件或空目录，dir 指定期望的类型
+func remove(local string, dir bool) error {
+	info, err := os.Lstat(local)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() != dir {
+		if dir {
+			return &os.PathError{Op: "rmdir", Path: local, Err: errors.New("不是目录")}
+		}
+		return &os.PathError{Op: "remove", Path: local, Err: errors.New("是目录")}
+	}
+	return os.Remove(local)
+}
+
+func setstat(local string, r *sftp.Request) error {
+	flags := r.AttrFlags()
+	attrs := r.Attributes()
+	if flags.Permissions {
+		if err := os.Chmod(local, attrs.FileMode().Perm()); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		if err := os.Chtimes(local, attrs.AccessTime(), attrs.ModTime()); err != nil {
+			return err
+		}
+	}
+	if flags.Size {
+		if err := os.Truncate(local, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filelist 目录列表和文件信息：List、Stat、Readlink
+func (sess *session) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if sess.isRoot(r.Filepath) {
+		if !sess.can(auth.AccessRead) {
+			return nil, sftp.ErrSSHFxPermissionDenied
+		}
+		switch r.Method {
+		case "List":
+			return listerAt(mounts.RootEntries(sess.server.cfg.Mounts())), nil
+		case "Stat":
+			return listerAt{mounts.RootInfo()}, nil
+		}
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+	local, err := sess.resolve(r.Filepath, auth.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(local)
+		if err != nil {
+			return nil, clientError(err, r.Filepath)
+		}
+		infos := make([]os.FileInfo, 0, len(entries))
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				infos = append(infos, info)
+			}
+		}
+		return listerAt(infos), nil
+	case "Stat":
+		info, err := os.Stat(local)
+		if err != nil {
+			return nil, clientError(err, r.Filepath)
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// Lstat 不跟随符号链接的文件信息
+func (sess *session) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	if sess.isRoot(r.Filepath) {
+		if !sess.can(auth.AccessRead) {
+			return nil, sftp.ErrSSHFxPermissionDenied
+		}
+		return listerAt{mounts.RootInfo()}, nil
+	}
+	local, err := sess.resolve(r.Filepath, auth.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(local)
+	if err != nil {
+		return nil, clientError(err, r.Filepath)
+	}
+	return listerAt{info}, nil
+}
+
+// listerAt 文件信息列表
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(dst []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, l[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,225 @@
+package sftpd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"homedash/internal/auth"
+	"homedash/internal/mounts"
+	"homedash/internal/storage"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestVirtualPath(t *testing.T) {
+	tests := []struct {
+		root string
+		p    string
+		want string
+	}{
+		{"", "/files/a", "/files/a"},
+		{"", "files/../ro/x", "/ro/x"},
+		{"", "../../etc/passwd", "/etc/passwd"},
+		{"/files/a", "/", "/files/a"},
+		{"/files/a", "", "/files/a"},
+		{"/files/a", "/x/y", "/files/a/x/y"},
+		{"/files/a", "..", "/files/a"},
+		{"/files/a", "../../etc/passwd", "/files/a/etc/passwd"},
+		{"/files/a", "/x/../../ab/secret", "/files/a/ab/secret"},
+		{"/files/a", "x/../../../ab", "/files/a/ab"},
+		{"/files/a/", "/x", "/files/a/x"},
+	}
+	for _, tt := range tests {
+		if got := virtualPath(Limits{Root: tt.root}, tt.p); got != tt.want {
+			t.Errorf("virtualPath(%q, %q) = %q, want %q", tt.root, tt.p, got, tt.want)
+		}
+	}
+}
+
+// testConn 只提供审计日志需要的连接信息
+type testConn struct {
+	ssh.Conn
+	user string
+}
+
+func (c testConn) User() string         { return c.user }
+func (c testConn) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+// fsTest 挂载点 files（可写）和 ro（只读），files 下有 a/inside.txt 和同名前缀的 ab/secret.txt
+type fsTest struct {
+	dir    string
+	server *Server
+	m      *auth.Manager
+	limits map[string]Limits
+}
+
+func newFSTest(t *testing.T) *fsTest {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"files/a", "files/ab", "ro"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"files/a/inside.txt", "files/ab/secret.txt", "ro/readme.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := auth.NewManager(storage.NewJSONBackend(t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fsTest{dir: dir, m: m, limits: map[string]Limits{}}
+	list := []mounts.Mount{
+		{Name: "files", Path: filepath.Join(dir, "files")},
+		{Name: "ro", Path: filepath.Join(dir, "ro"), ReadOnly: true},
+	}
+	ft.server = &Server{cfg: Config{
+		Auth:   m,
+		Mounts: func() []mounts.Mount { return list },
+		Limits: func(userID string) (Limits, error) { return ft.limits[userID], nil },
+	}}
+	return ft
+}
+
+// session 以新用户登录的 SFTP 会话
+func (ft *fsTest) session(t *testing.T, username string, limits Limits) *session {
+	t.Helper()
+	user, err := ft.m.CreateUser(username, "password123", auth.RoleAdmin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft.limits[user.ID] = limits
+	return &session{server: ft.server, conn: &ssh.ServerConn{
+		Conn:        testConn{user: username},
+		Permissions: &ssh.Permissions{Extensions: map[string]string{extUserID: user.ID}},
+	}}
+}
+
+func TestResolveLimitedRoot(t *testing.T) {
+	ft := newFSTest(t)
+	sess := ft.session(t, "alice", Limits{Root: "/files/a"})
+	root := filepath.Join(ft.dir, "files", "a")
+
+	if sess.isRoot("/") {
+		t.Error("限制了目录的用户不应有虚拟根目录")
+	}
+	for _, p := range []string{"/", "..", "../..", "/../../../", "x/../.."} {
+		local, err := sess.resolve(p, auth.AccessRead)
+		if err != nil || local != root {
+			t.Errorf("resolve(%q) = %q, %v, want %q", p, local, err, root)
+		}
+	}
+
+	// 同名前缀的兄弟目录 /files/ab 不能通过 .. 访问，只会落在 /files/a 之下
+	for _, p := range []string{"../ab/secret.txt", "/../ab/secret.txt", "../../files/ab/secret.txt"} {
+		local, err := sess.resolve(p, auth.AccessRead)
+		if err != nil {
+			t.Errorf("resolve(%q): %v", p, err)
+			continue
+		}
+		if !mounts.Within("/"+filepath.ToSlash(root), "/"+filepath.ToSlash(local)) {
+			t.Errorf("resolve(%q) = %q 越出了 %q", p, local, root)
+		}
+	}
+	if _, err := sess.Fileread(sftp.NewRequest("Get", "../ab/secret.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("读取兄弟目录中的文件: err = %v, want ErrNotExist", err)
+	}
+	if _, err := sess.Fileread(sftp.NewRequest("Get", "/inside.txt")); err != nil {
+		t.Errorf("读取根目录中的文件: %v", err)
+	}
+
+	// 限制的目录本身不能删除或重命名
+	for _, method := range []string{"Rmdir", "Rename"} {
+		r := sftp.NewRequest(method, "/")
+		r.Target = "/moved"
+		if err := sess.Filecmd(r); !errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+			t.Errorf("%s 根目录: err = %v, want 权限不足", method, err)
+		}
+	}
+}
+
+// writeRequests 全部写操作，path 为操作的路径，target 为重命名的目标
+func writeRequests(path, target string) []*sftp.Request {
+	var reqs []*sftp.Request
+	for _, method := range []string{"Setstat", "Rename", "PosixRename", "Mkdir", "Rmdir", "Remove"} {
+		r := sftp.NewRequest(method, path)
+		r.Target = target
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+func TestResolveReadOnly(t *testing.T) {
+	ft := newFSTest(t)
+	tests := []struct {
+		name   string
+		limits Limits
+		path   string
+		target string
+	}{
+		{"只读用户", Limits{ReadOnly: true}, "/files/a/inside.txt", "/files/a/moved.txt"},
+		{"只读用户的根目录", Limits{Root: "/files/a", ReadOnly: true}, "/inside.txt", "/moved.txt"},
+		{"只读挂载点", Limits{}, "/ro/readme.txt", "/ro/moved.txt"},
+		{"挂载点本身", Limits{}, "/files", "/moved"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := ft.session(t, "user"+string(rune('a'+i)), tt.limits)
+
+			r := sftp.NewRequest("Put", tt.path)
+			r.Flags = 0x02 | 0x08 | 0x10 // SSH_FXF_WRITE | SSH_FXF_CREAT | SSH_FXF_TRUNC
+			if _, err := sess.OpenFile(r); !errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+				t.Errorf("OpenFile: err = %v, want 权限不足", err)
+			}
+			if _, err := sess.Filewrite(r); !errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+				t.Errorf("Filewrite: err = %v, want 权限不足", err)
+			}
+			for _, req := range writeRequests(tt.path, tt.target) {
+				if err := sess.Filecmd(req); !errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+					t.Errorf("%s: err = %v, want 权限不足", req.Method, err)
+				}
+			}
+			// 读取不受影响
+			if _, err := sess.resolve(tt.path, auth.AccessRead); err != nil {
+				t.Errorf("读取: %v", err)
+			}
+		})
+	}
+
+	// 可写目录中的文件不能移动到只读挂载点
+	sess := ft.session(t, "mover", Limits{})
+	for _, method := range []string{"Rename", "PosixRename"} {
+		r := sftp.NewRequest(method, "/files/a/inside.txt")
+		r.Target = "/ro/moved.txt"
+		if err := sess.Filecmd(r); !errors.Is(err, sftp.ErrSSHFxPermissionDenied) {
+			t.Errorf("%s 到只读挂载点: err = %v, want 权限不足", method, err)
+		}
+	}
+
+	for _, name := range []string{"files/a/inside.txt", "ro/readme.txt"} {
+		if _, err := os.Stat(filepath.Join(ft.dir, name)); err != nil {
+			t.Errorf("%s 被修改: %v", name, err)
+		}
+	}
+}
+
+func TestLinkRejected(t *testing.T) {
+	ft := newFSTest(t)
+	sess := ft.session(t, "alice", Limits{})
+	for _, method := range []string{"Link", "Symlink"} {
+		r := sftp.NewRequest(method, "/files/a/link")
+		r.Target = "/files/ab/secret.txt"
+		if err := sess.Filecmd(r); !errors.Is(err, sftp.ErrSSHFxOpUnsupported) {
+			t.Errorf("%s: err = %v, want 不支持", method, err)
+		}
+		if _, err := os.Lstat(filepath.Join(ft.dir, "files", "a", "link")); !os.IsNotExist(err) {
+			t.Errorf("%s 创建了链接", method)
+		}
+	}
+}
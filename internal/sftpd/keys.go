@@ -0,0 +1,165 @@
+package sftpd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"homedash/internal/storage"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// lastUsedSaveInterval 最近使用时间的最短保存间隔，避免每次连接都写存储
+const lastUsedSaveInterval = time.Hour
+
+var (
+	ErrKeyNotFound  = errors.New("公钥不存在")
+	ErrKeyDuplicate = errors.New("该公钥已添加")
+)
+
+// Key 用户添加的 SSH 公钥，用于免密登录 SFTP
+type Key struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId"`
+	Name        string `json:"name"`
+	PublicKey   string `json:"publicKey"` // authorized_keys 格式
+	Fingerprint string `json:"fingerprint"`
+	CreatedAt   int64  `json:"createdAt"`
+	LastUsedAt  int64  `json:"lastUsedAt,omitempty"`
+}
+
+// Keys 管理用户的 SFTP 公钥
+type Keys struct {
+	mu      sync.Mutex
+	backend storage.Backend
+	keys    []Key
+}
+
+// NewKeys 从存储加载公钥
+func NewKeys(backend storage.Backend) (*Keys, error) {
+	k := &Keys{backend: backend}
+	data, err := backend.Load(storage.KeySFTPKeys)
+	if errors.Is(err, storage.ErrNotFound) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &k.keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keys) saveLocked(keys []Key) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := k.backend.Save(storage.KeySFTPKeys, data); err != nil {
+		return err
+	}
+	k.keys = keys
+	return nil
+}
+
+// List 用户的公钥
+func (k *Keys) List(userID string) []Key {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := []Key{}
+	for _, key := range k.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Add 添加公钥，name 为空时使用公钥的注释
+func (k *Keys) Add(userID, name, line string) (Key, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(line)))
+	if err != nil {
+		return Key{}, errors.New("无法解析公钥，请粘贴 authorized_keys 格式（如 ssh-ed25519 AAAA... user@host）")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = comment
+	}
+	if name == "" {
+		name = pub.Type()
+	}
+	key := Key{
+		ID:          uuid.New().String()[:8],
+		UserID:      userID,
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		CreatedAt:   time.Now().UnixMilli(),
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, existing := range k.keys {
+		if existing.UserID == userID && existing.Fingerprint == key.Fingerprint {
+			return Key{}, ErrKeyDuplicate
+		}
+	}
+	if err := k.saveLocked(append(append([]Key{}, k.keys...), key)); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// Delete 删除用户的公钥
+func (k *Keys) Delete(userID, id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := make([]Key, 0, len(k.keys))
+	found := false
+	for _, key := range k.keys {
+		if key.ID == id && key.UserID == userID {
+			found = true
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if !found {
+		return ErrKeyNotFound
+	}
+	return k.saveLocked(keys)
+}
+
+// lookup 查找与公钥匹配的记录，并更新最近使用时间
+func (k *Keys) lookup(username string, pub ssh.PublicKey, userID func(username string) (string, bool)) (Key, bool) {
+	id, ok := userID(username)
+	if !ok {
+		return Key{}, false
+	}
+	wire := pub.Marshal()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i := range k.keys {
+		key := &k.keys[i]
+		if key.UserID != id {
+			continue
+		}
+		stored, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil || subtle.ConstantTimeCompare(stored.Marshal(), wire) != 1 {
+			continue
+		}
+		now := time.Now()
+		if now.Sub(time.UnixMilli(key.LastUsedAt)) >= lastUsedSaveInterval {
+			key.LastUsedAt = now.UnixMilli()
+			k.saveLocked(k.keys)
+		}
+		return *key, true
+	}
+	return Key{}, false
+}
@@ -0,0 +1,344 @@
+// Package sftpd 内置 SFTP 服务器：与 WebDAV 共用挂载点，使用 HomeDash 账号、API 令牌或 SSH 公钥登录
+package sftpd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"homedash/internal/audit"
+	"homedash/internal/auth"
+	"homedash/internal/mounts"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const handshakeTimeout = 30 * time.Second
+
+// 登录后保存在 ssh.Permissions.Extensions 中的信息
+const (
+	extUserID  = "homedash-user-id"
+	extTokenID = "homedash-token-id"
+	extVia     = "homedash-via"
+)
+
+var errAuthFailed = errors.New("认证失败")
+
+// Config SFTP 服务器配置
+type Config struct {
+	Addr        string // 监听地址，如 ":2022"
+	HostKeyPath string // 主机私钥，不存在时生成 ed25519 密钥
+	Auth        *auth.Manager
+	Keys        *Keys
+	Audit       *audit.Log
+	// Mounts 返回当前的挂载点，客户端看到的根目录下每个挂载点是一个目录
+	Mounts func() []mounts.Mount
+	// Limits 返回用户的访问限制（可为 nil），读取失败时拒绝该次操作
+	Limits func(userID string) (Limits, error)
+}
+
+// Limits 用户的访问限制，与 /webdav 端点共用同一配置
+type Limits struct {
+	Root     string // 虚拟路径 /<挂载点>/<子目录>，作为客户端看到的根目录；空为全部挂载点
+	ReadOnly bool
+}
+
+// Server SFTP 服务器
+type Server struct {
+	cfg         Config
+	config      *ssh.ServerConfig
+	fingerprint string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*ssh.ServerConn]struct{}
+	closed   bool
+}
+
+// New 加载主机密钥并创建服务器
+func New(cfg Config) (*Server, error) {
+	signer, err := loadHostKey(cfg.HostKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载 SFTP 主机密钥失败: %v", err)
+	}
+	s := &Server{cfg: cfg, fingerprint: ssh.FingerprintSHA256(signer.PublicKey()), conns: map[*ssh.ServerConn]struct{}{}}
+	s.config = &ssh.ServerConfig{
+		ServerVersion:               "SSH-2.0-HomeDash",
+		PasswordCallback:            s.passwordCallback,
+		KeyboardInteractiveCallback: s.keyboardInteractiveCallback,
+		PublicKeyCallback:           s.publicKeyCallback,
+	}
+	s.config.AddHostKey(signer)
+	return s, nil
+}
+
+// Fingerprint 主机公钥的 SHA256 指纹，供用户首次连接时核对
+func (s *Server) Fingerprint() string {
+	return s.fingerprint
+}
+
+// Addr 监听地址
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.cfg.Addr
+}
+
+// ListenAndServe 开始监听，直到 Close 被调用
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close 停止监听并断开全部连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) handleConn(netConn net.Conn) {
+	netConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+	netConn.SetDeadline(time.Time{})
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	// 不支持端口转发等全局请求
+	go ssh.DiscardRequests(reqs)
+
+	sess := &session{server: s, conn: conn}
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "仅支持 SFTP")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go sess.serveChannel(channel, requests)
+	}
+}
+
+// serveChannel 只接受 sftp 子系统请求，不提供 shell 和命令执行
+func (sess *session) serveChannel(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		ok := req.Type == "subsystem" && len(req.Payload) >= 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  sess,
+			FilePut:  sess,
+			FileCmd:  sess,
+			FileList: sess,
+		})
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("SFTP 会话异常结束 (%s): %v", sess.conn.User(), err)
+		}
+		server.Close()
+		return
+	}
+}
+
+// findUserID 按用户名查找用户 ID（不区分大小写）
+func (s *Server) findUserID(username string) (string, bool) {
+	for _, u := range s.cfg.Auth.Users() {
+		if strings.EqualFold(u.Username, strings.TrimSpace(username)) {
+			return u.ID, true
+		}
+	}
+	return "", false
+}
+
+func remoteIP(conn ssh.ConnMetadata) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// login 校验密码或 API 令牌并检查文件访问权限
+func (s *Server) login(conn ssh.ConnMetadata, password, code string) (*ssh.Permissions, error) {
+	ip := remoteIP(conn)
+	if auth.IsAPIToken(password) {
+		token, user, ok := s.cfg.Auth.TokenUser(password, ip)
+		if !ok || !strings.EqualFold(user.Username, conn.User()) {
+			return nil, errAuthFailed
+		}
+		if !token.Allows(auth.AreaFiles, auth.AccessRead) || !user.Can(auth.AreaFiles, auth.AccessRead) {
+			return nil, errAuthFailed
+		}
+		return permissions(user.ID, token.ID, "sftp:token:"+token.Name), nil
+	}
+
+	user, err := s.cfg.Auth.Login(conn.User(), password, code, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Can(auth.AreaFiles, auth.AccessRead) {
+		s.record(conn, user.ID, "sftp:password", "sftp.login", "", audit.OutcomeDenied, "没有文件访问权限")
+		return nil, errAuthFailed
+	}
+	return permissions(user.ID, "", "sftp:password"), nil
+}
+
+func permissions(userID, tokenID, via string) *ssh.Permissions {
+	return &ssh.Permissions{Extensions: map[string]string{extUserID: userID, extTokenID: tokenID, extVia: via}}
+}
+
+func (s *Server) passwordCallback(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	// 启用两步验证的用户需通过 keyboard-interactive 输入验证码
+	return s.login(conn, string(password), "")
+}
+
+// keyboardInteractiveCallback 依次询问密码和两步验证码（WinSCP 等客户端默认使用这种方式）
+func (s *Server) keyboardInteractiveCallback(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := challenge(conn.User(), "", []string{"密码: "}, []bool{false})
+	if err != nil || len(answers) != 1 {
+		return nil, errAuthFailed
+	}
+	password := answers[0]
+	perms, err := s.login(conn, password, "")
+	if !errors.Is(err, auth.ErrTOTPRequired) {
+		return perms, err
+	}
+	answers, err = challenge(conn.User(), "", []string{"两步验证码: "}, []bool{true})
+	if err != nil || len(answers) != 1 {
+		return nil, errAuthFailed
+	}
+	return s.login(conn, password, answers[0])
+}
+
+func (s *Server) publicKeyCallback(conn ssh.ConnMetadata, pub ssh.PublicKey) (*ssh.Permissions, error) {
+	if s.cfg.Keys == nil {
+		return nil, errAuthFailed
+	}
+	key, ok := s.cfg.Keys.lookup(conn.User(), pub, s.findUserID)
+	if !ok {
+		return nil, errAuthFailed
+	}
+	user, ok := s.cfg.Auth.User(key.UserID)
+	if !ok || !user.Can(auth.AreaFiles, auth.AccessRead) {
+		return nil, errAuthFailed
+	}
+	return permissions(user.ID, "", "sftp:key:"+key.Name), nil
+}
+
+// record 写入审计日志
+func (s *Server) record(conn ssh.ConnMetadata, userID, via, action, target, outcome, detail string) {
+	entry := audit.Entry{
+		Actor:   conn.User(),
+		ActorID: userID,
+		Via:     via,
+		IP:      remoteIP(conn),
+		Action:  action,
+		Target:  target,
+		Outcome: outcome,
+		Detail:  detail,
+	}
+	if user, ok := s.cfg.Auth.User(userID); ok {
+		entry.Actor = user.Username
+	}
+	s.cfg.Audit.Record(entry)
+}
+
+// loadHostKey 读取主机私钥，不存在时生成新的 ed25519 密钥
+func loadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "homedash sftp")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(priv)
+}
@@ -0,0 +1,213 @@
+package sshterm
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"homedash/internal/terminal"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	dialTimeout        = 10 * time.Second
+	keepaliveMaxMissed = 3 // 连续多次没有响应视为连接已断开
+)
+
+// keepaliveInterval keepalive 请求的间隔（测试中会缩短）
+var keepaliveInterval = 30 * time.Second
+
+// remotePTY 远程主机上的伪终端，实现 terminal.PTY
+type remotePTY struct {
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	output  *io.PipeReader
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	exited    chan struct{}
+	exitCode  int
+	waitErr   error
+}
+
+// Dial 连接主机并在伪终端中启动登录 shell，主机密钥必须已记录在 known 中
+func Dial(host Host, known *KnownHosts, cols, rows uint16) (terminal.PTY, error) {
+	config := &ssh.ClientConfig{
+		User:            host.User,
+		HostKeyCallback: known.Callback(),
+		Timeout:         dialTimeout,
+	}
+	switch host.AuthType {
+	case AuthKey:
+		signer, err := host.signer()
+		if err != nil {
+			return nil, err
+		}
+		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	default:
+		// 部分服务器只开启了 keyboard-interactive，用同一密码回答
+		password := host.Password
+		config.Auth = []ssh.AuthMethod{
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		}
+	}
+
+	client, err := ssh.Dial("tcp", host.Address(), config)
+	if err != nil {
+		return nil, err
+	}
+	p, err := startShell(client, cols, rows)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func startShell(client *ssh.Client, cols, rows uint16) (*remotePTY, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty("xterm-256color", int(rows), int(cols), modes); err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	// 伪终端中标准错误与标准输出本就是同一设备，合并到一个输出流
+	pr, pw := io.Pipe()
+	session.Stdout = pw
+	session.Stderr = pw
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	p := &remotePTY{
+		client:  client,
+		session: session,
+		stdin:   stdin,
+		output:  pr,
+		closed:  make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go p.wait(pw)
+	go p.keepalive(keepaliveInterval)
+	return p, nil
+}
+
+func (p *remotePTY) wait(pw *io.PipeWriter) {
+	defer close(p.exited)
+	err := p.session.Wait()
+	var exitErr *ssh.ExitError
+	var missingErr *ssh.ExitMissingError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		p.exitCode = exitErr.ExitStatus()
+	case errors.As(err, &missingErr):
+		// 连接断开或远程 shell 被信号结束，没有退出码
+		p.exitCode = -1
+	default:
+		p.exitCode, p.waitErr = -1, err
+	}
+	pw.Close()
+	p.client.Close()
+}
+
+// keepalive 定期发送 keepalive 请求，既防止 NAT 或防火墙断开空闲连接，也能发现已断开的连接
+func (p *remotePTY) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-p.exited:
+			return
+		case <-p.closed:
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := p.client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+		select {
+		case err := <-reply:
+			if err != nil {
+				p.Close()
+				return
+			}
+			missed = 0
+		case <-time.After(interval):
+			missed++
+			if missed >= keepaliveMaxMissed {
+				p.Close()
+				return
+			}
+		}
+	}
+}
+
+func (p *remotePTY) Read(b []byte) (int, error) {
+	return p.output.Read(b)
+}
+
+func (p *remotePTY) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+func (p *remotePTY) Resize(cols, rows uint16) error {
+	return p.session.WindowChange(int(rows), int(cols))
+}
+
+// Signal Ctrl-C 通过输入发送，由远程伪终端转换为 SIGINT；其他信号通过 SSH 协议发送（需要服务器支持）
+func (p *remotePTY) Signal(sig terminal.Signal) error {
+	switch sig {
+	case terminal.SignalInterrupt:
+		_, err := p.stdin.Write([]byte{0x03})
+		return err
+	case terminal.SignalTerminate:
+		return p.session.Signal(ssh.SIGTERM)
+	case terminal.SignalKill:
+		return p.session.Signal(ssh.SIGKILL)
+	default:
+		return terminal.ErrUnknownSignal
+	}
+}
+
+func (p *remotePTY) Wait() (int, error) {
+	<-p.exited
+	return p.exitCode, p.waitErr
+}
+
+// Close 断开连接，远程 shell 随之收到 SIGHUP
+func (p *remotePTY) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.session.Close()
+		p.client.Close()
+	})
+	return nil
+}
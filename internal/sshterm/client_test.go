@@ -0,0 +1,409 @@
+package sshterm
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testUser     = "homedash"
+	testPassword = "secret"
+)
+
+// ptyRequest pty-req 请求的内容（RFC 4254 6.2）
+type ptyRequest struct {
+	Term   string
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+	Modes  string
+}
+
+// windowChange window-change 请求的内容（RFC 4254 6.7）
+type windowChange struct {
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+}
+
+// testServer 进程内的 SSH 服务器：回显 shell 的输入，收到 "exit" 时以退出码 3 结束
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+	userKey ed25519.PrivateKey // 允许登录的客户端密钥
+
+	mu              sync.Mutex
+	ignoreKeepalive bool // 不回复 keepalive，模拟已断开的连接
+
+	ptys       chan ptyRequest
+	resizes    chan windowChange
+	keepalives chan struct{}
+}
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, key
+}
+
+func startTestServer(t *testing.T) *testServer {
+	t.Helper()
+	hostKey, _ := newSigner(t)
+	userSigner, userKey := newSigner(t)
+	s := &testServer{
+		hostKey:    hostKey,
+		userKey:    userKey,
+		ptys:       make(chan ptyRequest, 4),
+		resizes:    make(chan windowChange, 4),
+		keepalives: make(chan struct{}, 16),
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("密码错误")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == testUser && bytes.Equal(key.Marshal(), userSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("未授权的密钥")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.addr = ln.Addr().String()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+
+	go func() {
+		for req := range reqs {
+			if req.Type != "keepalive@openssh.com" {
+				req.Reply(false, nil)
+				continue
+			}
+			select {
+			case s.keepalives <- struct{}{}:
+			default:
+			}
+			s.mu.Lock()
+			ignore := s.ignoreKeepalive
+			s.mu.Unlock()
+			if !ignore {
+				req.Reply(true, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "不支持的通道类型")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(channel, requests)
+	}
+}
+
+func (s *testServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty ptyRequest
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			s.ptys <- pty
+			req.Reply(true, nil)
+		case "window-change":
+			var size windowChange
+			if err := ssh.Unmarshal(req.Payload, &size); err == nil {
+				s.resizes <- size
+			}
+		case "shell":
+			req.Reply(true, nil)
+			go func() {
+				s.echo(channel)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{3}))
+				channel.Close()
+			}()
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// echo 按行回显输入，直到收到 "exit"
+func (s *testServer) echo(channel ssh.Channel) {
+	io.WriteString(channel, "ready\n")
+	buf := make([]byte, 1024)
+	var line []byte
+	for {
+		n, err := channel.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			if b != '\n' {
+				line = append(line, b)
+				continue
+			}
+			if string(line) == "exit" {
+				return
+			}
+			io.WriteString(channel, "echo: "+string(line)+"\n")
+			line = line[:0]
+		}
+	}
+}
+
+func (s *testServer) host() Host {
+	h, port, _ := net.SplitHostPort(s.addr)
+	p, _ := strconv.Atoi(port)
+	return Host{Host: h, Port: p, User: testUser, AuthType: AuthPassword, Password: testPassword}
+}
+
+// trustedKnownHosts 记录了 key 作为服务器主机密钥的 known_hosts
+func trustedKnownHosts(t *testing.T, address string, key ssh.PublicKey) *KnownHosts {
+	t.Helper()
+	known, err := OpenKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != nil {
+		if err := known.Trust(address, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return known
+}
+
+// readUntil 读取输出直到包含 want
+func readUntil(t *testing.T, r io.Reader, want string) {
+	t.Helper()
+	done := make(chan string, 1)
+	go func() {
+		var out []byte
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			out = append(out, buf[:n]...)
+			if strings.Contains(string(out), want) || err != nil {
+				done <- string(out)
+				return
+			}
+		}
+	}()
+	select {
+	case out := <-done:
+		if !strings.Contains(out, want) {
+			t.Fatalf("输出 %q 中没有 %q", out, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待输出 %q 超时", want)
+	}
+}
+
+func TestDialPassword(t *testing.T) {
+	s := startTestServer(t)
+	host := s.host()
+	known := trustedKnownHosts(t, host.Address(), s.hostKey.PublicKey())
+
+	p, err := Dial(host, known, 120, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	select {
+	case pty := <-s.ptys:
+		if pty.Term != "xterm-256color" || pty.Cols != 120 || pty.Rows != 40 {
+			t.Errorf("pty-req = %+v, want xterm-256color 120x40", pty)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到 pty-req")
+	}
+	readUntil(t, p, "ready")
+
+	if err := p.Resize(100, 30); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case size := <-s.resizes:
+		if size.Cols != 100 || size.Rows != 30 {
+			t.Errorf("window-change = %+v, want 100x30", size)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到 window-change")
+	}
+
+	if _, err := io.WriteString(p, "hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	readUntil(t, p, "echo: hello")
+
+	io.WriteString(p, "exit\n")
+	code, err := p.Wait()
+	if err != nil || code != 3 {
+		t.Errorf("Wait() = %d, %v, want 3, nil", code, err)
+	}
+}
+
+func TestDialWrongPassword(t *testing.T) {
+	s := startTestServer(t)
+	host := s.host()
+	host.Password = "wrong"
+	known := trustedKnownHosts(t, host.Address(), s.hostKey.PublicKey())
+
+	if p, err := Dial(host, known, 80, 24); err == nil {
+		p.Close()
+		t.Fatal("错误的密码登录成功")
+	}
+}
+
+func TestDialKey(t *testing.T) {
+	s := startTestServer(t)
+	block, err := ssh.MarshalPrivateKey(s.userKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := s.host()
+	host.AuthType, host.Password, host.PrivateKey = AuthKey, "", string(pem.EncodeToMemory(block))
+	known := trustedKnownHosts(t, host.Address(), s.hostKey.PublicKey())
+
+	p, err := Dial(host, known, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	readUntil(t, p, "ready")
+
+	// 未授权的密钥被拒绝
+	_, otherKey := newSigner(t)
+	block, _ = ssh.MarshalPrivateKey(otherKey, "")
+	host.PrivateKey = string(pem.EncodeToMemory(block))
+	if p, err := Dial(host, known, 80, 24); err == nil {
+		p.Close()
+		t.Fatal("未授权的密钥登录成功")
+	}
+}
+
+func TestDialHostKey(t *testing.T) {
+	s := startTestServer(t)
+	host := s.host()
+
+	// known_hosts 中没有该主机
+	_, err := Dial(host, trustedKnownHosts(t, host.Address(), nil), 80, 24)
+	var keyErr *HostKeyError
+	if !errors.As(err, &keyErr) || keyErr.Changed {
+		t.Fatalf("未知主机: err = %v, want HostKeyError（Changed=false）", err)
+	}
+	if keyErr.Fingerprint != ssh.FingerprintSHA256(s.hostKey.PublicKey()) {
+		t.Errorf("fingerprint = %s, want %s", keyErr.Fingerprint, ssh.FingerprintSHA256(s.hostKey.PublicKey()))
+	}
+
+	// known_hosts 中记录的是另一个密钥
+	other, _ := newSigner(t)
+	_, err = Dial(host, trustedKnownHosts(t, host.Address(), other.PublicKey()), 80, 24)
+	if !errors.As(err, &keyErr) || !keyErr.Changed {
+		t.Fatalf("密钥不一致: err = %v, want HostKeyError（Changed=true）", err)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	interval := keepaliveInterval
+	keepaliveInterval = 50 * time.Millisecond
+	defer func() { keepaliveInterval = interval }()
+
+	s := startTestServer(t)
+	host := s.host()
+	known := trustedKnownHosts(t, host.Address(), s.hostKey.PublicKey())
+
+	p, err := Dial(host, known, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	readUntil(t, p, "ready")
+
+	// 服务器回复时连接保持
+	for i := 0; i < 3; i++ {
+		select {
+		case <-s.keepalives:
+		case <-time.After(5 * time.Second):
+			t.Fatal("没有收到 keepalive")
+		}
+	}
+	if _, err := io.WriteString(p, "still\n"); err != nil {
+		t.Fatal(err)
+	}
+	readUntil(t, p, "echo: still")
+
+	// 连续多次没有回复时断开连接
+	s.mu.Lock()
+	s.ignoreKeepalive = true
+	s.mu.Unlock()
+	exited := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("keepalive 没有回复时连接未断开")
+	}
+}
@@ -0,0 +1,302 @@
+// Package sshterm 通过 SSH 连接远程主机的网页终端：保存的主机配置、known_hosts 校验和远程伪终端
+package sshterm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"homedash/internal/storage"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// 认证方式
+const (
+	AuthPassword = "password"
+	AuthKey      = "key"
+)
+
+// ErrHostNotFound 主机配置不存在或不属于当前用户
+var ErrHostNotFound = errors.New("SSH 主机不存在")
+
+// Host 保存的 SSH 主机配置，只属于创建它的用户
+type Host struct {
+	ID         string `json:"id"`
+	OwnerID    string `json:"ownerId"`
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	User       string `json:"user"`
+	AuthType   string `json:"authType"` // password | key
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"` // PEM / OpenSSH 格式
+	Passphrase string `json:"passphrase,omitempty"` // 私钥密码
+	CreatedAt  int64  `json:"createdAt"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// HostView 对外展示的主机配置（不含密码和私钥）
+type HostView struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	User        string `json:"user"`
+	AuthType    string `json:"authType"`
+	HasPassword bool   `json:"hasPassword"`
+	HasKey      bool   `json:"hasKey"`
+	CreatedAt   int64  `json:"createdAt"`
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
+// View 转换为对外展示的主机配置
+func (h Host) View() HostView {
+	return HostView{
+		ID:          h.ID,
+		Name:        h.Name,
+		Host:        h.Host,
+		Port:        h.Port,
+		User:        h.User,
+		AuthType:    h.AuthType,
+		HasPassword: h.Password != "",
+		HasKey:      h.PrivateKey != "",
+		CreatedAt:   h.CreatedAt,
+		UpdatedAt:   h.UpdatedAt,
+	}
+}
+
+// Address host:port
+func (h Host) Address() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+// Label 会话和审计日志中显示的目标
+func (h Host) Label() string {
+	return h.User + "@" + h.Address()
+}
+
+// validate 检查并规范化配置
+func (h *Host) validate() error {
+	h.Name = strings.TrimSpace(h.Name)
+	h.Host = strings.TrimSpace(h.Host)
+	h.User = strings.TrimSpace(h.User)
+	if h.Host == "" {
+		return errors.New("请填写主机地址")
+	}
+	if h.User == "" {
+		return errors.New("请填写用户名")
+	}
+	if h.Port == 0 {
+		h.Port = 22
+	}
+	if h.Port < 1 || h.Port > 65535 {
+		return errors.New("端口无效")
+	}
+	if h.Name == "" {
+		h.Name = h.Label()
+	}
+	switch h.AuthType {
+	case AuthPassword:
+		if h.Password == "" {
+			return errors.New("请填写密码")
+		}
+		h.PrivateKey, h.Passphrase = "", ""
+	case AuthKey:
+		if h.PrivateKey == "" {
+			return errors.New("请填写私钥")
+		}
+		if _, err := h.signer(); err != nil {
+			return err
+		}
+		h.Password = ""
+	default:
+		return errors.New("无效的认证方式")
+	}
+	return nil
+}
+
+// signer 解析私钥
+func (h Host) signer() (ssh.Signer, error) {
+	var signer ssh.Signer
+	var err error
+	if h.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(h.PrivateKey), []byte(h.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(h.PrivateKey))
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, errors.New("私钥已加密，请填写私钥密码")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法解析私钥: %v", err)
+	}
+	return signer, nil
+}
+
+// Hosts 管理保存的主机配置，内存中是明文，保存到存储时加密密码、私钥和私钥密码
+type Hosts struct {
+	mu      sync.Mutex
+	backend storage.Backend
+	box     *secretBox
+	hosts   []Host
+}
+
+// NewHosts 从存储加载主机配置，keyPath 是加密凭据的密钥文件（不存在时生成）
+func NewHosts(backend storage.Backend, keyPath string) (*Hosts, error) {
+	box, err := openSecretBox(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开 SSH 凭据密钥失败: %w", err)
+	}
+	h := &Hosts{backend: backend, box: box}
+	data, err := backend.Load(storage.KeySSHHosts)
+	if errors.Is(err, storage.ErrNotFound) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []Host
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	hosts := make([]Host, 0, len(stored))
+	migrate := false
+	for _, host := range stored {
+		host, plain, err := box.openHost(host)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+		migrate = migrate || plain
+	}
+	h.hosts = hosts
+	// 旧版本明文保存的凭据立即加密
+	if migrate {
+		if err := h.saveLocked(hosts); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *Hosts) saveLocked(hosts []Host) error {
+	sealed := make([]Host, 0, len(hosts))
+	for _, host := range hosts {
+		host, err := h.box.sealHost(host)
+		if err != nil {
+			return err
+		}
+		sealed = append(sealed, host)
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := h.backend.Save(storage.KeySSHHosts, data); err != nil {
+		return err
+	}
+	h.hosts = hosts
+	return nil
+}
+
+// List 用户保存的主机
+func (h *Hosts) List(ownerID string) []Host {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hosts := []Host{}
+	for _, host := range h.hosts {
+		if host.OwnerID == ownerID {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Get 查找用户的主机配置
+func (h *Hosts) Get(ownerID, id string) (Host, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, host := range h.hosts {
+		if host.ID == id && host.OwnerID == ownerID {
+			return host, nil
+		}
+	}
+	return Host{}, ErrHostNotFound
+}
+
+// Create 保存新主机
+func (h *Hosts) Create(ownerID string, host Host) (Host, error) {
+	host.ID = uuid.New().String()[:8]
+	host.OwnerID = ownerID
+	if err := host.validate(); err != nil {
+		return Host{}, err
+	}
+	now := time.Now().UnixMilli()
+	host.CreatedAt, host.UpdatedAt = now, now
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hosts := append(append([]Host{}, h.hosts...), host)
+	if err := h.saveLocked(hosts); err != nil {
+		return Host{}, err
+	}
+	return host, nil
+}
+
+// Update 修改主机配置，密码和私钥留空时保持不变
+func (h *Hosts) Update(ownerID, id string, update Host) (Host, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hosts := append([]Host{}, h.hosts...)
+	for i, host := range hosts {
+		if host.ID != id || host.OwnerID != ownerID {
+			continue
+		}
+		update.ID, update.OwnerID, update.CreatedAt = host.ID, host.OwnerID, host.CreatedAt
+		if update.AuthType == host.AuthType {
+			if update.Password == "" {
+				update.Password = host.Password
+			}
+			if update.PrivateKey == "" {
+				update.PrivateKey, update.Passphrase = host.PrivateKey, host.Passphrase
+			}
+		}
+		if err := update.validate(); err != nil {
+			return Host{}, err
+		}
+		update.UpdatedAt = time.Now().UnixMilli()
+		hosts[i] = update
+		if err := h.saveLocked(hosts); err != nil {
+			return Host{}, err
+		}
+		return update, nil
+	}
+	return Host{}, ErrHostNotFound
+}
+
+// Delete 删除主机配置
+func (h *Hosts) Delete(ownerID, id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hosts := make([]Host, 0, len(h.hosts))
+	found := false
+	for _, host := range h.hosts {
+		if host.ID == id && host.OwnerID == ownerID {
+			found = true
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	if !found {
+		return ErrHostNotFound
+	}
+	return h.saveLocked(hosts)
+}
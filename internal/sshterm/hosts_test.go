@@ -0,0 +1,100 @@
+package sshterm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"homedash/internal/storage"
+)
+
+func TestHostSecretsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	backend := storage.NewJSONBackend(dir, nil)
+	keyPath := filepath.Join(dir, "ssh", "hosts.key")
+
+	hosts, err := NewHosts(backend, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := hosts.Create("u1", Host{Host: "nas.local", User: "root", AuthType: AuthPassword, Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := backend.Load(storage.KeySSHHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testPassword) {
+		t.Fatalf("ssh-hosts 中保存了明文密码: %s", data)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("hosts.key 权限 = %o, want 600", info.Mode().Perm())
+		}
+	}
+
+	// 重新加载后解密
+	reloaded, err := NewHosts(backend, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := reloaded.Get("u1", created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.Password != testPassword {
+		t.Errorf("Password = %q, want %q", host.Password, testPassword)
+	}
+
+	// 密文不能挪到其他主机上使用
+	var stored []Host
+	json.Unmarshal(data, &stored)
+	stored = append(stored, stored[0])
+	stored[1].ID = "other"
+	data, _ = json.Marshal(stored)
+	if err := backend.Save(storage.KeySSHHosts, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHosts(backend, keyPath); err == nil {
+		t.Error("复制到其他主机的密文解密成功")
+	}
+
+	// 换了密钥文件无法解密
+	if _, err := NewHosts(backend, filepath.Join(dir, "other.key")); err == nil {
+		t.Error("使用其他密钥解密成功")
+	}
+}
+
+// TestHostSecretsMigrate 旧版本明文保存的凭据在加载时加密
+func TestHostSecretsMigrate(t *testing.T) {
+	dir := t.TempDir()
+	backend := storage.NewJSONBackend(dir, nil)
+	legacy, _ := json.Marshal([]Host{{ID: "h1", OwnerID: "u1", Host: "nas.local", Port: 22, User: "root", AuthType: AuthPassword, Password: testPassword}})
+	if err := backend.Save(storage.KeySSHHosts, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := NewHosts(backend, filepath.Join(dir, "hosts.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, err := hosts.Get("u1", "h1"); err != nil || host.Password != testPassword {
+		t.Fatalf("Get() = %+v, %v", host, err)
+	}
+	data, err := backend.Load(storage.KeySSHHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testPassword) {
+		t.Fatalf("加载后仍是明文: %s", data)
+	}
+}
@@ -0,0 +1,131 @@
+package sshterm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyError 主机密钥未知或与记录不一致，需要用户核对指纹后再信任
+type HostKeyError struct {
+	Host        string
+	Fingerprint string // SHA256 指纹
+	Changed     bool   // known_hosts 中已有该主机的其他密钥
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Changed {
+		return fmt.Sprintf("警告：主机 %s 的密钥与 known_hosts 中的记录不一致（%s），可能存在中间人攻击", e.Host, e.Fingerprint)
+	}
+	return fmt.Sprintf("主机 %s 的密钥未知（%s），请核对指纹后信任该主机", e.Host, e.Fingerprint)
+}
+
+// KnownHosts OpenSSH 格式的 known_hosts 文件，只有其中记录的主机密钥才能连接
+type KnownHosts struct {
+	mu   sync.Mutex
+	path string
+}
+
+// OpenKnownHosts 打开 known_hosts 文件，不存在时创建
+func OpenKnownHosts(path string) (*KnownHosts, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &KnownHosts{path: path}, nil
+}
+
+// Callback 校验主机密钥，每次都重新读取文件以便信任新主机后立即生效
+func (k *KnownHosts) Callback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		k.mu.Lock()
+		check, err := knownhosts.New(k.path)
+		k.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			return &HostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key), Changed: len(keyErr.Want) > 0}
+		}
+		return err
+	}
+}
+
+// Trust 信任主机密钥，替换该主机原有的记录
+func (k *KnownHosts) Trust(address string, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+
+	host := knownhosts.Normalize(address)
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) >= 2 && !strings.HasPrefix(fields[0], "#") && knownHostMatches(fields[0], host) {
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	out.WriteString(knownhosts.Line([]string{host}, key) + "\n")
+	return os.WriteFile(k.path, out.Bytes(), 0600)
+}
+
+// knownHostMatches 明文记录的主机列表中是否包含该主机（哈希记录无法判断，保留不动）
+func knownHostMatches(hosts, host string) bool {
+	for _, h := range strings.Split(hosts, ",") {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// errKeyScanned 获取到主机密钥后中止连接
+var errKeyScanned = errors.New("已获取主机密钥")
+
+// ScanHostKey 连接主机获取其密钥（不进行认证）
+func ScanHostKey(address string) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "homedash",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errKeyScanned
+		},
+		Timeout: dialTimeout,
+	}
+	client, err := ssh.Dial("tcp", address, config)
+	if err == nil {
+		client.Close()
+	}
+	if hostKey == nil {
+		if err == nil {
+			err = errors.New("未获取到主机密钥")
+		}
+		return nil, err
+	}
+	return hostKey, nil
+}
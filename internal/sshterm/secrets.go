@@ -0,0 +1,122 @@
+package sshterm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secretPrefix 加密保存的字段前缀，没有前缀的是旧版本保存的明文
+const secretPrefix = "enc:v1:"
+
+const secretKeySize = 32 // AES-256
+
+// secretBox 加密主机配置中的密码、私钥和私钥密码（AES-256-GCM）。
+// 密钥保存在 ssh-hosts 文档之外的独立文件中，只拿到文档（备份、数据库文件）无法还原凭据
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+// openSecretBox 读取密钥文件，不存在时生成（目录 0700，文件 0600）
+func openSecretBox(path string) (*secretBox, error) {
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key, err = createSecretKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("密钥文件 %s 无效", path)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretBox{aead: aead}, nil
+}
+
+func createSecretKey(path string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	key := make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+// seal 加密字段，hostID 作为附加数据，密文不能挪到其他主机上使用
+func (b *secretBox) seal(value, hostID string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(value), []byte(hostID))
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open 解密字段，返回值 plain 表示字段是旧版本保存的明文
+func (b *secretBox) open(value, hostID string) (result string, plain bool, err error) {
+	encoded, ok := strings.CutPrefix(value, secretPrefix)
+	if !ok {
+		return value, value != "", nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", false, errors.New("密文格式无效")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	data, err := b.aead.Open(nil, nonce, ciphertext, []byte(hostID))
+	if err != nil {
+		return "", false, errors.New("无法解密，密钥文件可能已更换")
+	}
+	return string(data), false, nil
+}
+
+// sealHost 返回凭据已加密的副本
+func (b *secretBox) sealHost(h Host) (Host, error) {
+	var err error
+	for _, field := range []*string{&h.Password, &h.PrivateKey, &h.Passphrase} {
+		if *field, err = b.seal(*field, h.ID); err != nil {
+			return Host{}, err
+		}
+	}
+	return h, nil
+}
+
+// openHost 解密凭据，plain 表示有字段仍为明文（需要重新保存）
+func (b *secretBox) openHost(h Host) (Host, bool, error) {
+	anyPlain := false
+	for _, field := range []*string{&h.Password, &h.PrivateKey, &h.Passphrase} {
+		value, plain, err := b.open(*field, h.ID)
+		if err != nil {
+			return Host{}, false, fmt.Errorf("主机 %s 的凭据%v", h.Label(), err)
+		}
+		*field = value
+		anyPlain = anyPlain || plain
+	}
+	return h, anyPlain, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersionLayout 版本号格式（同时作为历史版本文件名）
+const VersionLayout = "20060102-150405.000000000"
+
+// JSONBackend 基于 JSON 文件的后端
+// 文档保存为 <dataDir>/<key>.json（可通过 files 指定其他路径），历史版本保存在 <dataDir>/history/<key>/ 下
+type JSONBackend struct {
+	dataDir string
+	files   map[string]string
+}
+
+// NewJSONBackend 创建 JSON 文件后端
+func NewJSONBackend(dataDir string, files map[string]string) *JSONBackend {
+	paths := make(map[string]string, len(files))
+	for key, path := range files {
+		paths[key] = path
+	}
+	return &JSONBackend{dataDir: dataDir, files: paths}
+}
+
+// Kind 后端类型
+func (b *JSONBackend) Kind() string {
+	return KindJSON
+}
+
+// Path 文档对应的文件路径
+func (b *JSONBackend) Path(key string) string {
+	if path, ok := b.files[key]; ok {
+		return path
+	}
+	return filepath.Join(b.dataDir, key+".json")
+}
+
+// Load 读取文档
+func (b *JSONBackend) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(b.Path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Save 原子写入文档
+func (b *JSONBackend) Save(key string, data []byte) error {
+	path := b.Path(key)
+	if err := os.MkdirAll(filepath.Dir(path), DirPerm); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, FilePerm)
+}
+
+// historyDir 历史版本目录
+func (b *JSONBackend) historyDir(key string) string {
+	return filepath.Join(b.dataDir, "history", key)
+}
+
+// SaveVersion 保存历史版本并清理超出上限的旧版本
+func (b *JSONBackend) SaveVersion(key, id string, data []byte, limit int) error {
+	dir := b.historyDir(key)
+	if err := os.MkdirAll(dir, DirPerm); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, id+".json"), data, FilePerm); err != nil {
+		return err
+	}
+
+	versions, err := b.Versions(key)
+	if err != nil {
+		return err
+	}
+	for _, old := range versions[min(len(versions), limit):] {
+		os.Remove(filepath.Join(dir, old.ID+".json"))
+	}
+	return nil
+}
+
+// Versions 列出历史版本（最新的在前）
+func (b *JSONBackend) Versions(key string) ([]Version, error) {
+	entries, err := os.ReadDir(b.historyDir(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		savedAt, err := time.ParseInLocation(VersionLayout, id, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		versions = append(versions, Version{ID: id, Size: info.Size(), SavedAt: savedAt})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID > versions[j].ID })
+	return versions, nil
+}
+
+// LoadVersion 读取历史版本
+func (b *JSONBackend) LoadVersion(key, id string) ([]byte, error) {
+	if _, err := time.Parse(VersionLayout, id); err != nil {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(b.historyDir(key), id+".json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Close JSON 后端无需关闭
+func (b *JSONBackend) Close() error {
+	return nil
+}
+
+// WriteFileAtomic 先写临时文件再重命名，避免写入中途崩溃导致文件损坏
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // 重命名成功后为空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	// 同步目录项，确保重命名落盘（Windows 不支持打开目录同步）
+	if runtime.GOOS != "windows" {
+		if d, err := os.Open(dir); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite" // 纯 Go 实现，无需 cgo
+)
+
+// migrations 数据库结构迁移，按顺序执行，已执行的版本记录在 schema_migrations 中
+// 只能在末尾追加，不要修改已发布的条目
+var migrations = []string{
+	// 1: 文档与历史版本
+	`CREATE TABLE documents (
+		key        TEXT PRIMARY KEY,
+		data       BLOB NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE TABLE document_versions (
+		key      TEXT NOT NULL,
+		id       TEXT NOT NULL,
+		data     BLOB NOT NULL,
+		saved_at INTEGER NOT NULL,
+		PRIMARY KEY (key, id)
+	);
+	CREATE TABLE meta (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+	// 2: 审计日志
+	`CREATE TABLE audit_log (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		time     INTEGER NOT NULL,
+		actor    TEXT NOT NULL DEFAULT '',
+		actor_id TEXT NOT NULL DEFAULT '',
+		via      TEXT NOT NULL DEFAULT '',
+		ip       TEXT NOT NULL DEFAULT '',
+		action   TEXT NOT NULL,
+		target   TEXT NOT NULL DEFAULT '',
+		outcome  TEXT NOT NULL,
+		status   INTEGER NOT NULL DEFAULT 0,
+		detail   TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX audit_log_time ON audit_log (time);`,
+	// 3: 任务执行记录（seq 保持触发顺序）
+	`CREATE TABLE job_runs (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		id          TEXT NOT NULL UNIQUE,
+		job_id      TEXT NOT NULL,
+		job_name    TEXT NOT NULL DEFAULT '',
+		trigger_by  TEXT NOT NULL DEFAULT '',
+		status      TEXT NOT NULL,
+		exit_code   INTEGER NOT NULL DEFAULT 0,
+		output      TEXT NOT NULL DEFAULT '',
+		truncated   INTEGER NOT NULL DEFAULT 0,
+		error       TEXT NOT NULL DEFAULT '',
+		started_at  INTEGER NOT NULL,
+		finished_at INTEGER NOT NULL DEFAULT 0,
+		duration    INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX job_runs_job ON job_runs (job_id, seq);`,
+	// 4: 用户、登录会话与 API 令牌（列表类字段保存为 JSON 数组）
+	`CREATE TABLE users (
+		id             TEXT PRIMARY KEY,
+		username       TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash  TEXT NOT NULL,
+		role           TEXT NOT NULL,
+		permissions    TEXT NOT NULL DEFAULT '[]',
+		totp_enabled   INTEGER NOT NULL DEFAULT 0,
+		totp_secret    TEXT NOT NULL DEFAULT '',
+		totp_pending   TEXT NOT NULL DEFAULT '',
+		totp_last_step INTEGER NOT NULL DEFAULT 0,
+		recovery_codes TEXT NOT NULL DEFAULT '[]',
+		created_at     INTEGER NOT NULL,
+		updated_at     INTEGER NOT NULL
+	);
+	CREATE TABLE sessions (
+		id         TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		csrf_token TEXT NOT NULL,
+		user_id    TEXT NOT NULL,
+		ip         TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		last_seen  INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+	CREATE INDEX sessions_user ON sessions (user_id);
+	CREATE TABLE api_tokens (
+		id           TEXT PRIMARY KEY,
+		user_id      TEXT NOT NULL,
+		name         TEXT NOT NULL,
+		hint         TEXT NOT NULL DEFAULT '',
+		token_hash   TEXT NOT NULL UNIQUE,
+		scopes       TEXT NOT NULL DEFAULT '[]',
+		created_at   INTEGER NOT NULL,
+		expires_at   INTEGER NOT NULL DEFAULT 0,
+		last_used_at INTEGER NOT NULL DEFAULT 0,
+		last_used_ip TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX api_tokens_user ON api_tokens (user_id);`,
+}
+
+// SQLiteBackend 基于内嵌 SQLite 的后端
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// OpenSQLite 打开数据库并执行未完成的迁移，数据库文件（含 WAL 文件）权限为 FilePerm
+func OpenSQLite(path string) (*SQLiteBackend, error) {
+	// 预先创建文件：SQLite 创建的 -wal、-shm 文件沿用数据库文件的权限
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, FilePerm)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := restrictFiles(path); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者，单连接可避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	b := &SQLiteBackend{db: db}
+	if err := b.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("数据库迁移失败: %v", err)
+	}
+	if err := restrictFiles(path); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// restrictFiles 将数据库及其 WAL 文件的权限收紧为 FilePerm（旧版本以 0644 创建）
+func restrictFiles(path string) error {
+	for _, name := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Chmod(name, FilePerm); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate 执行未完成的迁移，每个迁移在独立事务中完成
+func (b *SQLiteBackend) migrate() error {
+	if _, err := b.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := b.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d，请升级 HomeDash", current, len(migrations))
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := b.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("版本 %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, i+1, time.Now().UnixMilli()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Kind 后端类型
+func (b *SQLiteBackend) Kind() string {
+	return KindSQLite
+}
+
+// DB 底层数据库连接，供需要独立数据表的模块使用
+func (b *SQLiteBackend) DB() *sql.DB {
+	return b.db
+}
+
+// Load 读取文档
+func (b *SQLiteBackend) Load(key string) ([]byte, error) {
+	var data []byte
+	err := b.db.QueryRow(`SELECT data FROM documents WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Save 写入文档
+func (b *SQLiteBackend) Save(key string, data []byte) error {
+	_, err := b.db.Exec(`INSERT INTO documents (key, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		key, data, time.Now().UnixMilli())
+	return err
+}
+
+// SaveVersion 保存历史版本并清理超出上限的旧版本
+func (b *SQLiteBackend) SaveVersion(key, id string, data []byte, limit int) error {
+	savedAt, err := time.ParseInLocation(VersionLayout, id, time.Local)
+	if err != nil {
+		savedAt = time.Now()
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO document_versions (key, id, data, saved_at) VALUES (?, ?, ?, ?)`,
+		key, id, data, savedAt.UnixMilli()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM document_versions WHERE key = ? AND id NOT IN (
+		SELECT id FROM document_versions WHERE key = ? ORDER BY id DESC LIMIT ?)`, key, key, limit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Versions 列出历史版本（最新的在前）
+func (b *SQLiteBackend) Versions(key string) ([]Version, error) {
+	rows, err := b.db.Query(`SELECT id, LENGTH(data), saved_at FROM document_versions WHERE key = ? ORDER BY id DESC`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		var v Version
+		var savedAt int64
+		if err := rows.Scan(&v.ID, &v.Size, &savedAt); err != nil {
+			return nil, err
+		}
+		v.SavedAt = time.UnixMilli(savedAt)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// LoadVersion 读取历史版本
+func (b *SQLiteBackend) LoadVersion(key, id string) ([]byte, error) {
+	var data []byte
+	err := b.db.QueryRow(`SELECT data FROM document_versions WHERE key = ? AND id = ?`, key, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Close 关闭数据库
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+// importOnce 首次使用时从 JSON 后端导入全部文档和历史版本，返回导入的文档数
+func (b *SQLiteBackend) importOnce(src Backend) (int, error) {
+	var done string
+	err := b.db.QueryRow(`SELECT value FROM meta WHERE key = 'json_imported_at'`).Scan(&done)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	imported := 0
+	for _, key := range documentKeys {
+		data, err := src.Load(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("读取 %s 失败: %v", key, err)
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO documents (key, data, updated_at) VALUES (?, ?, ?)`, key, data, now); err != nil {
+			return 0, err
+		}
+		imported++
+
+		versions, err := src.Versions(key)
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range versions {
+			vdata, err := src.LoadVersion(key, v.ID)
+			if err != nil {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT OR REPLACE INTO document_versions (key, id, data, saved_at) VALUES (?, ?, ?, ?)`,
+				key, v.ID, vdata, v.SavedAt.UnixMilli()); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('json_imported_at', ?)`, time.Now().Format(time.RFC3339)); err != nil {
+		return 0, err
+	}
+	return imported, tx.Commit()
+}
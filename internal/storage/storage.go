@@ -0,0 +1,117 @@
+// Package storage 提供配置与运行数据的持久化后端：JSON 文件（默认）或内嵌 SQLite
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 文档键
+const (
+	KeyServices = "services"
+	KeySettings = "settings"
+	KeyJobs     = "jobs"
+	KeyJobRuns  = "job-runs"
+	KeyUsers    = "users"
+	KeySessions = "sessions"
+	KeyAuth     = "auth"
+	KeyTokens   = "tokens"
+	KeySSHHosts = "ssh-hosts"
+	KeyTerminal = "terminal"
+	KeySFTPKeys = "sftp-keys"
+	KeyWebDAV   = "webdav"
+	// KeyScheduleRuns 服务定时任务（启动、停止、重启）的执行记录
+	KeyScheduleRuns = "schedule-runs"
+)
+
+// 数据目录和文档的权限：文档中保存了密码哈希、两步验证密钥、会话、令牌和 SSH 凭据，
+// 只允许运行 HomeDash 的账号读取
+const (
+	DirPerm  os.FileMode = 0700
+	FilePerm os.FileMode = 0600
+)
+
+// PrepareDataDir 创建数据目录，已存在时同样收紧为 DirPerm（旧版本以 0755 创建）
+func PrepareDataDir(dir string) error {
+	if err := os.MkdirAll(dir, DirPerm); err != nil {
+		return err
+	}
+	return os.Chmod(dir, DirPerm)
+}
+
+// documentKeys 全部文档键，切换后端时按此列表迁移
+var documentKeys = []string{KeyServices, KeySettings, KeyJobs, KeyJobRuns, KeyUsers, KeySessions, KeyAuth, KeyTokens, KeySSHHosts, KeyTerminal, KeySFTPKeys, KeyWebDAV, KeyScheduleRuns}
+
+// 后端类型
+const (
+	KindJSON   = "json"
+	KindSQLite = "sqlite"
+)
+
+// ErrNotFound 文档或版本不存在
+var ErrNotFound = errors.New("数据不存在")
+
+// Version 文档历史版本
+type Version struct {
+	ID      string    // 版本号，按字典序即时间顺序
+	Size    int64     // 字节数
+	SavedAt time.Time // 保存时间
+}
+
+// Backend 持久化后端
+// 数据以「键 - JSON 文档」的形式整体读写，文档可保留若干历史版本
+type Backend interface {
+	Kind() string
+
+	// Load 读取文档，不存在时返回 ErrNotFound
+	Load(key string) ([]byte, error)
+	// Save 原子写入文档
+	Save(key string, data []byte) error
+
+	// SaveVersion 保存一个历史版本，并只保留最近 limit 个
+	SaveVersion(key, id string, data []byte, limit int) error
+	// Versions 列出历史版本（最新的在前）
+	Versions(key string) ([]Version, error)
+	// LoadVersion 读取历史版本，不存在时返回 ErrNotFound
+	LoadVersion(key, id string) ([]byte, error)
+
+	Close() error
+}
+
+// FileBackend 以独立文件保存文档的后端，可用于监听外部修改
+type FileBackend interface {
+	Backend
+	Path(key string) string
+}
+
+// Open 按类型打开后端
+// files 指定部分文档的文件路径（如 web 目录下的 services.json），其余文档保存在 dataDir 下；
+// 选择 SQLite 时数据库位于 dataDir/homedash.db，首次打开会自动导入现有 JSON 数据
+func Open(kind, dataDir string, files map[string]string) (Backend, error) {
+	jsonBackend := NewJSONBackend(dataDir, files)
+
+	switch kind {
+	case "", KindJSON:
+		return jsonBackend, nil
+	case KindSQLite:
+		db, err := OpenSQLite(filepath.Join(dataDir, "homedash.db"))
+		if err != nil {
+			return nil, err
+		}
+		imported, err := db.importOnce(jsonBackend)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("从 JSON 文件迁移失败: %v", err)
+		}
+		if imported > 0 {
+			log.Printf("已从 JSON 文件导入 %d 个文档到 SQLite（原文件保留，不再使用）", imported)
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("未知的存储后端 %q，可选 json 或 sqlite", kind)
+	}
+}
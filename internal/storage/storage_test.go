@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// checkMode 检查文件权限（Windows 不支持 Unix 权限位）
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s 权限 = %o, want %o", filepath.Base(path), got, want)
+	}
+}
+
+func TestPrivatePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不使用 Unix 权限位")
+	}
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := PrepareDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, dir, DirPerm)
+
+	b := NewJSONBackend(dir, nil)
+	if err := b.Save(KeyUsers, []byte(`[]`)); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, b.Path(KeyUsers), FilePerm)
+	if err := b.SaveVersion(KeySettings, "20260101-000000.000000000", []byte(`{}`), 20); err != nil {
+		t.Fatal(err)
+	}
+	checkMode(t, b.historyDir(KeySettings), DirPerm)
+	checkMode(t, filepath.Join(b.historyDir(KeySettings), "20260101-000000.000000000.json"), FilePerm)
+
+	// 旧版本以 0644 创建的数据库在打开时收紧
+	dbPath := filepath.Join(dir, "homedash.db")
+	if err := os.WriteFile(dbPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenSQLite(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Save(KeyUsers, []byte(`[]`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{dbPath, dbPath + "-wal", dbPath + "-shm"} {
+		if _, err := os.Stat(name); err == nil {
+			checkMode(t, name, FilePerm)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package terminal
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ShellFamily 执行命令的 shell 语法，决定命令的拆分方式和哪些写法可以嵌套执行其他命令
+type ShellFamily int
+
+const (
+	ShellPOSIX      ShellFamily = iota // sh、bash、zsh 等
+	ShellPowerShell                    // powershell、pwsh
+	ShellCmd                           // cmd.exe
+)
+
+// ShellFamilyOf 按 shell 的程序名判断语法，无法识别的按 POSIX 处理
+func ShellFamilyOf(shell string) ShellFamily {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(shell), filepath.Ext(shell)))
+	switch name {
+	case "powershell", "pwsh":
+		return ShellPowerShell
+	case "cmd":
+		return ShellCmd
+	default:
+		return ShellPOSIX
+	}
+}
+
+// Policy 命令策略，模式为正则表达式（不自动锚定，需要时使用 ^ 和 $）。
+// 命令按 ;、&&、||、|、& 和换行拆分后逐段检查（引号内的分隔符同样拆分，偏保守）：
+// 任一段匹配 Deny 即拒绝；Allow 非空时每一段都必须匹配其中之一，且不允许命令替换
+// （POSIX shell 的 `、$(、<(、>(，PowerShell 的 `、(、{，cmd 的 (）
+type Policy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// PolicyError 命令被策略拒绝
+type PolicyError struct {
+	Command string // 被拒绝的命令片段
+	Pattern string // 匹配的拒绝规则，未命中允许列表时为空
+}
+
+func (e *PolicyError) Error() string {
+	if e.Pattern != "" {
+		return fmt.Sprintf("命令 %q 被策略禁止（匹配 %s）", e.Command, e.Pattern)
+	}
+	return fmt.Sprintf("命令 %q 不在允许列表中", e.Command)
+}
+
+// Empty 策略是否为空（不限制任何命令）
+func (p Policy) Empty() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// Validate 检查全部模式是否为有效的正则表达式
+func (p Policy) Validate() error {
+	for _, patterns := range [][]string{p.Allow, p.Deny} {
+		for _, pattern := range patterns {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("命令策略中存在空规则")
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("无效的命令规则 %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Check 检查命令是否允许执行，family 为执行命令的 shell 语法
+func (p Policy) Check(command string, family ShellFamily) error {
+	if p.Empty() {
+		return nil
+	}
+	deny, err := compilePatterns(p.Deny)
+	if err != nil {
+		return err
+	}
+	allow, err := compilePatterns(p.Allow)
+	if err != nil {
+		return err
+	}
+
+	command = strings.TrimSpace(command)
+	if len(allow) > 0 && hasSubstitution(command, family) {
+		return &PolicyError{Command: command}
+	}
+	for _, segment := range splitCommand(command, family) {
+		for _, re := range deny {
+			if re.MatchString(segment) {
+				return &PolicyError{Command: segment, Pattern: re.String()}
+			}
+		}
+		if len(allow) == 0 {
+			continue
+		}
+		allowed := false
+		for _, re := range allow {
+			if re.MatchString(segment) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &PolicyError{Command: segment}
+		}
+	}
+	return nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的命令规则 %q: %v", pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// splitCommand 按 shell 分隔符拆分为多段命令，忽略空段。
+// PowerShell 和 cmd 还把 \r 当作换行
+func splitCommand(command string, family ShellFamily) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(command); i++ {
+		switch command[i] {
+		case ';', '|', '\n':
+		case '\r':
+			if family == ShellPOSIX {
+				continue
+			}
+		case '&':
+			// 重定向 2>&1、&> 中的 & 不是分隔符
+			if i > 0 && (command[i-1] == '>' || command[i-1] == '<') || i+1 < len(command) && command[i+1] == '>' {
+				continue
+			}
+		default:
+			continue
+		}
+		segments = append(segments, command[start:i])
+		start = i + 1
+	}
+	segments = append(segments, command[start:])
+
+	res := segments[:0]
+	for _, s := range segments {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// substitutions 各 shell 中可以嵌套执行其他命令的写法，其中的命令无法逐段检查：
+// PowerShell 的 (...)、$(...)、@(...) 和脚本块 {...}，cmd 的 for /f ... in ('...')
+var substitutions = map[ShellFamily][]string{
+	ShellPOSIX:      {"`", "$(", "<(", ">("},
+	ShellPowerShell: {"`", "(", "{"},
+	ShellCmd:        {"("},
+}
+
+// hasSubstitution 命令中是否含有命令替换或进程替换
+func hasSubstitution(command string, family ShellFamily) bool {
+	for _, s := range substitutions[family] {
+		if strings.Contains(command, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package terminal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellFamilyOf(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  ShellFamily
+	}{
+		{"/bin/bash", ShellPOSIX},
+		{"zsh", ShellPOSIX},
+		{"", ShellPOSIX},
+		{"powershell.exe", ShellPowerShell},
+		{"PowerShell.EXE", ShellPowerShell},
+		{"/usr/bin/pwsh", ShellPowerShell},
+		{"cmd.exe", ShellCmd},
+	}
+	for _, tt := range tests {
+		if got := ShellFamilyOf(tt.shell); got != tt.want {
+			t.Errorf("ShellFamilyOf(%q) = %d, want %d", tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		family  ShellFamily
+		want    []string
+	}{
+		{"单条命令", "ls -la", ShellPOSIX, []string{"ls -la"}},
+		{"分号", "ls; rm -rf /", ShellPOSIX, []string{"ls", "rm -rf /"}},
+		{"与或", "make && make install || echo fail", ShellPOSIX, []string{"make", "make install", "echo fail"}},
+		{"管道", "ps aux | grep nginx", ShellPOSIX, []string{"ps aux", "grep nginx"}},
+		{"后台", "sleep 10 & rm x", ShellPOSIX, []string{"sleep 10", "rm x"}},
+		{"换行", "ls\nrm x", ShellPOSIX, []string{"ls", "rm x"}},
+		{"重定向不是分隔符", "make 2>&1 &> log", ShellPOSIX, []string{"make 2>&1 &> log"}},
+		{"引号内同样拆分", `echo "a;b"`, ShellPOSIX, []string{`echo "a`, `b"`}},
+		{"空段", " ; ls ;; ", ShellPOSIX, []string{"ls"}},
+		{"POSIX 中回车不是分隔符", "ls\rrm x", ShellPOSIX, []string{"ls\rrm x"}},
+		{"PowerShell 回车", "Get-Process\rRemove-Item x", ShellPowerShell, []string{"Get-Process", "Remove-Item x"}},
+		{"PowerShell 回车换行", "Get-Process\r\nRemove-Item x", ShellPowerShell, []string{"Get-Process", "Remove-Item x"}},
+		{"PowerShell 管道和分号", "Get-Process | Stop-Process; ls", ShellPowerShell, []string{"Get-Process", "Stop-Process", "ls"}},
+		{"PowerShell 调用运算符", "& Remove-Item x", ShellPowerShell, []string{"Remove-Item x"}},
+		{"cmd 回车", "dir\rdel x", ShellCmd, []string{"dir", "del x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitCommand(tt.command, tt.family); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	allow := Policy{Allow: []string{`^(ls|cat|echo|Get-\w+)\b`}}
+	deny := Policy{Deny: []string{`\brm\b`, `(?i)\bRemove-Item\b`}}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		command string
+		family  ShellFamily
+		wantErr bool
+	}{
+		{"空策略", Policy{}, "rm -rf /", ShellPOSIX, false},
+
+		{"拒绝规则", deny, "rm -rf /tmp/x", ShellPOSIX, true},
+		{"拒绝规则未命中", deny, "ls /tmp", ShellPOSIX, false},
+		{"拒绝规则检查每一段", deny, "ls && rm x", ShellPOSIX, true},
+		{"拒绝规则检查管道后的命令", deny, "ls | xargs rm", ShellPOSIX, true},
+		{"PowerShell 回车后的命令", deny, "Get-ChildItem\rRemove-Item x", ShellPowerShell, true},
+
+		{"允许列表", allow, "ls -la", ShellPOSIX, false},
+		{"允许列表每一段都须匹配", allow, "ls; whoami", ShellPOSIX, true},
+		{"允许列表中重定向", allow, "cat log 2>&1", ShellPOSIX, false},
+		{"允许列表不允许 $()", allow, "echo $(whoami)", ShellPOSIX, true},
+		{"允许列表不允许反引号", allow, "echo `whoami`", ShellPOSIX, true},
+		{"允许列表不允许进程替换", allow, "cat <(whoami)", ShellPOSIX, true},
+		{"POSIX 括号不是替换", allow, "echo (a)", ShellPOSIX, false},
+		{"POSIX 中回车不拆分", allow, "ls\rwhoami", ShellPOSIX, false},
+
+		{"PowerShell 允许", allow, "Get-Process -Name nginx", ShellPowerShell, false},
+		{"PowerShell 回车拆分", allow, "Get-Process\rStop-Computer", ShellPowerShell, true},
+		{"PowerShell 分组表达式", allow, "Get-Item (Remove-Item x)", ShellPowerShell, true},
+		{"PowerShell 子表达式", allow, "echo $(Stop-Computer)", ShellPowerShell, true},
+		{"PowerShell 数组子表达式", allow, "echo @(Stop-Computer)", ShellPowerShell, true},
+		{"PowerShell 脚本块", allow, "Get-Process | Get-Item -Path {Stop-Computer}", ShellPowerShell, true},
+		{"PowerShell 调用运算符", allow, "& Stop-Computer", ShellPowerShell, true},
+
+		{"cmd for /f", allow, "echo a & for /f %i in ('whoami') do echo %i", ShellCmd, true},
+		{"cmd 允许", allow, "echo hello", ShellCmd, false},
+
+		{"无效规则", Policy{Deny: []string{"("}}, "ls", ShellPOSIX, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.command, tt.family)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%q) = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
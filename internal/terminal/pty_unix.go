@@ -0,0 +1,118 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// unixPTY creack/pty 打开的伪终端，shell 运行在独立会话中
+type unixPTY struct {
+	file      *os.File
+	cmd       *exec.Cmd
+	closeOnce sync.Once
+	exited    chan struct{}
+	exitCode  int
+	waitErr   error
+}
+
+func start(opts Options) (PTY, error) {
+	cmd := exec.Command(opts.Shell, opts.Args...)
+	cmd.Dir = opts.Dir
+	cmd.Env = append(append(os.Environ(), "TERM=xterm-256color"), opts.Env...)
+	file, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: opts.Cols, Rows: opts.Rows})
+	if err != nil {
+		return nil, err
+	}
+	p := &unixPTY{file: file, cmd: cmd, exited: make(chan struct{})}
+	go p.wait()
+	return p, nil
+}
+
+func (p *unixPTY) wait() {
+	defer close(p.exited)
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		p.exitCode = exitErr.ExitCode()
+	case err != nil:
+		p.exitCode, p.waitErr = -1, err
+	}
+}
+
+func (p *unixPTY) Read(b []byte) (int, error) {
+	n, err := p.file.Read(b)
+	// shell 退出后读取主设备返回 EIO，视为正常结束
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (p *unixPTY) Write(b []byte) (int, error) {
+	return p.file.Write(b)
+}
+
+func (p *unixPTY) Resize(cols, rows uint16) error {
+	return pty.Setsize(p.file, &pty.Winsize{Cols: cols, Rows: rows})
+}
+
+// Signal 发送给终端的前台进程组（如正在运行的 top），没有前台程序时发给 shell
+func (p *unixPTY) Signal(sig Signal) error {
+	var s syscall.Signal
+	switch sig {
+	case SignalInterrupt:
+		s = syscall.SIGINT
+	case SignalTerminate:
+		s = syscall.SIGTERM
+	case SignalKill:
+		s = syscall.SIGKILL
+	default:
+		return ErrUnknownSignal
+	}
+
+	pgrp := 0
+	if conn, err := p.file.SyscallConn(); err == nil {
+		conn.Control(func(fd uintptr) {
+			pgrp, _ = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+		})
+	}
+	if pgrp > 0 {
+		return syscall.Kill(-pgrp, s)
+	}
+	return p.cmd.Process.Signal(s)
+}
+
+func (p *unixPTY) Wait() (int, error) {
+	<-p.exited
+	return p.exitCode, p.waitErr
+}
+
+// Close 关闭终端并结束 shell 所在会话的全部进程
+func (p *unixPTY) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		// shell 是会话首进程，进程组号即其 PID；先发 SIGHUP 让程序正常退出，超时后强制结束
+		pgid := -p.cmd.Process.Pid
+		syscall.Kill(pgid, syscall.SIGHUP)
+		err = p.file.Close()
+		go func() {
+			select {
+			case <-p.exited:
+			case <-time.After(closeGracePeriod):
+				syscall.Kill(pgid, syscall.SIGKILL)
+			}
+		}()
+	})
+	return err
+}
@@ -0,0 +1,197 @@
+//go:build windows
+
+package terminal
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// conPTY Windows 10 1809 起提供的伪控制台
+type conPTY struct {
+	console   windows.Handle
+	input     *os.File // 写入键盘输入
+	output    *os.File // 读取程序输出
+	process   windows.Handle
+	pid       uint32
+	closeOnce sync.Once
+	exited    chan struct{}
+	exitCode  int
+	waitErr   error
+}
+
+func start(opts Options) (PTY, error) {
+	var inRead, inWrite, outRead, outWrite windows.Handle
+	if err := windows.CreatePipe(&inRead, &inWrite, nil, 0); err != nil {
+		return nil, err
+	}
+	if err := windows.CreatePipe(&outRead, &outWrite, nil, 0); err != nil {
+		windows.CloseHandle(inRead)
+		windows.CloseHandle(inWrite)
+		return nil, err
+	}
+
+	var console windows.Handle
+	size := windows.Coord{X: int16(opts.Cols), Y: int16(opts.Rows)}
+	err := windows.CreatePseudoConsole(size, inRead, outWrite, 0, &console)
+	// 伪控制台持有自己的副本，本进程只保留另一端
+	windows.CloseHandle(inRead)
+	windows.CloseHandle(outWrite)
+	if err != nil {
+		windows.CloseHandle(inWrite)
+		windows.CloseHandle(outRead)
+		return nil, err
+	}
+
+	p := &conPTY{
+		console: console,
+		input:   os.NewFile(uintptr(inWrite), "conpty-input"),
+		output:  os.NewFile(uintptr(outRead), "conpty-output"),
+		exited:  make(chan struct{}),
+	}
+	if err := p.spawn(opts); err != nil {
+		windows.ClosePseudoConsole(console)
+		p.input.Close()
+		p.output.Close()
+		return nil, err
+	}
+	go p.wait()
+	return p, nil
+}
+
+// spawn 在伪控制台中启动 shell
+func (p *conPTY) spawn(opts Options) error {
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return err
+	}
+	defer attrs.Delete()
+	// 该属性的值是伪控制台句柄本身，而不是指向句柄的指针
+	if err := attrs.Update(windows.PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE, *(*unsafe.Pointer)(unsafe.Pointer(&p.console)), unsafe.Sizeof(p.console)); err != nil {
+		return err
+	}
+
+	si := windows.StartupInfoEx{ProcThreadAttributeList: attrs.List()}
+	si.Cb = uint32(unsafe.Sizeof(si))
+	// 不继承 HomeDash 自身的标准输入输出（作为服务运行时可能已被重定向）
+	si.Flags = windows.STARTF_USESTDHANDLES
+
+	shell := opts.Shell
+	if path, err := exec.LookPath(shell); err == nil {
+		shell = path
+	}
+	cmdLine, err := windows.UTF16PtrFromString(windows.ComposeCommandLine(append([]string{shell}, opts.Args...)))
+	if err != nil {
+		return err
+	}
+	var dir *uint16
+	if opts.Dir != "" {
+		if dir, err = windows.UTF16PtrFromString(opts.Dir); err != nil {
+			return err
+		}
+	}
+	env := environmentBlock(append(os.Environ(), opts.Env...))
+
+	var pi windows.ProcessInformation
+	flags := uint32(windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_UNICODE_ENVIRONMENT)
+	if err := windows.CreateProcess(nil, cmdLine, nil, nil, false, flags, &env[0], dir, &si.StartupInfo, &pi); err != nil {
+		return err
+	}
+	windows.CloseHandle(pi.Thread)
+	p.process = pi.Process
+	p.pid = pi.ProcessId
+	return nil
+}
+
+// environmentBlock 生成 CreateProcess 需要的环境变量块：每项以 \0 结尾，整体再以 \0 结尾
+func environmentBlock(env []string) []uint16 {
+	var block []uint16
+	for _, kv := range env {
+		block = append(block, utf16.Encode([]rune(kv))...)
+		block = append(block, 0)
+	}
+	return append(block, 0)
+}
+
+func (p *conPTY) wait() {
+	defer close(p.exited)
+	if _, err := windows.WaitForSingleObject(p.process, windows.INFINITE); err != nil {
+		p.exitCode, p.waitErr = -1, err
+		return
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(p.process, &code); err != nil {
+		p.exitCode, p.waitErr = -1, err
+		return
+	}
+	p.exitCode = int(code)
+}
+
+func (p *conPTY) Read(b []byte) (int, error) {
+	return p.output.Read(b)
+}
+
+func (p *conPTY) Write(b []byte) (int, error) {
+	return p.input.Write(b)
+}
+
+func (p *conPTY) Resize(cols, rows uint16) error {
+	return windows.ResizePseudoConsole(p.console, windows.Coord{X: int16(cols), Y: int16(rows)})
+}
+
+// Signal Ctrl-C 通过输入发送，由伪控制台转换为 CTRL_C_EVENT；结束类信号终止整个进程树
+func (p *conPTY) Signal(sig Signal) error {
+	switch sig {
+	case SignalInterrupt:
+		_, err := p.input.Write([]byte{0x03})
+		return err
+	case SignalTerminate, SignalKill:
+		return p.killTree()
+	default:
+		return ErrUnknownSignal
+	}
+}
+
+func (p *conPTY) killTree() error {
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(int(p.pid)))
+	if err := kill.Run(); err != nil {
+		return windows.TerminateProcess(p.process, 1)
+	}
+	return nil
+}
+
+func (p *conPTY) Wait() (int, error) {
+	<-p.exited
+	return p.exitCode, p.waitErr
+}
+
+// Close 关闭伪控制台（附加的程序会收到关闭事件），超时未退出则终止进程树
+func (p *conPTY) Close() error {
+	p.closeOnce.Do(func() {
+		// 旧版本 Windows 关闭伪控制台时会等待输出被读完，继续读取并丢弃，放在协程中避免阻塞
+		go io.Copy(io.Discard, p.output)
+		go func() {
+			windows.ClosePseudoConsole(p.console)
+			p.output.Close()
+		}()
+		p.input.Close()
+		go func() {
+			select {
+			case <-p.exited:
+			case <-time.After(closeGracePeriod):
+				p.killTree()
+			}
+			<-p.exited
+			windows.CloseHandle(p.process)
+		}()
+	})
+	return nil
+}
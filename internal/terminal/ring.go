@@ -0,0 +1,37 @@
+package terminal
+
+// ring 固定大小的环形缓冲，写满后覆盖最旧的数据，写入时不再分配内存
+type ring struct {
+	buf  []byte
+	pos  int  // 下一次写入的位置
+	full bool // 已写满一圈，pos 之后是最旧的数据
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]byte, size)}
+}
+
+// Write 追加数据，超过容量时只保留末尾
+func (r *ring) Write(p []byte) {
+	size := len(r.buf)
+	if len(p) >= size {
+		copy(r.buf, p[len(p)-size:])
+		r.pos, r.full = 0, true
+		return
+	}
+	n := copy(r.buf[r.pos:], p)
+	copy(r.buf, p[n:])
+	if r.pos+len(p) >= size {
+		r.full = true
+	}
+	r.pos = (r.pos + len(p)) % size
+}
+
+// Bytes 按写入顺序返回缓冲内容的副本
+func (r *ring) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.buf))
+	return append(append(out, r.buf[r.pos:]...), r.buf[:r.pos]...)
+}
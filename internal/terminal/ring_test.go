@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"空", nil, ""},
+		{"未写满", []string{"ab", "c"}, "abc"},
+		{"刚好写满", []string{"abc", "de"}, "abcde"},
+		{"覆盖最旧的数据", []string{"abc", "def"}, "bcdef"},
+		{"多次回绕", []string{"abcd", "ef", "gh", "i"}, "efghi"},
+		{"单次超过容量", []string{"ab", "0123456789"}, "56789"},
+		{"单次等于容量", []string{"ab", "01234"}, "01234"},
+		{"空写入", []string{"abc", "", "d"}, "abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing(5)
+			for _, w := range tt.writes {
+				r.Write([]byte(w))
+			}
+			if got := string(r.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRingRandom 与直接追加再截取末尾的结果一致
+func TestRingRandom(t *testing.T) {
+	const size = 64
+	rng := rand.New(rand.NewSource(1))
+	r := newRing(size)
+	var all []byte
+	for i := 0; i < 1000; i++ {
+		p := make([]byte, rng.Intn(2*size))
+		rng.Read(p)
+		r.Write(p)
+		all = append(all, p...)
+		want := all
+		if len(want) > size {
+			want = want[len(want)-size:]
+		}
+		if got := r.Bytes(); !bytes.Equal(got, want) {
+			t.Fatalf("第 %d 次写入后内容不一致", i)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package terminal
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxSessions = 10
+	DefaultIdleTimeout = 30 * time.Minute
+
+	scrollbackSize   = 256 * 1024 // 每个会话保留的输出，重新连接时回放
+	subscriberBuffer = 256        // 连接的输出队列长度，跟不上输出的连接会被断开
+	reapInterval     = time.Minute
+)
+
+var (
+	ErrTooManySessions = errors.New("终端会话数量已达上限，请先结束不用的会话")
+	ErrSessionNotFound = errors.New("终端会话不存在或已结束")
+)
+
+// SessionInfo 会话信息
+type SessionInfo struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	OwnerID    string `json:"ownerId"`
+	Owner      string `json:"owner"`
+	Shell      string `json:"shell"`
+	Cols       uint16 `json:"cols"`
+	Rows       uint16 `json:"rows"`
+	Clients    int    `json:"clients"`    // 当前连接数
+	Recording  bool   `json:"recording"`  // 是否正在录制
+	CreatedAt  int64  `json:"createdAt"`  // 毫秒时间戳
+	LastActive int64  `json:"lastActive"` // 最近一次输入或输出
+}
+
+// Recorder 录制会话输出
+type Recorder interface {
+	Output(data []byte)
+	Resize(cols, rows uint16)
+	Close() error
+}
+
+// Session 在服务端持续运行的终端会话，浏览器断开后不会结束，可从其他设备重新连接
+type Session struct {
+	pty      PTY
+	recorder Recorder
+
+	mu         sync.Mutex
+	info       SessionInfo
+	scrollback *ring // 回放缓冲
+	clients    map[*Attachment]struct{}
+	detachedAt time.Time // 最后一个连接断开的时间，用于判断空闲
+
+	exited   chan struct{}
+	exitCode int
+}
+
+// Attachment 一个连接到会话的客户端
+type Attachment struct {
+	output chan []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Output 会话输出
+func (a *Attachment) Output() <-chan []byte {
+	return a.output
+}
+
+// Done 会话结束或连接因跟不上输出被断开时关闭
+func (a *Attachment) Done() <-chan struct{} {
+	return a.done
+}
+
+func (a *Attachment) close() {
+	a.once.Do(func() { close(a.done) })
+}
+
+// Info 会话信息
+func (s *Session) Info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.info
+	info.Clients = len(s.clients)
+	return info
+}
+
+// Attach 连接到会话，返回连接和当前的回放内容
+func (s *Session) Attach() (*Attachment, []byte) {
+	a := &Attachment{output: make(chan []byte, subscriberBuffer), done: make(chan struct{})}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.exited:
+		a.close()
+		return a, nil
+	default:
+	}
+	s.clients[a] = struct{}{}
+	return a, s.scrollback.Bytes()
+}
+
+// Detach 断开连接，会话继续运行
+func (s *Session) Detach(a *Attachment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[a]; ok {
+		delete(s.clients, a)
+		if len(s.clients) == 0 {
+			s.detachedAt = time.Now()
+		}
+	}
+	a.close()
+}
+
+// Write 键盘输入
+func (s *Session) Write(b []byte) (int, error) {
+	s.touch()
+	return s.pty.Write(b)
+}
+
+// Resize 调整窗口大小（多个连接时以最后调整的为准）
+func (s *Session) Resize(cols, rows uint16) error {
+	s.mu.Lock()
+	s.info.Cols, s.info.Rows = cols, rows
+	if s.recorder != nil {
+		s.recorder.Resize(cols, rows)
+	}
+	s.mu.Unlock()
+	return s.pty.Resize(cols, rows)
+}
+
+// Signal 向前台程序发送信号
+func (s *Session) Signal(sig Signal) error {
+	return s.pty.Signal(sig)
+}
+
+// Exited shell 退出时关闭
+func (s *Session) Exited() <-chan struct{} {
+	return s.exited
+}
+
+// ExitCode shell 的退出码，仅在 Exited 关闭后有效
+func (s *Session) ExitCode() int {
+	<-s.exited
+	return s.exitCode
+}
+
+// Close 结束会话
+func (s *Session) Close() error {
+	return s.pty.Close()
+}
+
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.info.LastActive = time.Now().UnixMilli()
+	s.mu.Unlock()
+}
+
+// pump 读取输出：写入回放缓冲并推送给所有连接
+func (s *Session) pump() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			s.broadcast(append([]byte(nil), buf[:n]...))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Session) broadcast(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.LastActive = time.Now().UnixMilli()
+	if s.recorder != nil {
+		s.recorder.Output(data)
+	}
+	s.scrollback.Write(data)
+	for a := range s.clients {
+		select {
+		case a.output <- data:
+		default:
+			// 网络太慢跟不上输出，断开该连接，重新连接后从回放缓冲恢复
+			delete(s.clients, a)
+			a.close()
+			if len(s.clients) == 0 {
+				s.detachedAt = time.Now()
+			}
+		}
+	}
+}
+
+// Manager 管理全部终端会话
+type Manager struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	maxSessions int
+	idleTimeout time.Duration
+	record      func(SessionInfo) (Recorder, error)
+}
+
+// NewManager 创建会话管理器：最多 maxSessions 个会话（<=0 不限制），
+// 没有连接且超过 idleTimeout 没有输入输出的会话自动结束（<=0 不结束）
+func NewManager(maxSessions int, idleTimeout time.Duration) *Manager {
+	m := &Manager{
+		sessions:    make(map[string]*Session),
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+	}
+	go m.reapLoop()
+	return m
+}
+
+// SetRecorder 设置后新建的会话都会被录制，nil 关闭录制
+func (m *Manager) SetRecorder(record func(SessionInfo) (Recorder, error)) {
+	m.mu.Lock()
+	m.record = record
+	m.mu.Unlock()
+}
+
+// Create 启动本地 shell 会话
+func (m *Manager) Create(opts Options, name, ownerID, owner string) (*Session, error) {
+	if opts.Shell == "" {
+		opts.Shell, opts.Args = DefaultShell()
+	}
+	if opts.Cols == 0 || opts.Rows == 0 {
+		opts.Cols, opts.Rows = 80, 24
+	}
+	info := SessionInfo{Name: name, OwnerID: ownerID, Owner: owner, Shell: opts.Shell, Cols: opts.Cols, Rows: opts.Rows}
+	return m.CreateWith(info, func() (PTY, error) { return Start(opts) })
+}
+
+// CreateWith 用 start 打开的终端（如 SSH 连接）创建会话，info 提供名称、创建者、shell 描述和窗口大小
+func (m *Manager) CreateWith(info SessionInfo, start func() (PTY, error)) (*Session, error) {
+	m.mu.Lock()
+	if m.maxSessions > 0 && len(m.sessions) >= m.maxSessions {
+		m.mu.Unlock()
+		return nil, ErrTooManySessions
+	}
+	// 先占位，避免并发创建超出上限
+	id := uuid.New().String()[:8]
+	m.sessions[id] = nil
+	record := m.record
+	m.mu.Unlock()
+
+	pty, err := start()
+	if err != nil {
+		m.mu.Lock()
+		delete(m.sessions, id)
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	now := time.Now()
+	info.ID = id
+	info.Name = strings.TrimSpace(info.Name)
+	if info.Name == "" {
+		info.Name = "终端 " + now.Format("01-02 15:04")
+	}
+	info.Clients = 0
+	info.CreatedAt = now.UnixMilli()
+	info.LastActive = now.UnixMilli()
+	s := &Session{
+		pty:        pty,
+		info:       info,
+		scrollback: newRing(scrollbackSize),
+		clients:    make(map[*Attachment]struct{}),
+		detachedAt: now,
+		exited:     make(chan struct{}),
+	}
+	if record != nil {
+		// 录制失败不影响使用终端
+		if rec, err := record(s.info); err != nil {
+			log.Printf("⚠ 终端会话录制失败: %v", err)
+		} else {
+			s.recorder = rec
+			s.info.Recording = true
+		}
+	}
+	m.mu.Lock()
+	m.sessions[id] = s
+	m.mu.Unlock()
+
+	pumpDone := make(chan struct{})
+	go func() {
+		s.pump()
+		close(pumpDone)
+	}()
+	go func() {
+		s.exitCode, _ = pty.Wait()
+		// 等待剩余输出读完（Windows 的伪控制台在关闭前不会结束输出，最多等待片刻）
+		select {
+		case <-pumpDone:
+		case <-time.After(500 * time.Millisecond):
+		}
+		pty.Close()
+		m.mu.Lock()
+		delete(m.sessions, id)
+		m.mu.Unlock()
+		s.mu.Lock()
+		if s.recorder != nil {
+			s.recorder.Close()
+		}
+		close(s.exited)
+		for a := range s.clients {
+			a.close()
+		}
+		s.mu.Unlock()
+	}()
+	return s, nil
+}
+
+// Get 按 ID 查找会话
+func (m *Manager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[id]
+	return s, s != nil
+}
+
+// List 全部会话，按创建时间排序
+func (m *Manager) List() []SessionInfo {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if s != nil {
+			sessions = append(sessions, s)
+		}
+	}
+	m.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt < infos[j].CreatedAt })
+	return infos
+}
+
+// Rename 修改会话名称
+func (m *Manager) Rename(id, name string) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("会话名称不能为空")
+	}
+	s.mu.Lock()
+	s.info.Name = name
+	s.mu.Unlock()
+	return nil
+}
+
+// Kill 结束会话
+func (m *Manager) Kill(id string) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return s.Close()
+}
+
+// reapLoop 定期结束长时间无连接的会话
+func (m *Manager) reapLoop() {
+	if m.idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.reapIdle(time.Now())
+	}
+}
+
+func (m *Manager) reapIdle(now time.Time) {
+	m.mu.Lock()
+	var idle []*Session
+	for _, s := range m.sessions {
+		if s == nil {
+			continue
+		}
+		s.mu.Lock()
+		// 断开后仍在输出的程序（如编译、下载）不算空闲
+		last := time.UnixMilli(s.info.LastActive)
+		if s.detachedAt.After(last) {
+			last = s.detachedAt
+		}
+		if len(s.clients) == 0 && now.Sub(last) > m.idleTimeout {
+			idle = append(idle, s)
+		}
+		s.mu.Unlock()
+	}
+	m.mu.Unlock()
+	for _, s := range idle {
+		s.Close()
+	}
+}
@@ -0,0 +1,69 @@
+// Package terminal 提供伪终端（PTY）：Unix 上使用 creack/pty，Windows 上使用 ConPTY，
+// 使 vim、top 等交互式程序可以在浏览器终端中运行
+package terminal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Signal 可以发送给终端中前台程序的信号
+type Signal string
+
+const (
+	SignalInterrupt Signal = "INT"  // Ctrl-C
+	SignalTerminate Signal = "TERM" // 请求退出
+	SignalKill      Signal = "KILL" // 强制结束
+)
+
+// closeGracePeriod 关闭终端后等待程序自行退出的时间，超时后强制结束
+const closeGracePeriod = 3 * time.Second
+
+// ErrUnknownSignal 不支持的信号
+var ErrUnknownSignal = errors.New("不支持的信号")
+
+// Options 启动终端的参数
+type Options struct {
+	Shell string
+	Args  []string
+	Dir   string
+	Env   []string // 追加到当前进程环境变量之后
+	Cols  uint16
+	Rows  uint16
+}
+
+// PTY 运行中的伪终端：读取得到程序输出（含控制序列），写入即键盘输入
+type PTY interface {
+	io.ReadWriteCloser
+	// Resize 调整终端窗口大小
+	Resize(cols, rows uint16) error
+	// Signal 向前台程序发送信号
+	Signal(sig Signal) error
+	// Wait 等待 shell 退出并返回退出码
+	Wait() (int, error)
+}
+
+// Start 启动 shell 并连接到新的伪终端
+func Start(opts Options) (PTY, error) {
+	if opts.Shell == "" {
+		opts.Shell, opts.Args = DefaultShell()
+	}
+	if opts.Cols == 0 || opts.Rows == 0 {
+		opts.Cols, opts.Rows = 80, 24
+	}
+	return start(opts)
+}
+
+// DefaultShell 当前系统的默认交互式 shell
+func DefaultShell() (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "powershell.exe", []string{"-NoLogo"}
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell, []string{"-l"}
+	}
+	return "/bin/bash", []string{"-l"}
+}
@@ -0,0 +1,42 @@
+package tlscert
+
+import (
+	"net"
+	"net/http"
+)
+
+// hstsMaxAge HSTS 有效期（一年）
+const hstsMaxAge = "max-age=31536000"
+
+// RedirectHandler 将 HTTP 请求重定向到 HTTPS 端口上的同一地址
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+
+		// GET/HEAD 用 301，其他方法用 308 保留请求方法和内容
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
+
+// HSTS 在 HTTPS 响应中添加 Strict-Transport-Security，浏览器此后只通过 HTTPS 访问
+func HSTS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", hstsMaxAge)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
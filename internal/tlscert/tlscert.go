@@ -0,0 +1,169 @@
+// Package tlscert 提供 HTTPS 证书：加载用户提供的证书文件（修改后自动重新加载），
+// 或在数据目录中生成并保存自签名 CA 与服务器证书
+package tlscert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const reloadDelay = 500 * time.Millisecond // 合并证书续期工具写入时的连续事件
+
+// Config 证书来源：指定 CertFile/KeyFile 时使用证书文件，否则 SelfSigned 时生成自签名证书
+type Config struct {
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+	Hosts      []string // 自签名证书额外包含的主机名或 IP
+	DataDir    string   // 自签名 CA 与证书保存在 DataDir/tls
+}
+
+// Enabled 是否启用 HTTPS
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.SelfSigned
+}
+
+// Manager 持有当前证书，供 tls.Config.GetCertificate 使用
+type Manager struct {
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	loaded []byte // 当前证书文件和私钥文件的内容，用于判断是否真的发生了变化
+	config Config
+	caFile string // 自签名模式下的 CA 证书，供用户导入信任
+}
+
+// New 按配置加载或生成证书
+func New(cfg Config) (*Manager, error) {
+	m := &Manager{config: cfg}
+	switch {
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("证书文件和私钥文件需同时指定")
+		}
+		if _, err := m.reload(); err != nil {
+			return nil, err
+		}
+	case cfg.SelfSigned:
+		dir := filepath.Join(cfg.DataDir, "tls")
+		cert, err := ensureSelfSigned(dir, cfg.Hosts)
+		if err != nil {
+			return nil, fmt.Errorf("生成自签名证书失败: %v", err)
+		}
+		m.cert = cert
+		m.caFile = filepath.Join(dir, caCertFile)
+	default:
+		return nil, errors.New("未配置证书")
+	}
+	return m, nil
+}
+
+// CAFile 自签名 CA 证书路径（使用证书文件时为空）
+func (m *Manager) CAFile() string {
+	return m.caFile
+}
+
+// TLSConfig 用于 http.Server 的 TLS 配置
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.getCertificate,
+	}
+}
+
+func (m *Manager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert, nil
+}
+
+// reload 重新读取证书文件，返回证书是否有变化；失败时保留当前证书
+func (m *Manager) reload() (bool, error) {
+	certPEM, err := os.ReadFile(m.config.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("读取证书失败: %v", err)
+	}
+	keyPEM, err := os.ReadFile(m.config.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("读取私钥失败: %v", err)
+	}
+	loaded := append(append([]byte(nil), certPEM...), keyPEM...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if bytes.Equal(loaded, m.loaded) {
+		return false, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("加载证书失败: %v", err)
+	}
+	m.cert = &cert
+	m.loaded = loaded
+	return true, nil
+}
+
+// Watch 监听证书文件的修改（如 certbot 续期），自动重新加载；自签名模式无需监听
+func (m *Manager) Watch() error {
+	if m.config.CertFile == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// 监听所在目录：续期工具通常以重命名或替换符号链接的方式更新文件
+	dirs := map[string]bool{
+		filepath.Dir(m.config.CertFile): true,
+		filepath.Dir(m.config.KeyFile):  true,
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	go m.watchLoop(watcher)
+	return nil
+}
+
+func (m *Manager) watchLoop(watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// 目录内任何变化都尝试重新加载（符号链接目标的变化无法按文件名判断）
+			if timer != nil {
+				timer.Reset(reloadDelay)
+				continue
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				changed, err := m.reload()
+				if err != nil {
+					log.Printf("⚠ 证书已修改但重新加载失败，继续使用旧证书: %v", err)
+					return
+				}
+				if changed {
+					log.Printf("✓ 已重新加载证书: %s", m.config.CertFile)
+				}
+			})
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("证书文件监听错误: %v", err)
+		}
+	}
+}